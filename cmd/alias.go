@@ -0,0 +1,234 @@
+/*
+Copyright © 2024 Richard Weston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/google/uuid"
+	"github.com/ktr0731/go-fuzzyfinder"
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/metadata"
+	"github.com/riweston/aztx/pkg/subscription"
+	"github.com/riweston/aztx/pkg/types"
+
+	"github.com/spf13/cobra"
+)
+
+// aliasCmd manages short names for subscriptions
+var aliasCmd = &cobra.Command{
+	Use:   "alias",
+	Short: "Manage subscription aliases",
+	Long: `Give subscriptions short names that can be passed to aztx instead of their ID or name,
+e.g. "aztx alias set prod 'Contoso Production'" followed by "aztx prod".
+Aliases are kept in aztx's metadata file and never written to the Azure profile.`,
+}
+
+var aliasSetCmd = &cobra.Command{
+	Use:   "set <alias> [query]",
+	Short: "Assign an alias to a subscription",
+	Long: `Assign an alias to the subscription matching query, or to the subscription chosen
+with the fuzzy finder when no query is given. A subscription has at most one alias,
+so setting a new one replaces the old.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		alias := strings.ToLower(args[0])
+		if !validAlias(alias) {
+			return pkgerrors.ErrInvalidAlias
+		}
+
+		store, err := newMetadataStore()
+		if err != nil {
+			return err
+		}
+		sub, err := pickSubscription(args[1:])
+		if err != nil || sub == nil {
+			return err
+		}
+
+		err = store.Update(func(m *metadata.Metadata) error {
+			m.SetAlias(alias, sub.ID.String())
+			return nil
+		})
+		if err != nil {
+			return pkgerrors.ErrOperation("saving alias", err)
+		}
+		newLogger().Success("Alias %s now points to %s (%s)", alias, sub.Name, sub.ID)
+		return printResult(cmd, aliasResult{Alias: alias, SubscriptionID: sub.ID.String(), SubscriptionName: sub.Name})
+	},
+}
+
+var aliasRmCmd = &cobra.Command{
+	Use:     "rm <alias>",
+	Aliases: []string{"remove"},
+	Short:   "Remove an alias",
+	Args:    cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := newMetadataStore()
+		if err != nil {
+			return err
+		}
+		alias := strings.ToLower(args[0])
+		var id string
+		removed := false
+		err = store.Update(func(m *metadata.Metadata) error {
+			id = m.Aliases()[alias]
+			removed = m.RemoveAlias(alias)
+			return nil
+		})
+		if err != nil {
+			return pkgerrors.ErrOperation("removing alias", err)
+		}
+		if !removed {
+			return fmt.Errorf("%w: %s", pkgerrors.ErrAliasNotFound, args[0])
+		}
+		newLogger().Success("Removed alias %s", alias)
+		return printResult(cmd, aliasResult{Alias: alias, SubscriptionID: id, SubscriptionName: subscriptionNames()[id]})
+	},
+}
+
+var aliasListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List aliases",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := newMetadataStore()
+		if err != nil {
+			return err
+		}
+		kept, err := store.Load()
+		if err != nil {
+			return pkgerrors.ErrOperation("reading metadata", err)
+		}
+		aliases := kept.Aliases()
+		names := make([]string, 0, len(aliases))
+		for alias := range aliases {
+			names = append(names, alias)
+		}
+		sort.Strings(names)
+
+		subs := subscriptionNames()
+		if outputFormat().Structured() {
+			results := make([]aliasResult, 0, len(names))
+			for _, alias := range names {
+				results = append(results, aliasResult{Alias: alias, SubscriptionID: aliases[alias], SubscriptionName: subs[aliases[alias]]})
+			}
+			return printResult(cmd, results)
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ALIAS\tSUBSCRIPTION\tID")
+		for _, alias := range names {
+			id := aliases[alias]
+			name, ok := subs[id]
+			if !ok {
+				name = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", alias, name, id)
+		}
+		return w.Flush()
+	},
+}
+
+// aliasResult is an alias in structured output.
+type aliasResult struct {
+	Alias            string `json:"alias"`
+	SubscriptionID   string `json:"subscriptionId"`
+	SubscriptionName string `json:"subscriptionName"`
+}
+
+// reservedAlias matches arguments aztx already gives a meaning to.
+var reservedAlias = regexp.MustCompile(`^-[0-9]*$`)
+
+// validAlias reports whether alias can be used as a positional query without being
+// mistaken for a subscription ID, the previous context or a history jump.
+func validAlias(alias string) bool {
+	if alias == "" || strings.ContainsAny(alias, " \t\r\n") || reservedAlias.MatchString(alias) {
+		return false
+	}
+	_, err := uuid.Parse(alias)
+	return err != nil
+}
+
+// pickSubscription resolves the subscription an alias, favorite or tag command applies to,
+// from a query when one is given or with the fuzzy finder otherwise. It returns nil
+// without an error when the finder is aborted.
+func pickSubscription(args []string) (*types.Subscription, error) {
+	fa, err := newProfileStorage()
+	if err != nil {
+		return nil, err
+	}
+	storage, err := withMetadata(fa)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := storage.ReadConfig()
+	if err != nil {
+		return nil, pkgerrors.ErrReadingConfiguration(err)
+	}
+
+	subManager := subscription.Manager{BaseManager: types.BaseManager{Configuration: cfg}}
+	var sub *types.Subscription
+	if len(args) > 0 {
+		sub, err = resolveQuery(&subManager, args[0])
+	} else {
+		sub, err = subManager.SelectSubscription(cfg.Subscriptions)
+	}
+	if err != nil {
+		if errors.Is(err, fuzzyfinder.ErrAbort) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return sub, nil
+}
+
+// subscriptionNames returns the names of the subscriptions in the active profile keyed
+// by lowercased ID. It is best effort, returning an empty map when the profile cannot be read.
+func subscriptionNames() map[string]string {
+	names := make(map[string]string)
+	fa, err := newProfileStorage()
+	if err != nil {
+		return names
+	}
+	cfg, err := fa.ReadConfig()
+	if err != nil {
+		return names
+	}
+	for _, sub := range cfg.Subscriptions {
+		names[strings.ToLower(sub.ID.String())] = sub.Name
+	}
+	return names
+}
+
+func init() {
+	rootCmd.AddCommand(aliasCmd)
+	aliasCmd.AddCommand(aliasSetCmd, aliasRmCmd, aliasListCmd)
+	aliasSetCmd.ValidArgsFunction = completeSubscription(1)
+	aliasRmCmd.ValidArgsFunction = completeAliases
+}
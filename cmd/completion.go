@@ -0,0 +1,230 @@
+/*
+Copyright © 2024 Richard Weston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/riweston/aztx/pkg/metadata"
+	"github.com/riweston/aztx/pkg/subscription"
+	"github.com/riweston/aztx/pkg/tenant"
+	"github.com/riweston/aztx/pkg/types"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// completeFunc completes a positional argument or flag value.
+type completeFunc = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+// completionConfig reads the active profile with the metadata kept by aztx merged in.
+// Completion runs on every tab, so it takes no lock, migrates nothing and writes
+// nothing; it returns nil when the profile cannot be read.
+func completionConfig() *types.Configuration {
+	cfg, err := readProfileConfig()
+	if err != nil {
+		return nil
+	}
+	return cfg
+}
+
+// completionValues renders completions the way cobra expects them, as the value and
+// its description separated by a tab.
+func completionValues(completions []types.Completion) []string {
+	values := make([]string, 0, len(completions))
+	for _, c := range completions {
+		if c.Description == "" {
+			values = append(values, c.Value)
+		} else {
+			values = append(values, c.Value+"\t"+c.Description)
+		}
+	}
+	return values
+}
+
+// completeSubscription completes the subscription a command applies to, in the
+// argument at position. Later arguments are not completed.
+func completeSubscription(position int) completeFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) != position {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		cfg := completionConfig()
+		if cfg == nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		subManager := subscription.Manager{BaseManager: types.BaseManager{Configuration: cfg}}
+		return completionValues(subManager.Completions()), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeSwitchQuery completes the query of aztx itself: the subscriptions and -,
+// described by the context it returns to.
+func completeSwitchQuery(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	values, directive := completeSubscription(0)(cmd, args, toComplete)
+	if len(args) > 0 {
+		return values, directive
+	}
+	previous := "previous context"
+	if _, name := newStateManager().GetLastContext(); name != "" {
+		previous = fmt.Sprintf("previous context: %s", name)
+	}
+	return append([]string{"-\t" + previous}, values...), directive
+}
+
+// completeTenant completes a tenant ID or custom name.
+func completeTenant(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	cfg := completionConfig()
+	if cfg == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	tenantManager := tenant.Manager{BaseManager: types.BaseManager{Configuration: cfg}}
+	return completionValues(tenantManager.Completions()), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeTenantArg completes a tenant in the first positional argument only.
+func completeTenantArg(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeTenant(cmd, args, toComplete)
+}
+
+// completeField completes the distinct values a field takes across the subscriptions
+// of the active profile, such as their states or clouds.
+func completeField(field func(types.Subscription) string) completeFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		cfg := completionConfig()
+		if cfg == nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		seen := make(map[string]bool)
+		var values []string
+		for _, sub := range cfg.Subscriptions {
+			if value := field(sub); value != "" && !seen[value] {
+				seen[value] = true
+				values = append(values, value)
+			}
+		}
+		sort.Strings(values)
+		return values, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeSortKeys completes a comma-separated list of sort keys.
+func completeSortKeys(keys []string) completeFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		prefix := ""
+		if i := strings.LastIndex(toComplete, ","); i >= 0 {
+			prefix = toComplete[:i+1]
+		}
+		values := make([]string, 0, len(keys))
+		for _, key := range keys {
+			values = append(values, prefix+key)
+		}
+		return values, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+	}
+}
+
+// completeAliases completes the aliases kept by aztx.
+func completeAliases(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	kept := completionMetadata()
+	if kept == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names := subscriptionNames()
+	var completions []types.Completion
+	for alias, id := range kept.Aliases() {
+		completions = append(completions, types.Completion{Value: alias, Description: names[id]})
+	}
+	sort.Slice(completions, func(i, j int) bool {
+		return completions[i].Value < completions[j].Value
+	})
+	return completionValues(completions), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeTags completes the subscription of a tag command, then the tags in use.
+func completeTags(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) == 0 {
+		return completeSubscription(0)(cmd, args, toComplete)
+	}
+	kept := completionMetadata()
+	if kept == nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	seen := make(map[string]bool)
+	var tags []string
+	for id := range kept.Subscriptions {
+		for _, tag := range kept.Tags(id) {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeConfigDirs completes the names of the config-dirs in ~/.aztx.yml, and
+// directories otherwise.
+func completeConfigDirs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	dirs := viper.GetStringMapString("config-dirs")
+	if len(dirs) == 0 {
+		return nil, cobra.ShellCompDirectiveFilterDirs
+	}
+	completions := make([]types.Completion, 0, len(dirs))
+	for name, path := range dirs {
+		completions = append(completions, types.Completion{Value: name, Description: path})
+	}
+	sort.Slice(completions, func(i, j int) bool {
+		return completions[i].Value < completions[j].Value
+	})
+	return completionValues(completions), cobra.ShellCompDirectiveFilterDirs
+}
+
+// completionMetadata loads the metadata kept by aztx without migrating anything, or
+// returns nil when it cannot be read.
+func completionMetadata() *metadata.Metadata {
+	path, err := metadata.DefaultPath()
+	if err != nil {
+		return nil
+	}
+	kept, err := metadata.NewStore(path, 0).Load()
+	if err != nil {
+		return nil
+	}
+	return kept
+}
+
+// registerFlagCompletion registers completion for a flag of cmd. It only fails when
+// the flag does not exist, which is a programming error.
+func registerFlagCompletion(cmd *cobra.Command, flag string, fn completeFunc) {
+	if err := cmd.RegisterFlagCompletionFunc(flag, fn); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,230 @@
+/*
+Copyright © 2024 Richard Weston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/metadata"
+	"github.com/riweston/aztx/pkg/subscription"
+	"github.com/riweston/aztx/pkg/types"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+// defaultProductionTags are the tags that mark a subscription as production when
+// production-tags is not set in ~/.aztx.yml.
+var defaultProductionTags = []string{"prod", "production"}
+
+// productionColor wraps the current context when it is production and colour is on.
+const productionColor = "\033[1;31m%s\033[0m"
+
+// currentCmd prints the active context for scripts and shell prompts
+var currentCmd = &cobra.Command{
+	Use:   "current",
+	Short: "Print the active subscription",
+	Long: `Print the subscription that is currently the default, read straight from the Azure
+profile without starting the Azure CLI, which makes it cheap enough for shell prompts.
+Inside a session started with "aztx --session" it is the subscription of the session.
+
+The --format template can use the fields {{.Name}}, {{.ID}}, {{.TenantID}},
+{{.TenantName}}, {{.TenantAlias}}, {{.User}}, {{.UserType}}, {{.Cloud}}, {{.Alias}},
+{{.Tags}}, {{.Production}}, {{.Protected}}, {{.Color}} and {{.Session}}. A subscription
+tagged with one of the production-tags from ~/.aztx.yml ("prod" and "production" by
+default) is production. Production and protected subscriptions have the Color "red"
+instead of "green" and, with --color, are printed in red.
+
+With --check nothing is printed and aztx fails when the working directory is pinned
+to another subscription by a .aztx file, see "aztx pin".
+
+For prompts, --prompt prints nothing instead of failing when there is no active
+subscription and leaves out the trailing newline, e.g. for starship:
+
+  [custom.azure]
+  command = "aztx current --prompt --color always"
+  when = true`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if check, _ := cmd.Flags().GetBool("check"); check {
+			return checkPin()
+		}
+		prompt, _ := cmd.Flags().GetBool("prompt")
+		current, err := readCurrentContext()
+		if err != nil {
+			if prompt {
+				return nil
+			}
+			return err
+		}
+
+		if outputFormat().Structured() {
+			return printResult(cmd, current)
+		}
+
+		format, _ := cmd.Flags().GetString("format")
+		tmpl, err := template.New("current").Option("missingkey=error").Funcs(template.FuncMap{
+			"join": strings.Join,
+		}).Parse(format)
+		if err != nil {
+			return pkgerrors.ErrOperation("parsing format", err)
+		}
+		var out strings.Builder
+		if err := tmpl.Execute(&out, current); err != nil {
+			return pkgerrors.ErrOperation("rendering format", err)
+		}
+
+		text := out.String()
+		if colour, _ := cmd.Flags().GetString("color"); (current.Production || current.Protected) && useColor(cmd.OutOrStdout(), colour) {
+			text = fmt.Sprintf(productionColor, text)
+		}
+		if !prompt {
+			text += "\n"
+		}
+		_, err = fmt.Fprint(cmd.OutOrStdout(), text)
+		return err
+	},
+}
+
+// currentContext is the active context as exposed to --format templates and
+// structured output.
+type currentContext struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	TenantID    string   `json:"tenantId"`
+	TenantName  string   `json:"tenantName"`  // Custom name of the tenant, or the account name
+	TenantAlias string   `json:"tenantAlias"` // Custom name of the tenant, empty when it has none
+	User        string   `json:"user"`
+	UserType    string   `json:"userType"`
+	Cloud       string   `json:"cloud"`
+	Alias       string   `json:"alias"`
+	Tags        []string `json:"tags"`
+	Production  bool     `json:"production"`
+	Protected   bool     `json:"protected"` // Whether a protected rule in ~/.aztx.yml matches the subscription
+	Color       string   `json:"color"`
+	Session     bool     `json:"session"` // Whether the context belongs to the session of this shell
+}
+
+// readCurrentContext reads the default subscription from the active profile. It takes
+// no lock and writes nothing, so that it stays fast enough to run on every prompt.
+func readCurrentContext() (*currentContext, error) {
+	fa, err := newProfileStorage()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := fa.ReadConfig()
+	if err != nil {
+		return nil, pkgerrors.ErrReadingConfiguration(err)
+	}
+
+	subManager := subscription.Manager{BaseManager: types.BaseManager{Configuration: cfg}}
+	sub, err := subManager.DefaultSubscription()
+	if err != nil {
+		return nil, err
+	}
+
+	current := &currentContext{
+		ID:       sub.ID.String(),
+		Name:     sub.Name,
+		TenantID: sub.TenantID.String(),
+		User:     sub.User.Name,
+		UserType: sub.User.Type,
+		Cloud:    sub.EnvironmentName,
+		Tags:     []string{},
+		Session:  isSessionOverlay(fa.ConfigDir()),
+	}
+	// Tenant names not yet moved out of the profile still count.
+	for _, t := range cfg.Tenants {
+		if t.ID == sub.TenantID {
+			current.TenantAlias = t.CustomName
+		}
+	}
+
+	if path, err := metadata.DefaultPath(); err == nil {
+		if kept, err := metadata.NewStore(path, 0).Load(); err == nil {
+			if name, ok := kept.TenantNames()[current.TenantID]; ok {
+				current.TenantAlias = name
+			}
+			current.Alias = kept.Subscriptions[current.ID].Alias
+			current.Tags = nonNil(kept.Tags(current.ID))
+		}
+	}
+
+	current.TenantName = current.TenantAlias
+	if current.TenantName == "" {
+		current.TenantName = current.User
+	}
+	current.Production = isProduction(current.Tags)
+	if rules, err := protectRules(); err == nil {
+		sub.Tags = current.Tags
+		current.Protected = rules.Match(*sub, current.TenantAlias)
+	}
+	current.Color = "green"
+	if current.Production || current.Protected {
+		current.Color = "red"
+	}
+	return current, nil
+}
+
+// isProduction reports whether tags include one of the production tags.
+func isProduction(tags []string) bool {
+	productionTags := viper.GetStringSlice("production-tags")
+	if len(productionTags) == 0 {
+		productionTags = defaultProductionTags
+	}
+	for _, tag := range tags {
+		for _, production := range productionTags {
+			if strings.EqualFold(tag, production) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// useColor resolves the --color setting for output written to w: always, never, or
+// auto when w is a terminal.
+func useColor(w io.Writer, setting string) bool {
+	switch setting {
+	case "always":
+		return true
+	case "never":
+		return false
+	default:
+		f, ok := w.(interface{ Fd() uintptr })
+		return ok && term.IsTerminal(int(f.Fd()))
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(currentCmd)
+	currentCmd.Flags().StringP("format", "f", "{{.Name}}", "Go template for the output")
+	currentCmd.Flags().Bool("prompt", false, "Print nothing when there is no active subscription and omit the trailing newline")
+	currentCmd.Flags().Bool("check", false, "Fail when the active subscription is not the one the working directory is pinned to")
+	currentCmd.Flags().String("color", "auto", "Print production subscriptions in red: auto, always or never")
+	registerFlagCompletion(currentCmd, "color", cobra.FixedCompletions([]string{"auto", "always", "never"}, cobra.ShellCompDirectiveNoFileComp))
+}
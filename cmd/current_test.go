@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/riweston/aztx/pkg/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCurrent_Format(t *testing.T) {
+	newTestHome(t)
+	for _, args := range [][]string{
+		{"tenant", "rename", "11111111-1111-1111-1111-111111111111", "Contoso"},
+		{"alias", "set", "prod", "Production Workloads"},
+		{"tag", "add", "Production Workloads", "critical"},
+	} {
+		_, err := run(t, args...)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{name: "default", want: "Production Workloads\n"},
+		{name: "ids", format: "{{.ID}} {{.TenantID}}", want: "9e7969ef-4cb8-4a2d-959f-bfdaae452a3d 11111111-1111-1111-1111-111111111111\n"},
+		{name: "tenant", format: "{{.TenantName}}/{{.TenantAlias}}", want: "Contoso/Contoso\n"},
+		{name: "account", format: "{{.User}} ({{.UserType}}) {{.Cloud}}", want: "Contoso Ltd (user) AzureCloud\n"},
+		{name: "metadata", format: "{{.Alias}} {{join .Tags \",\"}} {{.Color}}", want: "prod critical green\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := []string{"current"}
+			if tt.format != "" {
+				args = append(args, "--format", tt.format)
+			}
+			out, err := run(t, args...)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, out)
+		})
+	}
+
+	_, err := run(t, "current", "--format", "{{.Missing}}")
+	assert.Error(t, err)
+}
+
+func TestCurrent_Prompt(t *testing.T) {
+	dir := newTestHome(t)
+
+	out, err := run(t, "current", "--prompt")
+	require.NoError(t, err)
+	assert.Equal(t, "Production Workloads", out, "no trailing newline")
+
+	// Without a default subscription the prompt stays empty instead of failing.
+	data, err := os.ReadFile(filepath.Join(dir, storage.ProfileFileName))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, storage.ProfileFileName), []byte(`{"subscriptions": []}`), 0644))
+	out, err = run(t, "current", "--prompt")
+	require.NoError(t, err)
+	assert.Empty(t, out)
+	_, err = run(t, "current")
+	assert.Error(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(dir, storage.ProfileFileName), data, 0644))
+}
+
+func TestCurrent_ProductionColor(t *testing.T) {
+	newTestHome(t)
+	_, err := run(t, "tag", "add", "Production Workloads", "prod")
+	require.NoError(t, err)
+
+	tests := []struct {
+		color string
+		want  string
+	}{
+		{color: "always", want: fmt.Sprintf(productionColor, "Production Workloads red")},
+		{color: "never", want: "Production Workloads red"},
+		// The output of the test is not a terminal, whatever stdout is.
+		{color: "auto", want: "Production Workloads red"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.color, func(t *testing.T) {
+			out, err := run(t, "current", "--prompt", "--format", "{{.Name}} {{.Color}}", "--color", tt.color)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, out)
+		})
+	}
+
+	// Subscriptions without a production tag are never coloured.
+	_, err = run(t, "Fabrikam Production")
+	require.NoError(t, err)
+	out, err := run(t, "current", "--prompt", "--format", "{{.Name}} {{.Production}}", "--color", "always")
+	require.NoError(t, err)
+	assert.Equal(t, "Fabrikam Production false", out)
+}
+
+func TestCurrent_Session(t *testing.T) {
+	dir := newTestHome(t)
+	s, err := storage.NewSession(storage.DefaultSessionRoot(), dir, "")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = s.Remove() })
+	t.Setenv(storage.ConfigDirEnv, s.Dir)
+
+	_, err = run(t, "Fabrikam Production")
+	require.NoError(t, err)
+
+	out, err := run(t, "current", "--format", "{{.Name}} {{.Session}}")
+	require.NoError(t, err)
+	assert.Equal(t, "Fabrikam Production true\n", out)
+	assert.Equal(t, "Production Workloads", defaultIn(t, dir), "the shared profile is untouched")
+}
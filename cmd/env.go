@@ -0,0 +1,111 @@
+/*
+Copyright © 2024 Richard Weston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/shell"
+	"github.com/riweston/aztx/pkg/subscription"
+	"github.com/riweston/aztx/pkg/types"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// envCmd prints a subscription as environment variables
+var envCmd = &cobra.Command{
+	Use:   "env [query]",
+	Short: "Print a subscription as environment variables",
+	Long: `Print the statements that set ARM_SUBSCRIPTION_ID, ARM_TENANT_ID,
+AZURE_SUBSCRIPTION_ID and AZURE_TENANT_ID, read by Terraform's azurerm provider and
+the Azure SDKs, for the subscription matching query or the default subscription.
+The default subscription of the Azure CLI is not changed.
+
+  eval "$(aztx env prod)"
+  aztx env prod --format fish | source
+  aztx env prod --format pwsh | Invoke-Expression
+  aztx env prod --format dotenv > .env`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeSubscription(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		if format == "" {
+			format = viper.GetString("shell")
+		}
+		dialect := shell.Detect()
+		if format != "" {
+			var err error
+			if dialect, err = shell.ParseEnvDialect(format); err != nil {
+				return err
+			}
+		}
+
+		sub, err := contextSubscription(args)
+		if err != nil || sub == nil {
+			return err
+		}
+		vars := shell.ContextVars(*sub)
+		if outputFormat().Structured() {
+			results := make([]envResult, 0, len(vars))
+			for _, v := range vars {
+				results = append(results, envResult{Name: v.Name, Value: v.Value})
+			}
+			return printResult(cmd, results)
+		}
+		_, err = fmt.Fprint(cmd.OutOrStdout(), shell.Export(dialect, vars))
+		return err
+	},
+}
+
+// envResult is an environment variable in structured output.
+type envResult struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// contextSubscription resolves the subscription of a command that works with a
+// context without switching to it: the one matching the query when one is given and
+// the default subscription otherwise. It returns nil without an error when the finder
+// is aborted.
+func contextSubscription(args []string) (*types.Subscription, error) {
+	if len(args) > 0 {
+		return pickSubscription(args)
+	}
+	fa, err := newProfileStorage()
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := fa.ReadConfig()
+	if err != nil {
+		return nil, pkgerrors.ErrReadingConfiguration(err)
+	}
+	subManager := subscription.Manager{BaseManager: types.BaseManager{Configuration: cfg}}
+	return subManager.DefaultSubscription()
+}
+
+func init() {
+	rootCmd.AddCommand(envCmd)
+	envCmd.Flags().StringP("format", "f", "", "Format: sh, bash, zsh, fish, pwsh or dotenv (defaults to $SHELL)")
+	registerFlagCompletion(envCmd, "format", cobra.FixedCompletions([]string{"sh", "bash", "zsh", "fish", "pwsh", "dotenv"}, cobra.ShellCompDirectiveNoFileComp))
+}
@@ -0,0 +1,139 @@
+/*
+Copyright © 2024 Richard Weston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/profile"
+	"github.com/riweston/aztx/pkg/shell"
+	"github.com/riweston/aztx/pkg/storage"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// execCmd runs a command in the context of a subscription
+var execCmd = &cobra.Command{
+	Use:   "exec <query> -- <command> [args...]",
+	Short: "Run a command with a subscription, leaving the default untouched",
+	Long: `Run a command with the subscription matching query, without changing the default
+subscription of the Azure CLI for anything else. The command gets the variables
+printed by "aztx env" and an AZURE_CONFIG_DIR holding a private copy of the Azure
+profile in which that subscription is the default, so that az, Terraform and the
+Azure SDKs all use it.
+
+  aztx exec prod -- terraform plan
+  aztx exec dev -- az group list
+
+aztx exits with the exit status of the command.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if cmd.ArgsLenAtDash() != 1 || len(args) < 2 {
+			return pkgerrors.ErrExecUsage
+		}
+		return nil
+	},
+	ValidArgsFunction: completeSubscription(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sub, err := pickSubscription(args[:1])
+		if err != nil || sub == nil {
+			return err
+		}
+		if sub.Protected {
+			if err := newConfirmGuard(cmd).Approve(*sub); err != nil {
+				return err
+			}
+		}
+
+		fa, err := newProfileStorage()
+		if err != nil {
+			return err
+		}
+		base := fa.ConfigDir()
+		if overlay, err := storage.OpenSession(base); err == nil {
+			base = overlay.Base
+		}
+		session, err := storage.NewSession(storage.DefaultSessionRoot(), base, "")
+		if err != nil {
+			return err
+		}
+		defer session.Remove()
+
+		overlay := newProfileStorageIn(session.Dir)
+		overlay.Backups = 0
+		logger := profile.NewLogger(viper.GetString("log-level"))
+		logger.SetOutput(io.Discard)
+		if err := profile.NewConfigurationAdapter(overlay, logger).SetContext(sub.Identity()); err != nil {
+			return pkgerrors.ErrOperation("setting context", err)
+		}
+
+		child := exec.Command(args[1], args[2:]...)
+		child.Stdin, child.Stdout, child.Stderr = os.Stdin, os.Stdout, os.Stderr
+		child.Env = append(os.Environ(), storage.ConfigDirEnv+"="+session.Dir)
+		for _, v := range shell.ContextVars(*sub) {
+			child.Env = append(child.Env, v.Name+"="+v.Value)
+		}
+
+		// The command gets interrupts from the terminal itself; aztx waits for it to
+		// exit so that the private profile is removed afterwards.
+		interrupts := make(chan os.Signal, 1)
+		signal.Notify(interrupts, os.Interrupt)
+		defer signal.Stop(interrupts)
+
+		if err := child.Run(); err != nil {
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				return exitStatus(exitErr.ExitCode())
+			}
+			return pkgerrors.ErrOperation("running "+args[1], err)
+		}
+		return nil
+	},
+}
+
+// exitStatus is returned when a command run by aztx fails, so that aztx exits with
+// the same status without reporting an error of its own.
+type exitStatus int
+
+func (e exitStatus) Error() string {
+	return fmt.Sprintf("command exited with status %d", int(e))
+}
+
+// ExitCode returns the status aztx exits with after err: the status of the command
+// it ran, or 1.
+func ExitCode(err error) int {
+	var status exitStatus
+	if errors.As(err, &status) && status > 0 {
+		return int(status)
+	}
+	return 1
+}
+
+func init() {
+	rootCmd.AddCommand(execCmd)
+}
@@ -0,0 +1,139 @@
+/*
+Copyright © 2024 Richard Weston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/metadata"
+
+	"github.com/spf13/cobra"
+)
+
+// favCmd manages the subscriptions pinned to the top of the finder
+var favCmd = &cobra.Command{
+	Use:   "fav",
+	Short: "Manage favorite subscriptions",
+	Long: `Mark subscriptions as favorites so that they are listed first, with a star, in the
+fuzzy finder. Favorites are kept in aztx's metadata file and never written to the Azure profile.`,
+}
+
+var favAddCmd = &cobra.Command{
+	Use:   "add [query]",
+	Short: "Mark a subscription as a favorite",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setFavorite(cmd, args, true)
+	},
+}
+
+var favRmCmd = &cobra.Command{
+	Use:     "rm [query]",
+	Aliases: []string{"remove"},
+	Short:   "Unmark a favorite subscription",
+	Args:    cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return setFavorite(cmd, args, false)
+	},
+}
+
+var favListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List favorite subscriptions",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := newMetadataStore()
+		if err != nil {
+			return err
+		}
+		kept, err := store.Load()
+		if err != nil {
+			return pkgerrors.ErrOperation("reading metadata", err)
+		}
+
+		subs := subscriptionNames()
+		if outputFormat().Structured() {
+			results := make([]favoriteResult, 0)
+			for _, id := range kept.Favorites() {
+				results = append(results, favoriteResult{SubscriptionID: id, SubscriptionName: subs[id], Favorite: true})
+			}
+			return printResult(cmd, results)
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SUBSCRIPTION\tID")
+		for _, id := range kept.Favorites() {
+			name, ok := subs[id]
+			if !ok {
+				name = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\n", name, id)
+		}
+		return w.Flush()
+	},
+}
+
+// setFavorite marks or unmarks the subscription matching args, or the one chosen with
+// the fuzzy finder when no query is given.
+func setFavorite(cmd *cobra.Command, args []string, favorite bool) error {
+	store, err := newMetadataStore()
+	if err != nil {
+		return err
+	}
+	sub, err := pickSubscription(args)
+	if err != nil || sub == nil {
+		return err
+	}
+
+	err = store.Update(func(m *metadata.Metadata) error {
+		m.SetFavorite(sub.ID.String(), favorite)
+		return nil
+	})
+	if err != nil {
+		return pkgerrors.ErrOperation("saving favorite", err)
+	}
+
+	logger := newLogger()
+	if favorite {
+		logger.Success("Added %s (%s) to favorites", sub.Name, sub.ID)
+	} else {
+		logger.Success("Removed %s (%s) from favorites", sub.Name, sub.ID)
+	}
+	return printResult(cmd, favoriteResult{SubscriptionID: sub.ID.String(), SubscriptionName: sub.Name, Favorite: favorite})
+}
+
+// favoriteResult is a subscription's favorite flag in structured output.
+type favoriteResult struct {
+	SubscriptionID   string `json:"subscriptionId"`
+	SubscriptionName string `json:"subscriptionName"`
+	Favorite         bool   `json:"favorite"`
+}
+
+func init() {
+	rootCmd.AddCommand(favCmd)
+	favCmd.AddCommand(favAddCmd, favRmCmd, favListCmd)
+	favAddCmd.ValidArgsFunction = completeSubscription(0)
+	favRmCmd.ValidArgsFunction = completeSubscription(0)
+}
@@ -0,0 +1,152 @@
+/*
+Copyright © 2024 Richard Weston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/ktr0731/go-fuzzyfinder"
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/finder"
+	"github.com/riweston/aztx/pkg/state"
+
+	"github.com/spf13/cobra"
+)
+
+// historyCmd lists the contexts recorded when switching and lets the user jump back to one
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List previous contexts and switch back to one",
+	Long: `List the contexts you switched away from, most recent first. The number in the
+first column can be passed as -N to jump back directly, e.g. "aztx -2".
+Use --pick to choose an entry with the fuzzy finder and switch to it.
+The number of entries kept is controlled by "history-size" in ~/.aztx.yml, or by
+AZTX_HISTORY_SIZE.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := newLogger()
+		stateManager := newStateManager()
+		history := stateManager.History()
+		pick, _ := cmd.Flags().GetBool("pick")
+		if !pick && outputFormat().Structured() {
+			results := make([]historyResult, 0, len(history))
+			for i, entry := range history {
+				results = append(results, historyResult{
+					Steps:            i + 1,
+					Timestamp:        entry.Timestamp,
+					SubscriptionID:   entry.SubscriptionID,
+					SubscriptionName: entry.SubscriptionName,
+					TenantID:         entry.TenantID,
+					User:             entry.User,
+					UserType:         entry.UserType,
+					ConfigDir:        entry.ConfigDir,
+					Command:          entry.Command,
+				})
+			}
+			return printResult(cmd, results)
+		}
+		if len(history) == 0 {
+			return pkgerrors.ErrNoPreviousContext
+		}
+
+		if !pick {
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "#\tWHEN\tSUBSCRIPTION\tUSER\tTENANT\tCOMMAND")
+			for i, entry := range history {
+				user := entry.User
+				if user == "" {
+					user = "-"
+				}
+				fmt.Fprintf(w, "-%d\t%s\t%s\t%s\t%s\t%s\n", i+1, formatHistoryTime(entry),
+					historyLabel(entry), user, entry.TenantID, entry.Command)
+			}
+			return w.Flush()
+		}
+
+		indexed := make([]int, len(history))
+		for i := range indexed {
+			indexed[i] = i
+		}
+		selected, err := finder.Fuzzy(indexed, func(i int) string {
+			label := fmt.Sprintf("-%d  %s  %s", i+1, formatHistoryTime(history[i]), historyLabel(history[i]))
+			if history[i].User != "" {
+				label += " · " + history[i].User
+			}
+			return label
+		})
+		if err != nil {
+			if errors.Is(err, fuzzyfinder.ErrAbort) {
+				return nil
+			}
+			return pkgerrors.ErrOperation("selecting history entry", err)
+		}
+
+		fa, err := newProfileStorage()
+		if err != nil {
+			return err
+		}
+		fa = historyStorage(fa, stateManager, fmt.Sprintf("-%d", *selected+1))
+		storage, err := withMetadata(fa)
+		if err != nil {
+			return err
+		}
+		adapter := newSwitchAdapter(cmd, storage, logger)
+		if err := adapter.SetHistoryContext(stateManager, *selected+1, "aztx history"); err != nil {
+			return pkgerrors.ErrSettingPreviousContext(err)
+		}
+		return reportSwitch(cmd, adapter, "aztx history")
+	},
+}
+
+// historyResult is a history entry in structured output. Steps is the N of aztx -N.
+type historyResult struct {
+	Steps            int       `json:"steps"`
+	Timestamp        time.Time `json:"timestamp"`
+	SubscriptionID   string    `json:"subscriptionId"`
+	SubscriptionName string    `json:"subscriptionName"`
+	TenantID         string    `json:"tenantId"`
+	User             string    `json:"user"`
+	UserType         string    `json:"userType"`
+	ConfigDir        string    `json:"configDir"`
+	Command          string    `json:"command"`
+}
+
+// formatHistoryTime renders the time a history entry was recorded in local time.
+func formatHistoryTime(entry state.HistoryEntry) string {
+	if entry.Timestamp.IsZero() {
+		return "-"
+	}
+	return entry.Timestamp.Local().Format("2006-01-02 15:04:05")
+}
+
+// historyLabel renders the subscription of a history entry like the finder does.
+func historyLabel(entry state.HistoryEntry) string {
+	return fmt.Sprintf("%s (%s)", entry.SubscriptionName, entry.SubscriptionID)
+}
+
+func init() {
+	rootCmd.AddCommand(historyCmd)
+	historyCmd.Flags().BoolP("pick", "p", false, "Pick an entry with the fuzzy finder and switch to it")
+}
@@ -0,0 +1,106 @@
+/*
+Copyright © 2024 Richard Weston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/shell"
+
+	"github.com/spf13/cobra"
+)
+
+// initCmd prints the shell integration script
+var initCmd = &cobra.Command{
+	Use:   "init <bash | zsh | fish | pwsh>",
+	Short: "Print the shell integration script",
+	Long: `Print a script that integrates aztx with your shell. It defines an aztx function
+that lets aztx change the calling shell, so that "aztx --session" and "aztx session end"
+work without eval, and registers completion for aztx.
+
+  bash:       eval "$(aztx init bash)"                      in ~/.bashrc
+  zsh:        eval "$(aztx init zsh)"                       in ~/.zshrc, after compinit
+  fish:       aztx init fish | source                       in ~/.config/fish/config.fish
+  PowerShell: aztx init pwsh | Out-String | Invoke-Expression   in $PROFILE
+
+With --prompt the active subscription is shown in front of the prompt, with
+--session every switch only changes the current shell, and with --pin entering a
+directory pinned by a .aztx file switches to its subscription, see "aztx pin".
+With --idle-revert every prompt offers to switch back from a protected subscription
+that sat idle longer than protected-idle-timeout, see "aztx protect check".`,
+	Args:      cobra.ExactArgs(1),
+	ValidArgs: shell.Shells,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := shell.InitOptions{}
+		opts.Prompt, _ = cmd.Flags().GetBool("prompt")
+		opts.Session, _ = cmd.Flags().GetBool("session")
+		opts.Pin, _ = cmd.Flags().GetBool("pin")
+		opts.IdleRevert, _ = cmd.Flags().GetBool("idle-revert")
+		if completion, _ := cmd.Flags().GetBool("completion"); completion {
+			var err error
+			if opts.Completion, err = completionScript(args[0]); err != nil {
+				return err
+			}
+		}
+
+		script, err := shell.Init(args[0], opts)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprint(cmd.OutOrStdout(), script)
+		return err
+	},
+}
+
+// completionScript returns cobra's completion script for the named shell.
+func completionScript(name string) (string, error) {
+	var b bytes.Buffer
+	var err error
+	switch strings.ToLower(name) {
+	case "bash":
+		err = rootCmd.GenBashCompletionV2(&b, true)
+	case "zsh":
+		err = rootCmd.GenZshCompletion(&b)
+	case "fish":
+		err = rootCmd.GenFishCompletion(&b, true)
+	case "pwsh", "powershell":
+		err = rootCmd.GenPowerShellCompletionWithDesc(&b)
+	default:
+		return "", pkgerrors.ErrInvalidShell(name)
+	}
+	if err != nil {
+		return "", pkgerrors.ErrOperation("generating completion", err)
+	}
+	return b.String(), nil
+}
+
+func init() {
+	rootCmd.AddCommand(initCmd)
+	initCmd.Flags().Bool("prompt", false, "Show the active subscription in the prompt")
+	initCmd.Flags().Bool("session", false, "Make every switch change only the current shell")
+	initCmd.Flags().Bool("pin", false, "Switch to the pinned subscription when changing into a pinned directory")
+	initCmd.Flags().Bool("idle-revert", false, "Offer to switch back from an idle protected subscription before each prompt")
+	initCmd.Flags().Bool("completion", true, "Register shell completion for aztx")
+}
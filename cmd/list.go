@@ -0,0 +1,158 @@
+/*
+Copyright © 2024 Richard Weston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/subscription"
+	"github.com/riweston/aztx/pkg/types"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	listFilter subscription.Filter
+	listSort   []string
+)
+
+// listCmd prints the subscriptions of the active profile
+var listCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List subscriptions",
+	Long: `List the subscriptions of the Azure profile with their state, tenant, user, cloud and
+the tenants managing them. The subscription in use, the default of the cloud the
+Azure CLI targets, is marked with a star. ACCESS shows "lighthouse" for subscriptions
+other tenants manage through Azure Lighthouse and "guest" for those reached as a B2B
+guest of another tenant.
+
+--tenant matches a tenant ID or part of a tenant's custom or account name, --user
+part of the signed-in user, and --state and --cloud the exact value ignoring case.
+--delegated and --guest only list the subscriptions reached those ways; --delegated
+groups them by managing tenant unless --sort is given. --sort takes one or more of
+name, id, state, tenant, user, cloud, default and managedby, separated by commas.
+See "aztx tenant delegations" for the tenants managing each customer tenant.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fa, err := newProfileStorage()
+		if err != nil {
+			return err
+		}
+		storage, err := withMetadata(fa)
+		if err != nil {
+			return err
+		}
+		cfg, err := storage.ReadConfig()
+		if err != nil {
+			return pkgerrors.ErrReadingConfiguration(err)
+		}
+
+		keys := listSort
+		if listFilter.Delegated && !cmd.Flags().Changed("sort") {
+			keys = []string{"managedby", "name"}
+		}
+		subManager := subscription.Manager{BaseManager: types.BaseManager{Configuration: cfg}}
+		subs, err := subManager.List(listFilter, keys...)
+		if err != nil {
+			return err
+		}
+
+		if outputFormat().Structured() {
+			results := make([]listResult, 0, len(subs))
+			for _, sub := range subs {
+				results = append(results, listResult{
+					ID:               sub.ID.String(),
+					Name:             sub.Name,
+					State:            sub.State,
+					TenantID:         sub.TenantID.String(),
+					TenantName:       subManager.TenantName(sub),
+					User:             sub.User.Name,
+					Cloud:            sub.EnvironmentName,
+					IsDefault:        sub.IsDefault,
+					Current:          subManager.IsCurrent(sub),
+					Protected:        sub.Protected,
+					ManagedByTenants: subManager.ManagedBy(sub),
+					Delegated:        subscription.Delegated(sub),
+					Guest:            subscription.Guest(sub),
+				})
+			}
+			return printResult(cmd, results)
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CURRENT\tNAME\tSUBSCRIPTION ID\tSTATE\tTENANT\tUSER\tCLOUD\tMANAGED BY\tACCESS")
+		for _, sub := range subs {
+			current := ""
+			if subManager.IsCurrent(sub) {
+				current = "*"
+			}
+			managedBy := strings.Join(subManager.ManagedBy(sub), ",")
+			if managedBy == "" {
+				managedBy = "-"
+			}
+			access := strings.Join(subscription.Access(sub), ",")
+			if access == "" {
+				access = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", current, sub.Name, sub.ID, sub.State,
+				subManager.TenantName(sub), sub.User.Name, sub.EnvironmentName, managedBy, access)
+		}
+		return w.Flush()
+	},
+}
+
+// listResult is a subscription listed by "aztx list" in structured output.
+type listResult struct {
+	ID               string   `json:"id"`
+	Name             string   `json:"name"`
+	State            string   `json:"state"`
+	TenantID         string   `json:"tenantId"`
+	TenantName       string   `json:"tenantName"`
+	User             string   `json:"user"`
+	Cloud            string   `json:"cloud"`
+	IsDefault        bool     `json:"isDefault"`
+	Current          bool     `json:"current"`
+	Protected        bool     `json:"protected"`
+	ManagedByTenants []string `json:"managedByTenants"`
+	Delegated        bool     `json:"delegated"`
+	Guest            bool     `json:"guest"`
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+	listCmd.Flags().StringVar(&listFilter.Tenant, "tenant", "", "Only list subscriptions of a tenant (ID or name)")
+	listCmd.Flags().StringVar(&listFilter.State, "state", "", "Only list subscriptions in a state, e.g. Enabled")
+	listCmd.Flags().StringVar(&listFilter.User, "user", "", "Only list subscriptions of a signed-in user")
+	listCmd.Flags().StringVar(&listFilter.Cloud, "cloud", "", "Only list subscriptions of an Azure cloud, e.g. AzureCloud")
+	listCmd.Flags().BoolVar(&listFilter.Delegated, "delegated", false, "Only list subscriptions managed by other tenants through Azure Lighthouse")
+	listCmd.Flags().BoolVar(&listFilter.Guest, "guest", false, "Only list subscriptions reached as a B2B guest of another tenant")
+	listCmd.Flags().StringSliceVar(&listSort, "sort", []string{"name"}, "Sort by name, id, state, tenant, user, cloud, default or managedby")
+	registerFlagCompletion(listCmd, "tenant", completeTenant)
+	registerFlagCompletion(listCmd, "state", completeField(func(s types.Subscription) string { return s.State }))
+	registerFlagCompletion(listCmd, "user", completeField(func(s types.Subscription) string { return s.User.Name }))
+	registerFlagCompletion(listCmd, "cloud", completeField(func(s types.Subscription) string { return s.EnvironmentName }))
+	registerFlagCompletion(listCmd, "sort", completeSortKeys(subscription.SortKeys))
+}
@@ -0,0 +1,154 @@
+/*
+Copyright © 2024 Richard Weston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"github.com/riweston/aztx/pkg/output"
+	"github.com/riweston/aztx/pkg/profile"
+	"github.com/riweston/aztx/pkg/types"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// started is when the command began, reported as the elapsed time of structured results.
+var started = time.Now()
+
+// outputFormat returns the format selected with --output. An invalid format is
+// rejected before any command runs, so it falls back to text here.
+func outputFormat() output.Format {
+	format, err := output.ParseFormat(viper.GetString("output"))
+	if err != nil {
+		return output.Text
+	}
+	return format
+}
+
+// newLogger returns the logger for the selected log level. With a structured output
+// format, info and success messages are dropped so that stdout only carries the result.
+// While a session is switched they go to stderr, as stdout carries the shell code.
+func newLogger() profile.Logger {
+	logger := profile.NewLogger(viper.GetString("log-level"))
+	if outputFormat().Structured() {
+		logger.SetOutput(io.Discard)
+	} else if activeSession != nil {
+		logger.SetOutput(os.Stderr)
+	}
+	return logger
+}
+
+// printResult writes the result of a command in a structured output format. In text
+// mode it does nothing, as commands report to people through the logger.
+func printResult(cmd *cobra.Command, v interface{}) error {
+	format := outputFormat()
+	if !format.Structured() {
+		return nil
+	}
+	return output.Write(cmd.OutOrStdout(), format, v)
+}
+
+// PrintError reports an error returned by Execute in the selected output format.
+// The failure of a command run by aztx exec is left to the command to report.
+func PrintError(err error) {
+	var status exitStatus
+	if errors.As(err, &status) {
+		return
+	}
+	_ = output.WriteError(os.Stderr, outputFormat(), err)
+}
+
+// subscriptionResult is a subscription in structured output.
+type subscriptionResult struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	TenantID string `json:"tenantId"`
+	User     string `json:"user"`
+	UserType string `json:"userType"`
+	Cloud    string `json:"cloud"`
+}
+
+// newSubscriptionResult returns sub as it is shown in structured output.
+func newSubscriptionResult(sub types.Subscription) subscriptionResult {
+	return subscriptionResult{
+		ID:       sub.ID.String(),
+		Name:     sub.Name,
+		TenantID: sub.TenantID.String(),
+		User:     sub.User.Name,
+		UserType: sub.User.Type,
+		Cloud:    sub.EnvironmentName,
+	}
+}
+
+// tenantResult is a tenant in structured output.
+type tenantResult struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	CustomName string `json:"customName"`
+}
+
+// newTenantResult returns t as it is shown in structured output.
+func newTenantResult(t types.Tenant) tenantResult {
+	return tenantResult{
+		ID:         t.ID.String(),
+		Name:       t.Name,
+		CustomName: t.CustomName,
+	}
+}
+
+// switchResult is the structured result of a context switch.
+type switchResult struct {
+	Subscription subscriptionResult  `json:"subscription"`
+	Tenant       tenantResult        `json:"tenant"`
+	Previous     *subscriptionResult `json:"previous"`
+	CloudChanged bool                `json:"cloudChanged"`
+	Command      string              `json:"command"`
+	ElapsedMs    int64               `json:"elapsedMs"`
+}
+
+// reportSwitch writes the switch made through adapter in a structured output format,
+// or as shell code while a session is switched.
+func reportSwitch(cmd *cobra.Command, adapter *profile.ConfigurationAdapter, command string) error {
+	if activeSession != nil {
+		return exportSession(cmd, adapter)
+	}
+	last := adapter.LastSwitch()
+	if last == nil {
+		return nil
+	}
+	result := switchResult{
+		Subscription: newSubscriptionResult(last.Subscription),
+		Tenant:       newTenantResult(last.Tenant),
+		CloudChanged: last.CloudChanged,
+		Command:      command,
+		ElapsedMs:    time.Since(started).Milliseconds(),
+	}
+	if last.Previous != nil {
+		previous := newSubscriptionResult(*last.Previous)
+		result.Previous = &previous
+	}
+	return printResult(cmd, result)
+}
@@ -0,0 +1,281 @@
+/*
+Copyright © 2024 Richard Weston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/finder"
+	"github.com/riweston/aztx/pkg/pin"
+	"github.com/riweston/aztx/pkg/profile"
+	"github.com/riweston/aztx/pkg/state"
+	"github.com/riweston/aztx/pkg/subscription"
+	"github.com/riweston/aztx/pkg/types"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// pinCmd groups the commands that manage directory pins
+var pinCmd = &cobra.Command{
+	Use:   "pin",
+	Short: "Pin a directory to a subscription",
+	Long: `A .aztx file pins the directory it is in, and every directory below it, to a
+subscription. It holds a subscription ID, name or alias, or a YAML mapping that also
+names the tenant, to tell subscriptions with the same name apart:
+
+  subscription: Shared Services
+  tenant: Contoso
+
+The nearest .aztx or .aztx.yml file in the working directory or its parents applies.
+Running aztx without arguments in a pinned directory offers to switch to the pinned
+subscription, "aztx current --check" fails when it is not active, and the shell
+integration from "aztx init --pin" switches to it on every cd.`,
+}
+
+var pinSetCmd = &cobra.Command{
+	Use:   "set [query]",
+	Short: "Pin the working directory to a subscription",
+	Long: `Write a .aztx file to the working directory pinning it to the subscription matching
+query, or to the default subscription. The subscription ID is written, as aliases
+are only known to the machine they were set on.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeSubscription(0),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sub, err := contextSubscription(args)
+		if err != nil || sub == nil {
+			return err
+		}
+		dir, err := os.Getwd()
+		if err != nil {
+			return pkgerrors.ErrFileOperation("fetching working directory", err)
+		}
+		path, err := pin.Write(dir, pin.Pin{Subscription: sub.ID.String()})
+		if err != nil {
+			return err
+		}
+		if outputFormat().Structured() {
+			return printResult(cmd, pinResult{Path: path, Subscription: sub.ID.String(), Resolved: newSubscriptionResult(*sub)})
+		}
+		newLogger().Success("Pinned %s to %s", dir, subscription.Label(*sub))
+		return nil
+	},
+}
+
+var pinShowCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Print the pin of the working directory",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := findPin()
+		if err != nil {
+			return err
+		}
+		if p == nil {
+			return pkgerrors.ErrNoPin
+		}
+		cfg, err := readProfileConfig()
+		if err != nil {
+			return err
+		}
+		subManager := subscription.Manager{BaseManager: types.BaseManager{Configuration: cfg}}
+		sub, err := p.Resolve(&subManager)
+		if err != nil {
+			return err
+		}
+
+		if outputFormat().Structured() {
+			return printResult(cmd, pinResult{
+				Path:         p.Path,
+				Subscription: p.Subscription,
+				Tenant:       p.Tenant,
+				Resolved:     newSubscriptionResult(*sub),
+				Active:       subManager.IsCurrent(*sub),
+			})
+		}
+		status := "not active"
+		if subManager.IsCurrent(*sub) {
+			status = "active"
+		}
+		_, err = fmt.Fprintf(cmd.OutOrStdout(), "%s\t%s\t%s\n", p.Path, subscription.Label(*sub), status)
+		return err
+	},
+}
+
+var pinApplyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Switch to the pinned subscription",
+	Long: `Switch to the subscription the working directory is pinned to, unless it is already
+the default. Nothing happens outside a pinned directory, which lets the shell
+integration run it on every change of directory.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		p, err := findPin()
+		if err != nil || p == nil {
+			return err
+		}
+
+		fa, err := newProfileStorage()
+		if err != nil {
+			return err
+		}
+		if viper.GetBool("session") {
+			if fa, err = startSession(cmd, fa); err != nil {
+				return err
+			}
+			defer endSession()
+		}
+		storage, err := withMetadata(fa)
+		if err != nil {
+			return err
+		}
+		cfg, err := storage.ReadConfig()
+		if err != nil {
+			return pkgerrors.ErrReadingConfiguration(err)
+		}
+		subManager := subscription.Manager{BaseManager: types.BaseManager{Configuration: cfg}}
+		sub, err := p.Resolve(&subManager)
+		if err != nil || subManager.IsCurrent(*sub) {
+			return err
+		}
+
+		adapter := newSwitchAdapter(cmd, storage, newLogger()).WithHistory(newStateManager(), "aztx pin apply")
+		if err := adapter.SetContext(sub.Identity()); err != nil {
+			return pkgerrors.ErrOperation("setting context", err)
+		}
+		return reportSwitch(cmd, adapter, "aztx pin apply")
+	},
+}
+
+// pinResult is a pin in structured output.
+type pinResult struct {
+	Path         string             `json:"path"`
+	Subscription string             `json:"subscription"`
+	Tenant       string             `json:"tenant,omitempty"`
+	Resolved     subscriptionResult `json:"resolved"`
+	Active       bool               `json:"active"`
+}
+
+// findPin returns the pin of the working directory, or nil when it is not pinned.
+// ~/.aztx.yml is the configuration of aztx, not a pin, so it is skipped.
+func findPin() (*pin.Pin, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return nil, pkgerrors.ErrFileOperation("fetching working directory", err)
+	}
+	return pin.Find(dir, configFile)
+}
+
+// checkPin verifies that the default subscription is the one the working directory is
+// pinned to. A directory that is not pinned passes, and so does one whose pin matches
+// the default among the entries of several accounts.
+func checkPin() error {
+	p, err := findPin()
+	if err != nil || p == nil {
+		return err
+	}
+	cfg, err := readProfileConfig()
+	if err != nil {
+		return err
+	}
+	subManager := subscription.Manager{BaseManager: types.BaseManager{Configuration: cfg}}
+	pinned, err := p.Resolve(&subManager)
+	if err != nil {
+		return err
+	}
+	if subManager.IsCurrent(*pinned) {
+		return nil
+	}
+	active := "no subscription"
+	if sub, err := subManager.DefaultSubscription(); err == nil {
+		active = subscription.Label(*sub)
+	}
+	return pkgerrors.ErrPinDiffers(active, p.Path, subscription.Label(*pinned))
+}
+
+// offerPin asks whether to switch to the pinned subscription when aztx is run without
+// arguments in a pinned directory whose pin is not the default. It reports whether the
+// switch was made; when it was declined, or there is no terminal to ask on, the finder
+// opens as usual.
+func offerPin(cmd *cobra.Command, storage profile.StorageAdapter, logger profile.Logger, sm state.StateManager) (bool, error) {
+	if !finder.IsInteractive() {
+		return false, nil
+	}
+	p, err := findPin()
+	if err != nil {
+		logger.Warn("ignoring pin: %v", err)
+		return false, nil
+	}
+	if p == nil {
+		return false, nil
+	}
+	cfg, err := storage.ReadConfig()
+	if err != nil {
+		return false, pkgerrors.ErrReadingConfiguration(err)
+	}
+	subManager := subscription.Manager{BaseManager: types.BaseManager{Configuration: cfg}}
+	sub, err := p.Resolve(&subManager)
+	if err != nil {
+		logger.Warn("ignoring pin %s: %v", p.Path, err)
+		return false, nil
+	}
+	if subManager.IsCurrent(*sub) || !confirm(fmt.Sprintf("Switch to %s, pinned by %s?", subscription.Label(*sub), p.Path), true) {
+		return false, nil
+	}
+
+	adapter := newSwitchAdapter(cmd, storage, logger).WithHistory(sm, "aztx (pin)")
+	if err := adapter.SetContext(sub.Identity()); err != nil {
+		return false, pkgerrors.ErrOperation("setting context", err)
+	}
+	return true, reportSwitch(cmd, adapter, "aztx (pin)")
+}
+
+// confirm asks a yes or no question on the terminal and returns the answer, or def
+// when the answer is empty.
+func confirm(question string, def bool) bool {
+	choices := "[y/N]"
+	if def {
+		choices = "[Y/n]"
+	}
+	answer, ok := ask(question + " " + choices)
+	if !ok {
+		return false
+	}
+	switch strings.ToLower(answer) {
+	case "":
+		return def
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(pinCmd)
+	pinCmd.AddCommand(pinSetCmd)
+	pinCmd.AddCommand(pinShowCmd)
+	pinCmd.AddCommand(pinApplyCmd)
+}
@@ -0,0 +1,243 @@
+/*
+Copyright © 2024 Richard Weston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/profile"
+	"github.com/riweston/aztx/pkg/protect"
+	"github.com/riweston/aztx/pkg/state"
+	"github.com/riweston/aztx/pkg/subscription"
+	"github.com/riweston/aztx/pkg/types"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+// protectedBanner is printed in white on red before switching into a protected subscription.
+const protectedBanner = "\033[1;97;41m %s \033[0m\n"
+
+// activityResolution is how stale the recorded use of a protected subscription may
+// get before it is written again, so that prompts do not rewrite ~/.aztx.yml each time.
+const activityResolution = time.Minute
+
+// protectRules returns the protected rules from ~/.aztx.yml.
+func protectRules() (protect.Rules, error) {
+	var rules protect.Rules
+	if err := viper.UnmarshalKey("protected", &rules); err != nil {
+		return nil, pkgerrors.ErrOperation("reading protected rules", err)
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// newSwitchAdapter returns a configuration adapter for switching through storage that
+// asks for confirmation before switching into a protected subscription.
+func newSwitchAdapter(cmd *cobra.Command, storage profile.StorageAdapter, logger profile.Logger) *profile.ConfigurationAdapter {
+	return profile.NewConfigurationAdapter(storage, logger).WithGuard(newConfirmGuard(cmd))
+}
+
+// newConfirmGuard returns a confirmGuard holding the name passed to cmd with --confirm,
+// or set in AZTX_CONFIRM. Neither is read through viper, so that a confirmation meant
+// for one invocation can never be saved to ~/.aztx.yml and approve every later switch.
+func newConfirmGuard(cmd *cobra.Command) confirmGuard {
+	typed, _ := cmd.Flags().GetString("confirm")
+	if typed == "" {
+		typed = os.Getenv("AZTX_CONFIRM")
+	}
+	return confirmGuard{typed: typed}
+}
+
+// confirmGuard approves a switch into a protected subscription when the user types its
+// name, or passed it with --confirm. The use of the subscription is recorded in state,
+// which protected-idle-timeout is measured from.
+type confirmGuard struct {
+	typed string // name passed with --confirm, asked for when empty
+}
+
+func (g confirmGuard) Approve(sub types.Subscription) error {
+	text := fmt.Sprintf("PROTECTED  %s", subscription.Label(sub))
+	if term.IsTerminal(int(os.Stderr.Fd())) {
+		fmt.Fprintf(os.Stderr, protectedBanner, text)
+	} else {
+		fmt.Fprintln(os.Stderr, text)
+	}
+
+	typed := g.typed
+	if typed == "" {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return pkgerrors.ErrNeedsConfirmation(sub.Name)
+		}
+		typed, _ = ask("Type the subscription name to switch to it:")
+	}
+	typed = strings.TrimSpace(typed)
+	if typed != sub.Name && !strings.EqualFold(typed, sub.ID.String()) {
+		return pkgerrors.ErrConfirmationFailed
+	}
+
+	if err := newStateManager().SetProtectedActivity(time.Now()); err != nil {
+		return pkgerrors.WrapError("recording protected activity", err)
+	}
+	return nil
+}
+
+// protectCmd groups the commands about protected subscriptions
+var protectCmd = &cobra.Command{
+	Use:   "protect",
+	Short: "Guard rails for protected subscriptions",
+	Long: `Subscriptions matched by the protected rules in ~/.aztx.yml are marked with ⚠ in the
+finder, and switching to one prints a red banner and asks for its name to be typed,
+or passed with --confirm.`,
+}
+
+var protectCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Offer to switch back from a protected subscription left idle",
+	Long: `When protected-idle-timeout is set in ~/.aztx.yml and a protected subscription has
+been the default for that long since aztx protect check last ran, offer to switch
+back to safe-context, or to the most recent unprotected context in the history.
+Without a terminal to ask on, it only warns unless --yes is given.
+
+The shell integration from "aztx init --idle-revert" runs it before every prompt, so
+the timeout measures how long the shell sat idle. A long-running command counts as
+idle too, which is why it asks before switching. Sessions are left alone.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		yes, _ := cmd.Flags().GetBool("yes")
+		return revertIdleProtected(yes)
+	},
+}
+
+// revertIdleProtected offers to switch back to a safe context when a protected
+// subscription has been the default for longer than protected-idle-timeout since aztx
+// last saw it in use. It switches without asking when yes is set. Sessions are left
+// alone, as their environment would still name the subscription.
+func revertIdleProtected(yes bool) error {
+	timeout := viper.GetDuration("protected-idle-timeout")
+	if timeout <= 0 {
+		return nil
+	}
+	fa, err := newProfileStorage()
+	if err != nil || isSessionOverlay(fa.ConfigDir()) {
+		return err
+	}
+	cfg, err := readProfileConfig()
+	if err != nil {
+		return err
+	}
+
+	sm := newStateManager()
+	last, now := sm.ProtectedActivity(), time.Now()
+	subManager := subscription.Manager{BaseManager: types.BaseManager{Configuration: cfg}}
+	current, err := subManager.DefaultSubscription()
+	if err != nil || !current.Protected {
+		if last.IsZero() {
+			return nil
+		}
+		return sm.SetProtectedActivity(time.Time{})
+	}
+	if last.IsZero() || now.Sub(last) < timeout {
+		if now.Sub(last) < activityResolution {
+			return nil
+		}
+		return sm.SetProtectedActivity(now)
+	}
+
+	logger := profile.NewLogger(viper.GetString("log-level"))
+	logger.SetOutput(os.Stderr)
+	target := safeContext(&subManager, sm)
+	if target == nil {
+		logger.Warn("%s is protected and has been idle for %s, but there is no safe context to switch back to; set safe-context in ~/.aztx.yml", current.Name, timeout)
+		return sm.SetProtectedActivity(now)
+	}
+
+	if !yes {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			logger.Warn("%s is protected and has been idle for %s, pass --yes to switch back to %s", current.Name, timeout, target.Name)
+			return sm.SetProtectedActivity(now)
+		}
+		if !confirm(fmt.Sprintf("%s is protected and has been idle for %s. Switch back to %s?", current.Name, timeout, target.Name), true) {
+			return sm.SetProtectedActivity(now)
+		}
+	}
+
+	storage, err := withMetadata(fa)
+	if err != nil {
+		return err
+	}
+	adapter := profile.NewConfigurationAdapter(storage, logger).WithHistory(sm, "aztx (idle)")
+	if err := adapter.SetContext(target.Identity()); err != nil {
+		return pkgerrors.ErrOperation("setting context", err)
+	}
+	logger.Warn("%s was idle for %s, switched back to %s", current.Name, timeout, target.Name)
+	return sm.SetProtectedActivity(time.Time{})
+}
+
+// safeContext returns the subscription to switch back to from an idle protected one:
+// safe-context from ~/.aztx.yml, or the most recent unprotected context in the history.
+func safeContext(subManager *subscription.Manager, sm *state.ViperStateManager) *types.Subscription {
+	if query := viper.GetString("safe-context"); query != "" {
+		if sub, _ := subManager.MatchSubscriptions(query); sub != nil && !sub.Protected {
+			return sub
+		}
+		return nil
+	}
+	for _, entry := range sm.History() {
+		id, err := entry.Identity()
+		if err != nil {
+			continue
+		}
+		for i, sub := range subManager.Configuration.Subscriptions {
+			if id.Matches(sub) && !sub.Protected && !subManager.IsCurrent(sub) {
+				return &subManager.Configuration.Subscriptions[i]
+			}
+		}
+	}
+	return nil
+}
+
+// ask prints question on stderr and returns the line the user answers with. It
+// reports false when stdin ends before an answer is given.
+func ask(question string) (string, bool) {
+	fmt.Fprintf(os.Stderr, "%s ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(os.Stderr)
+		return "", false
+	}
+	return strings.TrimSpace(answer), true
+}
+
+func init() {
+	rootCmd.AddCommand(protectCmd)
+	protectCmd.AddCommand(protectCheckCmd)
+	protectCheckCmd.Flags().BoolP("yes", "y", false, "Switch back without asking")
+}
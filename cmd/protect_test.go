@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newIdleProtectedHome sets up Production Workloads as a protected default that has
+// been idle for longer than protected-idle-timeout. It returns the Azure CLI config
+// dir and the path of ~/.aztx.yml.
+func newIdleProtectedHome(t *testing.T) (string, string) {
+	t.Helper()
+	dir := newTestHome(t)
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+	config := filepath.Join(home, ".aztx.yml")
+	data := fmt.Sprintf(`protected:
+  - name: Production Workloads
+protected-idle-timeout: 30m
+safe-context: Fabrikam Production
+protected-activity: %q
+`, time.Now().Add(-time.Hour).UTC().Format(time.RFC3339))
+	require.NoError(t, os.WriteFile(config, []byte(data), 0644))
+	return dir, config
+}
+
+func TestCurrent_LeavesIdleProtected(t *testing.T) {
+	dir, config := newIdleProtectedHome(t)
+	before, err := os.ReadFile(config)
+	require.NoError(t, err)
+
+	out, err := run(t, "current", "--prompt")
+	require.NoError(t, err)
+	assert.Equal(t, "Production Workloads", out)
+	assert.Equal(t, "Production Workloads", defaultIn(t, dir))
+	after, err := os.ReadFile(config)
+	require.NoError(t, err)
+	assert.Equal(t, string(before), string(after), "aztx current writes nothing")
+}
+
+func TestProtectCheck(t *testing.T) {
+	t.Run("asks without a terminal", func(t *testing.T) {
+		dir, _ := newIdleProtectedHome(t)
+		_, err := run(t, "protect", "check")
+		require.NoError(t, err)
+		assert.Equal(t, "Production Workloads", defaultIn(t, dir), "nothing is switched without an answer")
+	})
+
+	t.Run("yes", func(t *testing.T) {
+		dir, _ := newIdleProtectedHome(t)
+		_, err := run(t, "protect", "check", "--yes")
+		require.NoError(t, err)
+		assert.Equal(t, "Fabrikam Production", defaultIn(t, dir))
+		assert.Equal(t, "aztx (idle)", history(t)[0].Command)
+	})
+}
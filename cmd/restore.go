@@ -0,0 +1,121 @@
+/*
+Copyright © 2024 Richard Weston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ktr0731/go-fuzzyfinder"
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/finder"
+	"github.com/riweston/aztx/pkg/storage"
+
+	"github.com/spf13/cobra"
+)
+
+// restoreCmd lists and restores the backups aztx takes before rewriting the Azure profile
+var restoreCmd = &cobra.Command{
+	Use:   "restore [backup]",
+	Short: "Restore the Azure profile from a backup",
+	Long: `Restore azureProfile.json from one of the timestamped backups aztx takes before
+every write. Without an argument a fuzzy finder lets you pick the backup to restore.
+The number of backups kept is controlled by the "backups" key in ~/.aztx.yml.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := newLogger()
+		fa, err := newProfileStorage()
+		if err != nil {
+			return err
+		}
+
+		if list, _ := cmd.Flags().GetBool("list"); list {
+			backups, err := fa.ListBackups()
+			if err != nil {
+				return pkgerrors.ErrOperation("listing backups", err)
+			}
+			if outputFormat().Structured() {
+				results := make([]backupResult, 0, len(backups))
+				for _, backup := range backups {
+					results = append(results, newBackupResult(backup))
+				}
+				return printResult(cmd, results)
+			}
+			if len(backups) == 0 {
+				logger.Info("no backups found in %s", fa.BackupDir())
+				return nil
+			}
+			for _, backup := range backups {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s  %s\n", backup.Time.Local().Format("2006-01-02 15:04:05"), backup.Name)
+			}
+			return nil
+		}
+
+		var selected *storage.Backup
+		if len(args) > 0 {
+			selected, err = fa.FindBackup(args[0])
+			if err != nil {
+				return pkgerrors.ErrOperation("finding backup", err)
+			}
+		} else {
+			backups, err := fa.ListBackups()
+			if err != nil {
+				return pkgerrors.ErrOperation("listing backups", err)
+			}
+			if len(backups) == 0 {
+				return pkgerrors.ErrBackupNotFound
+			}
+			selected, err = finder.Fuzzy(backups, func(b storage.Backup) string {
+				return fmt.Sprintf("%s (%s)", b.Time.Local().Format("2006-01-02 15:04:05"), b.Name)
+			})
+			if err != nil {
+				if errors.Is(err, fuzzyfinder.ErrAbort) {
+					return nil
+				}
+				return pkgerrors.ErrOperation("selecting backup", err)
+			}
+		}
+
+		if err := fa.Restore(*selected); err != nil {
+			return pkgerrors.ErrOperation("restoring backup", err)
+		}
+		logger.Success("restored %s from backup %s", fa.Path, selected.Name)
+		return printResult(cmd, newBackupResult(*selected))
+	},
+}
+
+// backupResult is a backup in structured output.
+type backupResult struct {
+	Name string    `json:"name"`
+	Path string    `json:"path"`
+	Time time.Time `json:"time"`
+}
+
+func newBackupResult(backup storage.Backup) backupResult {
+	return backupResult{Name: backup.Name, Path: backup.Path, Time: backup.Time}
+}
+
+func init() {
+	rootCmd.AddCommand(restoreCmd)
+	restoreCmd.Flags().Bool("list", false, "List available backups instead of restoring one")
+}
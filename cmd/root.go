@@ -27,11 +27,17 @@ package cmd
 import (
 	"errors"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/ktr0731/go-fuzzyfinder"
 	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/metadata"
+	"github.com/riweston/aztx/pkg/output"
 	"github.com/riweston/aztx/pkg/profile"
+	"github.com/riweston/aztx/pkg/shell"
 	"github.com/riweston/aztx/pkg/state"
 	"github.com/riweston/aztx/pkg/storage"
 	"github.com/riweston/aztx/pkg/subscription"
@@ -44,35 +50,112 @@ import (
 
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
-	Use:   "aztx",
+	Use:   "aztx [query | - | -N]",
 	Short: "Azure Tenant Context Switcher",
 	Long: `aztx is a command line tool that helps you switch between Azure tenants and subscriptions.
-It provides a fuzzy finder interface to select subscriptions and remembers your last context.`,
+It provides a fuzzy finder interface to select subscriptions and remembers your last context.
+
+Pass a subscription ID, name or alias to switch directly. Any other query opens the
+finder with just the subscriptions it partly matches, even when there is only one,
+or lists them when aztx is not running in a terminal. Pass - to return to the previous context,
+or -N to go back N contexts in the history shown by "aztx history". Without a query
+in a directory pinned with a .aztx file, aztx first offers to switch to the pinned
+subscription, see "aztx pin".
+
+With --session only the current shell is switched: aztx prints shell code to eval,
+e.g. eval "$(aztx --session prod)", and the default subscription of every other
+shell is left untouched. See "aztx session".
+
+Subscriptions matched by the protected rules in ~/.aztx.yml are marked with ⚠ in the
+finder, and switching to one prints a red banner and asks for its name to be typed,
+or passed with --confirm.
+
+--cloud only offers the subscriptions of one Azure cloud. Switching to a subscription
+in another cloud than the one the Azure CLI targets also runs the equivalent of
+"az cloud set", and the finder shows the cloud of each subscription when the profile
+spans several.
+
+The profile lists a subscription once for every account signed in to it. The finder
+then shows the account of each entry, and --user picks the account to switch with,
+e.g. aztx --user deploy-sp prod.
+
+Subscriptions other tenants manage through Azure Lighthouse are marked "lighthouse"
+in the finder, and those reached as a B2B guest of another tenant "guest".
+--delegated and --guest only offer those subscriptions.`,
 	Args: cobra.MaximumNArgs(1),
+	// Errors are reported once by main, without repeating the usage text.
+	SilenceErrors: true,
+	SilenceUsage:  true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		_, err := output.ParseFormat(viper.GetString("output"))
+		return err
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		stateManager := state.NewViperStateManager(viper.GetViper())
-		storage := storage.FileAdapter{}
-		if err := storage.FetchDefaultPath("/.azure/azureProfile.json"); err != nil {
-			return pkgerrors.ErrFileOperation("fetching default profile path", err)
+		stateManager := newStateManager()
+		fa, err := newProfileStorage()
+		if err != nil {
+			return err
 		}
-
-		logger := profile.NewLogger(viper.GetString("log-level"))
-		cfg, err := storage.ReadConfig()
+		if len(args) > 0 && (args[0] == "-" || historyJump.MatchString(args[0])) {
+			fa = historyStorage(fa, stateManager, args[0])
+		}
+		inSession := viper.GetBool("session")
+		if inSession {
+			if fa, err = startSession(cmd, fa); err != nil {
+				return err
+			}
+			defer endSession()
+		}
+		storage, err := withMetadata(fa)
 		if err != nil {
-			return pkgerrors.ErrReadingConfiguration(err)
+			return err
+		}
+
+		logger := newLogger()
+		if !inSession && isSessionOverlay(fa.ConfigDir()) {
+			logger.Warn("switching the session of this shell without updating its environment, use eval \"$(aztx --session ...)\" instead")
 		}
 
 		if len(args) > 0 && args[0] == "-" {
-			adapter := profile.NewConfigurationAdapter(&storage, logger)
+			adapter := newSwitchAdapter(cmd, storage, logger)
 			if err := adapter.SetPreviousContext(stateManager); err != nil {
 				return pkgerrors.ErrSettingPreviousContext(err)
 			}
-			return nil
+			return reportSwitch(cmd, adapter, "aztx -")
+		}
+
+		if len(args) > 0 && historyJump.MatchString(args[0]) {
+			steps, _ := strconv.Atoi(args[0][1:])
+			adapter := newSwitchAdapter(cmd, storage, logger)
+			if err := adapter.SetHistoryContext(stateManager, steps, "aztx "+args[0]); err != nil {
+				return pkgerrors.ErrSettingPreviousContext(err)
+			}
+			return reportSwitch(cmd, adapter, "aztx "+args[0])
+		}
+
+		if len(args) > 0 {
+			return switchByQuery(cmd, storage, logger, stateManager, args[0])
+		}
+
+		if !viper.GetBool("by-tenant") && switchFilter == (subscription.Filter{}) {
+			if switched, err := offerPin(cmd, storage, logger, stateManager); switched || err != nil {
+				return err
+			}
 		}
 
 		// Check if tenant selection is requested
 		if viper.GetBool("by-tenant") {
-			tenantManager := tenant.Manager{BaseManager: types.BaseManager{Configuration: cfg}}
+			cfg, err := storage.ReadConfig()
+			if err != nil {
+				return pkgerrors.ErrReadingConfiguration(err)
+			}
+
+			subManager, err := (&subscription.Manager{BaseManager: types.BaseManager{Configuration: cfg}}).Narrow(switchFilter)
+			if err != nil {
+				return err
+			}
+
+			tenantManager := tenant.Manager{BaseManager: subManager.BaseManager}
 			selectedTenant, err := tenantManager.FindTenantIndex()
 			if err != nil {
 				if errors.Is(err, fuzzyfinder.ErrAbort) {
@@ -81,7 +164,6 @@ It provides a fuzzy finder interface to select subscriptions and remembers your
 				return pkgerrors.ErrTenantOperation("selecting tenant", err)
 			}
 
-			subManager := subscription.Manager{BaseManager: types.BaseManager{Configuration: cfg}}
 			sub, err := subManager.FindSubscriptionIndexByTenant(selectedTenant.ID)
 			if err != nil {
 				if errors.Is(err, fuzzyfinder.ErrAbort) {
@@ -90,15 +172,36 @@ It provides a fuzzy finder interface to select subscriptions and remembers your
 				return pkgerrors.ErrSelectingSubscription(err)
 			}
 
-			adapter := profile.NewConfigurationAdapter(&storage, logger)
-			if err := adapter.SetContext(sub.ID); err != nil {
+			adapter := newSwitchAdapter(cmd, storage, logger).WithHistory(stateManager, "aztx --by-tenant")
+			if err := adapter.SetContext(sub.Identity()); err != nil {
 				return pkgerrors.ErrOperation("setting context", err)
 			}
-			return nil
+			return reportSwitch(cmd, adapter, "aztx --by-tenant")
+		}
+
+		// Subscription selection across all configured config dirs
+		sources, err := configSources()
+		if err != nil {
+			return err
+		}
+		if len(sources) > 0 {
+			sub, err := profile.SelectAcrossSources(sources, switchFilter, logger)
+			if err != nil {
+				if errors.Is(err, fuzzyfinder.ErrAbort) {
+					return nil
+				}
+				return pkgerrors.ErrSelectingSubscription(err)
+			}
+
+			adapter := newSwitchAdapter(cmd, sub.Source.Storage, logger).WithHistory(stateManager, "aztx")
+			if err := adapter.SetContext(sub.Identity()); err != nil {
+				return pkgerrors.ErrOperation("setting context", err)
+			}
+			return reportSwitch(cmd, adapter, "aztx")
 		}
 
 		// Default subscription selection
-		adapter := profile.NewConfigurationAdapter(&storage, logger)
+		adapter := newSwitchAdapter(cmd, storage, logger).WithHistory(stateManager, "aztx").WithFilter(switchFilter)
 		sub, err := adapter.SelectWithFinder()
 		if err != nil {
 			if errors.Is(err, fuzzyfinder.ErrAbort) {
@@ -107,27 +210,253 @@ It provides a fuzzy finder interface to select subscriptions and remembers your
 			return pkgerrors.ErrSelectingSubscription(err)
 		}
 
-		if err := adapter.SetContext(sub.ID); err != nil {
+		if err := adapter.SetContext(sub.Identity()); err != nil {
 			return pkgerrors.ErrOperation("setting context", err)
 		}
 
-		return nil
+		return reportSwitch(cmd, adapter, "aztx")
 	},
 }
 
+// newProfileStorage returns a file adapter for the Azure CLI profile in the active
+// config dir, configured with the backup and locking settings from ~/.aztx.yml.
+// The config dir is resolved from --config-dir, which may name an entry of
+// config-dirs, then AZURE_CONFIG_DIR and finally ~/.azure, as the Azure CLI does.
+func newProfileStorage() (*storage.FileAdapter, error) {
+	dir := viper.GetString("config-dir")
+	if named, ok := viper.GetStringMapString("config-dirs")[strings.ToLower(dir)]; ok && dir != "" {
+		dir = named
+	}
+	resolved, err := storage.ResolveConfigDir(dir)
+	if err != nil {
+		return nil, pkgerrors.ErrFileOperation("fetching default profile path", err)
+	}
+	return newProfileStorageIn(resolved), nil
+}
+
+// historyStorage returns the storage of the config dir the history entry an "aztx -"
+// or "aztx -N" argument goes back to was recorded in, so that a context left in
+// another config dir is restored there. It returns fa for entries recorded in fa's
+// config dir or by versions of aztx that did not record it.
+func historyStorage(fa *storage.FileAdapter, sm state.StateManager, arg string) *storage.FileAdapter {
+	steps := 1
+	if arg != "-" {
+		steps, _ = strconv.Atoi(arg[1:])
+	}
+	history := sm.History()
+	if steps < 1 || steps > len(history) {
+		return fa
+	}
+	dir := history[steps-1].ConfigDir
+	if dir == "" || dir == fa.BaseConfigDir() {
+		return fa
+	}
+	return newProfileStorageIn(dir)
+}
+
+// newProfileStorageIn returns a file adapter for the Azure CLI profile in dir.
+func newProfileStorageIn(dir string) *storage.FileAdapter {
+	fa := &storage.FileAdapter{
+		Backups:     viper.GetInt("backups"),
+		LockTimeout: viper.GetDuration("lock-timeout"),
+	}
+	fa.UseConfigDir(dir)
+	return fa
+}
+
+// newMetadataStore returns the store for the metadata aztx keeps about subscriptions
+// and tenants. Aliases and favorites that earlier versions kept in ~/.aztx.yml are
+// moved into it the first time it is used.
+func newMetadataStore() (*metadata.Store, error) {
+	path, err := metadata.DefaultPath()
+	if err != nil {
+		return nil, pkgerrors.ErrFileOperation("fetching metadata path", err)
+	}
+	store := metadata.NewStore(path, viper.GetDuration("lock-timeout"))
+
+	aliases := viper.GetStringMapString("aliases")
+	favorites := viper.GetStringSlice("favorites")
+	if len(aliases) == 0 && len(favorites) == 0 {
+		return store, nil
+	}
+	err = store.Update(func(m *metadata.Metadata) error {
+		existing := m.Aliases()
+		for alias, id := range aliases {
+			if _, ok := existing[strings.ToLower(alias)]; !ok {
+				m.SetAlias(alias, id)
+			}
+		}
+		for _, id := range favorites {
+			m.SetFavorite(id, true)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, pkgerrors.ErrOperation("migrating aliases and favorites", err)
+	}
+	stateConfig := loadStateConfig()
+	stateConfig.Set("aliases", map[string]string{})
+	stateConfig.Set("favorites", []string{})
+	if err := stateConfig.WriteConfig(); err != nil {
+		return nil, pkgerrors.ErrOperation("migrating aliases and favorites", err)
+	}
+	return store, nil
+}
+
+// withMetadata wraps the profile storage so that the metadata kept by aztx, the
+// protected rules and the history are merged into the configurations read through
+// it. Tenant names that earlier versions stored in the profile itself are moved to
+// the metadata store first.
+func withMetadata(fa *storage.FileAdapter) (*profile.MetadataStorage, error) {
+	store, err := newMetadataStore()
+	if err != nil {
+		return nil, err
+	}
+	storage := profile.NewMetadataStorage(fa, store)
+	if storage.Rules, err = protectRules(); err != nil {
+		return nil, err
+	}
+	storage.History = newStateManager()
+	if _, err := storage.Migrate(); err != nil && !errors.Is(err, pkgerrors.ErrFileDoesNotExist) {
+		return nil, pkgerrors.ErrOperation("migrating tenant names", err)
+	}
+	return storage, nil
+}
+
+// readProfileConfig reads the active profile with the metadata kept by aztx merged in.
+// It takes no lock and migrates nothing, for completion and checks that only look.
+func readProfileConfig() (*types.Configuration, error) {
+	fa, err := newProfileStorage()
+	if err != nil {
+		return nil, err
+	}
+	var store profile.MetadataStore
+	if path, err := metadata.DefaultPath(); err == nil {
+		store = metadata.NewStore(path, 0)
+	}
+	storage := profile.NewMetadataStorage(fa, store)
+	if storage.Rules, err = protectRules(); err != nil {
+		return nil, err
+	}
+	cfg, err := storage.ReadConfig()
+	if err != nil {
+		return nil, pkgerrors.ErrReadingConfiguration(err)
+	}
+	return cfg, nil
+}
+
+// configSources returns the named config dirs from ~/.aztx.yml when the finder
+// should list subscriptions from all of them. It returns nil when an explicit
+// --config-dir was given, a session is being switched or no config dirs are configured.
+func configSources() ([]profile.Source, error) {
+	if viper.GetString("config-dir") != "" || activeSession != nil {
+		return nil, nil
+	}
+	dirs, err := storage.NewConfigDirs(viper.GetStringMapString("config-dirs"))
+	if err != nil {
+		return nil, pkgerrors.ErrFileOperation("resolving config dirs", err)
+	}
+	sources := make([]profile.Source, 0, len(dirs))
+	for _, dir := range dirs {
+		storage, err := withMetadata(newProfileStorageIn(dir.Path))
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, profile.Source{Name: dir.Name, Storage: storage})
+	}
+	return sources, nil
+}
+
+// stateConfig reads and writes the state aztx keeps in ~/.aztx.yml. It only holds the
+// content of the file, unlike the global viper instance, so that writing state never
+// persists the value of a flag passed to a single invocation.
+var (
+	stateConfig     = viper.New()
+	stateConfigOnce sync.Once
+	configFile      string
+)
+
+// loadStateConfig reads ~/.aztx.yml into stateConfig the first time state is needed,
+// so that commands which never touch state do not parse the file twice.
+func loadStateConfig() *viper.Viper {
+	stateConfigOnce.Do(func() {
+		stateConfig.SetConfigFile(configFile)
+		stateConfig.SetConfigType("yml")
+		if err := stateConfig.ReadInConfig(); err != nil {
+			logger := profile.NewLogger("error")
+			logger.Error("Failed to read config: %v", err)
+			os.Exit(1)
+		}
+	})
+	return stateConfig
+}
+
+// newStateManager returns the state manager backed by ~/.aztx.yml, keeping as many
+// history entries as history-size allows.
+func newStateManager() *state.ViperStateManager {
+	sm := state.NewViperStateManager(loadStateConfig())
+	sm.HistorySize = viper.GetInt("history-size")
+	return sm
+}
+
+// switchFilter narrows the subscriptions offered when switching with a query, the
+// finder or --by-tenant.
+var switchFilter subscription.Filter
+
+// historyJump matches the -N argument used to go back N contexts in the history.
+var historyJump = regexp.MustCompile(`^-[0-9]+$`)
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // It is called by main.main() and only needs to happen once to the rootCmd.
 // Returns an error if the command execution fails.
 func Execute() error {
+	rootCmd.SetArgs(historyJumpArgs(os.Args[1:]))
 	return rootCmd.Execute()
 }
 
+// historyJumpArgs marks a -N argument as positional so that it is not parsed as a
+// shorthand flag, turning "aztx -2" into "aztx -- -2".
+func historyJumpArgs(args []string) []string {
+	for i, arg := range args {
+		if arg == "--" {
+			return args
+		}
+		if historyJump.MatchString(arg) {
+			rewritten := append([]string{}, args[:i]...)
+			rewritten = append(rewritten, "--")
+			return append(rewritten, args[i:]...)
+		}
+	}
+	return args
+}
+
 // init initializes the command configuration by setting up flags and binding them to viper.
 // It is automatically called by cobra during command initialization.
 func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.PersistentFlags().String("log-level", "info", "Set log level (debug, info, warn, error)")
+	rootCmd.PersistentFlags().String("config-dir", "", "Azure CLI config directory, or the name of an entry in config-dirs (defaults to AZURE_CONFIG_DIR or ~/.azure)")
+	rootCmd.PersistentFlags().StringP("output", "o", string(output.Text), "Output format (text, json, yaml, tsv)")
+	rootCmd.PersistentFlags().String("confirm", "", "Name of the protected subscription being switched to, instead of typing it")
 	rootCmd.Flags().Bool("by-tenant", false, "Select tenant before choosing subscription")
+	rootCmd.Flags().Bool("session", false, "Switch only the current shell, printing shell code to eval")
+	rootCmd.Flags().String("shell", "", "Shell to print --session code for: sh, bash, zsh, fish or pwsh (defaults to $SHELL)")
+	rootCmd.Flags().StringVar(&switchFilter.Cloud, "cloud", "", "Only offer subscriptions of an Azure cloud, e.g. AzureUSGovernment")
+	rootCmd.Flags().StringVar(&switchFilter.User, "user", "", "Only offer subscriptions of a signed-in account, e.g. a service principal")
+	rootCmd.Flags().BoolVar(&switchFilter.Delegated, "delegated", false, "Only offer subscriptions managed by other tenants through Azure Lighthouse")
+	rootCmd.Flags().BoolVar(&switchFilter.Guest, "guest", false, "Only offer subscriptions reached as a B2B guest of another tenant")
+
+	rootCmd.ValidArgsFunction = completeSwitchQuery
+	registerFlagCompletion(rootCmd, "config-dir", completeConfigDirs)
+	formats := make([]string, 0, len(output.Formats))
+	for _, f := range output.Formats {
+		formats = append(formats, string(f))
+	}
+	registerFlagCompletion(rootCmd, "output", cobra.FixedCompletions(formats, cobra.ShellCompDirectiveNoFileComp))
+	registerFlagCompletion(rootCmd, "log-level", cobra.FixedCompletions([]string{"debug", "info", "warn", "error"}, cobra.ShellCompDirectiveNoFileComp))
+	registerFlagCompletion(rootCmd, "shell", cobra.FixedCompletions(shell.Shells, cobra.ShellCompDirectiveNoFileComp))
+	registerFlagCompletion(rootCmd, "cloud", completeField(func(s types.Subscription) string { return s.EnvironmentName }))
+	registerFlagCompletion(rootCmd, "user", completeField(func(s types.Subscription) string { return s.User.Name }))
 
 	// Bind flags to viper and check for errors
 	if err := viper.BindPFlag("log-level", rootCmd.PersistentFlags().Lookup("log-level")); err != nil {
@@ -135,11 +464,26 @@ func init() {
 		logger.Error("Failed to bind log-level flag: %v", err)
 		os.Exit(1)
 	}
+	if err := viper.BindPFlag("config-dir", rootCmd.PersistentFlags().Lookup("config-dir")); err != nil {
+		logger := profile.NewLogger("error")
+		logger.Error("Failed to bind config-dir flag: %v", err)
+		os.Exit(1)
+	}
+	if err := viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output")); err != nil {
+		logger := profile.NewLogger("error")
+		logger.Error("Failed to bind output flag: %v", err)
+		os.Exit(1)
+	}
 	if err := viper.BindPFlag("by-tenant", rootCmd.Flags().Lookup("by-tenant")); err != nil {
 		logger := profile.NewLogger("error")
 		logger.Error("Failed to bind by-tenant flag: %v", err)
 		os.Exit(1)
 	}
+	if err := viper.BindPFlag("session", rootCmd.Flags().Lookup("session")); err != nil {
+		logger := profile.NewLogger("error")
+		logger.Error("Failed to bind session flag: %v", err)
+		os.Exit(1)
+	}
 }
 
 // initConfig reads in config file and ENV variables if set.
@@ -160,11 +504,15 @@ func initConfig() {
 	viper.SetEnvPrefix("AZTX")
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 	viper.AutomaticEnv()
+	viper.SetDefault("backups", 5)
+	viper.SetDefault("lock-timeout", storage.DefaultLockTimeout.String())
+	viper.SetDefault("history-size", state.DefaultHistorySize)
 
-	// Create config if it doesn't exist
+	// Create an empty config if it doesn't exist. Writing the global viper instance
+	// instead would save the flags of this invocation along with it.
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			if err := viper.SafeWriteConfigAs(home + "/.aztx.yml"); err != nil {
+			if err := createConfig(home + "/.aztx.yml"); err != nil {
 				logger := profile.NewLogger("error")
 				logger.Error("Failed to write config: %v", err)
 				os.Exit(1)
@@ -175,4 +523,20 @@ func initConfig() {
 			os.Exit(1)
 		}
 	}
+
+	// State is written through its own viper instance, see loadStateConfig.
+	configFile = home + "/.aztx.yml"
+}
+
+// createConfig creates an empty config file at path, leaving a file that already
+// exists alone.
+func createConfig(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if errors.Is(err, os.ErrExist) {
+			return nil
+		}
+		return err
+	}
+	return f.Close()
 }
@@ -0,0 +1,242 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ktr0731/go-fuzzyfinder"
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/finder"
+	"github.com/riweston/aztx/pkg/state"
+	"github.com/riweston/aztx/pkg/storage"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestMain points HOME and the XDG directories at a temporary directory, so that the
+// commands under test never touch the real ~/.azure or ~/.aztx.yml, and detaches stdin
+// so that they never prompt.
+func TestMain(m *testing.M) {
+	home, err := os.MkdirTemp("", "aztx-cmd")
+	if err != nil {
+		panic(err)
+	}
+	if os.Stdin, err = os.Open(os.DevNull); err != nil {
+		panic(err)
+	}
+	os.Setenv("HOME", home)
+	os.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	os.Setenv("XDG_RUNTIME_DIR", filepath.Join(home, "run"))
+	os.Unsetenv(storage.ConfigDirEnv)
+	os.Unsetenv(storage.SessionEnv)
+	os.Unsetenv("AZTX_CONFIRM")
+	code := m.Run()
+	os.RemoveAll(home)
+	os.Exit(code)
+}
+
+// newTestHome gives each test a fresh ~/.azure profile, in which Production Workloads
+// is the default, and an empty ~/.aztx.yml. It returns the Azure CLI config dir.
+func newTestHome(t *testing.T) string {
+	t.Helper()
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+	require.NoError(t, os.RemoveAll(filepath.Join(home, ".config")))
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".aztx.yml"), nil, 0644))
+	return newTestConfigDir(t, filepath.Join(home, ".azure"))
+}
+
+// newTestConfigDir writes the sample profile into dir.
+func newTestConfigDir(t *testing.T, dir string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "azureProfile.json"))
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(dir, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "azureProfile.json"), data, 0644))
+	return dir
+}
+
+// run executes aztx with args as a separate invocation would: flags are back at their
+// defaults and ~/.aztx.yml is read afresh.
+func run(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	resetFlags(t, rootCmd)
+	stateConfig = viper.New()
+	stateConfigOnce = sync.Once{}
+
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs(historyJumpArgs(args))
+	err := rootCmd.Execute()
+	return out.String(), err
+}
+
+// resetFlags puts the flags of cmd and its subcommands back at their defaults.
+func resetFlags(t *testing.T, cmd *cobra.Command) {
+	for _, fs := range []*pflag.FlagSet{cmd.Flags(), cmd.PersistentFlags()} {
+		fs.VisitAll(func(f *pflag.Flag) {
+			if slice, ok := f.Value.(pflag.SliceValue); ok {
+				var values []string
+				if def := strings.Trim(f.DefValue, "[]"); def != "" {
+					values = strings.Split(def, ",")
+				}
+				require.NoError(t, slice.Replace(values))
+			} else {
+				require.NoError(t, f.Value.Set(f.DefValue))
+			}
+			f.Changed = false
+		})
+	}
+	for _, sub := range cmd.Commands() {
+		resetFlags(t, sub)
+	}
+}
+
+// choose replaces the fuzzy finder for the test: each time it is shown it picks the
+// first item whose label contains the next of labels.
+func choose(t *testing.T, labels ...string) {
+	t.Helper()
+	previous := finder.Select
+	t.Cleanup(func() { finder.Select = previous })
+	finder.Select = func(slice interface{}, itemFunc func(int) string, _ ...fuzzyfinder.Option) (int, error) {
+		require.NotEmpty(t, labels, "the finder was shown more often than expected")
+		want := labels[0]
+		labels = labels[1:]
+		for i := 0; ; i++ {
+			label := itemFunc(i)
+			if strings.Contains(label, want) {
+				return i, nil
+			}
+			require.Less(t, i, 100, "no item matches %q", want)
+		}
+	}
+}
+
+// defaultIn returns the name of the default subscription in the profile in dir.
+func defaultIn(t *testing.T, dir string) string {
+	t.Helper()
+	cfg, err := (&storage.FileAdapter{Path: filepath.Join(dir, "azureProfile.json")}).ReadConfig()
+	require.NoError(t, err)
+	for _, sub := range cfg.Subscriptions {
+		if sub.IsDefault {
+			return sub.Name
+		}
+	}
+	return ""
+}
+
+func history(t *testing.T) []state.HistoryEntry {
+	t.Helper()
+	stateConfig = viper.New()
+	stateConfigOnce = sync.Once{}
+	return newStateManager().History()
+}
+
+func TestRoot_SwitchPaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		choices []string
+		command string
+	}{
+		{name: "query", args: []string{"Fabrikam Production"}, command: "aztx Fabrikam Production"},
+		{name: "finder", args: nil, choices: []string{"Fabrikam Production"}, command: "aztx"},
+		{name: "by tenant", args: []string{"--by-tenant"}, choices: []string{"22222222", "Fabrikam Production"}, command: "aztx --by-tenant"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := newTestHome(t)
+			choose(t, tt.choices...)
+
+			_, err := run(t, tt.args...)
+			require.NoError(t, err)
+			assert.Equal(t, "Fabrikam Production", defaultIn(t, dir))
+
+			entries := history(t)
+			require.Len(t, entries, 1)
+			assert.Equal(t, "Production Workloads", entries[0].SubscriptionName)
+			assert.Equal(t, tt.command, entries[0].Command)
+			assert.Equal(t, dir, entries[0].ConfigDir)
+
+			_, err = run(t, "-")
+			require.NoError(t, err)
+			assert.Equal(t, "Production Workloads", defaultIn(t, dir))
+			assert.Equal(t, "Fabrikam Production", history(t)[0].SubscriptionName)
+		})
+	}
+}
+
+func TestRoot_HistoryJump(t *testing.T) {
+	dir := newTestHome(t)
+	for _, query := range []string{"Fabrikam Production", "Acme Corp Main"} {
+		_, err := run(t, query)
+		require.NoError(t, err)
+	}
+
+	_, err := run(t, "-2")
+	require.NoError(t, err)
+	assert.Equal(t, "Production Workloads", defaultIn(t, dir))
+	assert.Equal(t, "aztx -2", history(t)[0].Command)
+}
+
+func TestRoot_HistorySize(t *testing.T) {
+	newTestHome(t)
+	t.Setenv("AZTX_HISTORY_SIZE", "2")
+	for _, query := range []string{"Fabrikam Production", "Acme Corp Main", "Development Environment"} {
+		_, err := run(t, query)
+		require.NoError(t, err)
+	}
+
+	entries := history(t)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "Acme Corp Main", entries[0].SubscriptionName)
+	assert.Equal(t, "Fabrikam Production", entries[1].SubscriptionName)
+}
+
+func TestRoot_HistoryRestoresConfigDir(t *testing.T) {
+	dir := newTestHome(t)
+	work := newTestConfigDir(t, filepath.Join(t.TempDir(), "work"))
+
+	_, err := run(t, "--config-dir", work, "Fabrikam Production")
+	require.NoError(t, err)
+	assert.Equal(t, "Fabrikam Production", defaultIn(t, work))
+	assert.Equal(t, work, history(t)[0].ConfigDir)
+
+	// Going back without --config-dir restores the context where it was left.
+	_, err = run(t, "-")
+	require.NoError(t, err)
+	assert.Equal(t, "Production Workloads", defaultIn(t, work))
+	assert.Equal(t, "Production Workloads", defaultIn(t, dir), "the default config dir is untouched")
+}
+
+func TestRoot_ConfirmIsNotSaved(t *testing.T) {
+	dir := newTestHome(t)
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+	config := filepath.Join(home, ".aztx.yml")
+	require.NoError(t, os.Remove(config))
+
+	// The first run creates ~/.aztx.yml without the flags it was given.
+	_, err = run(t, "--confirm", "Fabrikam Production", "--output", "json", "current")
+	require.NoError(t, err)
+	data, err := os.ReadFile(config)
+	require.NoError(t, err)
+	assert.Empty(t, string(data))
+
+	require.NoError(t, os.WriteFile(config, []byte("protected:\n  - name: Fabrikam Production\n"), 0644))
+	_, err = run(t, "Fabrikam Production")
+	require.ErrorIs(t, err, pkgerrors.ErrConfirmationRequired, "the second run still asks")
+	assert.Equal(t, "Production Workloads", defaultIn(t, dir))
+
+	_, err = run(t, "--confirm", "Fabrikam Production", "Fabrikam Production")
+	require.NoError(t, err)
+	assert.Equal(t, "Fabrikam Production", defaultIn(t, dir))
+}
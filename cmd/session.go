@@ -0,0 +1,226 @@
+/*
+Copyright © 2024 Richard Weston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/profile"
+	"github.com/riweston/aztx/pkg/shell"
+	"github.com/riweston/aztx/pkg/storage"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// sessionVars are the variables a session sets besides AZURE_CONFIG_DIR.
+var sessionVars = []string{"AZURE_SUBSCRIPTION_ID", "ARM_SUBSCRIPTION_ID", "ARM_TENANT_ID", storage.SessionEnv}
+
+// shellSession is the session a --session switch is made in.
+type shellSession struct {
+	*storage.Session
+	dialect  shell.Dialect
+	created  bool // The overlay was created by this invocation
+	exported bool // The shell code for a switch was printed
+}
+
+// activeSession is set while "aztx --session" runs, so that the switch is reported
+// as shell code instead of messages.
+var activeSession *shellSession
+
+// sessionCmd groups the commands that manage shell sessions
+var sessionCmd = &cobra.Command{
+	Use:   "session",
+	Short: "Manage per-shell sessions",
+	Long: `A session gives one shell its own subscription context. Start or switch it with
+
+  eval "$(aztx --session [query])"
+
+which points AZURE_CONFIG_DIR at a private copy of the Azure profile and exports
+AZURE_SUBSCRIPTION_ID, ARM_SUBSCRIPTION_ID and ARM_TENANT_ID, leaving the default
+subscription of every other shell untouched. Tokens and other Azure CLI settings stay
+shared with the base config directory.`,
+}
+
+var sessionEndCmd = &cobra.Command{
+	Use:   "end",
+	Short: "End the session of the current shell",
+	Long: `Print the shell code that ends the session of the current shell, restoring
+AZURE_CONFIG_DIR, and remove the session's private profile. Use it with eval, or
+on its own with the shell integration from "aztx init":
+
+  eval "$(aztx session end)"`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dialect, err := shellDialect(cmd)
+		if err != nil {
+			return err
+		}
+		dir := os.Getenv(storage.SessionEnv)
+		if dir == "" {
+			return pkgerrors.ErrNotASession
+		}
+		session, err := storage.OpenSession(dir)
+		if err != nil {
+			return err
+		}
+
+		code := shell.Unset(dialect, sessionVars)
+		if session.PreviousConfigDir != "" {
+			code += shell.Export(dialect, []shell.Var{{Name: storage.ConfigDirEnv, Value: session.PreviousConfigDir}})
+		} else {
+			code += shell.Unset(dialect, []string{storage.ConfigDirEnv})
+		}
+		if err := session.Remove(); err != nil {
+			return err
+		}
+		return writeShellCode(cmd, code)
+	},
+}
+
+// startSession opens the session the switch of a --session invocation is made in and
+// returns the storage of its private profile. The session of the current shell is
+// reused when it overlays the config dir aztx resolved; otherwise a new one is
+// created, replacing the old.
+func startSession(cmd *cobra.Command, fa *storage.FileAdapter) (*storage.FileAdapter, error) {
+	if outputFormat().Structured() {
+		return nil, pkgerrors.ErrSessionOutput
+	}
+	dialect, err := shellDialect(cmd)
+	if err != nil {
+		return nil, err
+	}
+
+	previous := os.Getenv(storage.ConfigDirEnv)
+	var current *storage.Session
+	if dir := os.Getenv(storage.SessionEnv); dir != "" {
+		current, err = storage.OpenSession(dir)
+		if err != nil && !errors.Is(err, pkgerrors.ErrNotASession) {
+			return nil, err
+		}
+	}
+
+	active := &shellSession{dialect: dialect}
+	base := fa.ConfigDir()
+	if overlay, err := storage.OpenSession(base); err == nil {
+		base = overlay.Base
+	}
+	switch {
+	case current != nil && base == current.Base:
+		if err := current.Sync(); err != nil {
+			return nil, err
+		}
+		active.Session = current
+	default:
+		if current != nil {
+			previous = current.PreviousConfigDir
+		}
+		active.Session, err = storage.NewSession(storage.DefaultSessionRoot(), base, previous)
+		if err != nil {
+			return nil, err
+		}
+		active.created = true
+		if current != nil {
+			_ = current.Remove()
+		}
+	}
+
+	activeSession = active
+	overlay := newProfileStorageIn(active.Dir)
+	overlay.Backups = 0
+	return overlay, nil
+}
+
+// endSession removes a session created by this invocation when nothing was switched
+// in it, e.g. because the finder was aborted, as the shell never learns about it.
+func endSession() {
+	if activeSession != nil && activeSession.created && !activeSession.exported {
+		_ = activeSession.Remove()
+	}
+}
+
+// exportSession writes the shell code that makes the current shell use the switch
+// made through adapter.
+func exportSession(cmd *cobra.Command, adapter *profile.ConfigurationAdapter) error {
+	last := adapter.LastSwitch()
+	if last == nil {
+		return nil
+	}
+	code := shell.Export(activeSession.dialect, []shell.Var{
+		{Name: storage.ConfigDirEnv, Value: activeSession.Dir},
+		{Name: storage.SessionEnv, Value: activeSession.Dir},
+		{Name: "AZURE_SUBSCRIPTION_ID", Value: last.Subscription.ID.String()},
+		{Name: "ARM_SUBSCRIPTION_ID", Value: last.Subscription.ID.String()},
+		{Name: "ARM_TENANT_ID", Value: last.Subscription.TenantID.String()},
+	})
+	activeSession.exported = true
+	return writeShellCode(cmd, code)
+}
+
+// writeShellCode writes code that must run in the calling shell. The shell integration
+// from "aztx init" names a file to source in AZTX_EVAL_FILE; without it the code is
+// printed for eval.
+func writeShellCode(cmd *cobra.Command, code string) error {
+	path := os.Getenv(shell.EvalFileEnv)
+	if path == "" {
+		_, err := fmt.Fprint(cmd.OutOrStdout(), code)
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return pkgerrors.ErrFileOperation("writing shell code", err)
+	}
+	if _, err := f.WriteString(code); err != nil {
+		_ = f.Close()
+		return pkgerrors.ErrFileOperation("writing shell code", err)
+	}
+	return f.Close()
+}
+
+// isSessionOverlay reports whether dir is the private config dir of a session.
+func isSessionOverlay(dir string) bool {
+	_, err := storage.OpenSession(dir)
+	return err == nil
+}
+
+// shellDialect returns the shell selected with --shell or AZTX_SHELL, which the shell
+// integration sets, or the user's shell.
+func shellDialect(cmd *cobra.Command) (shell.Dialect, error) {
+	name, _ := cmd.Flags().GetString("shell")
+	if name == "" {
+		name = viper.GetString("shell")
+	}
+	if name == "" {
+		return shell.Detect(), nil
+	}
+	return shell.ParseDialect(name)
+}
+
+func init() {
+	rootCmd.AddCommand(sessionCmd)
+	sessionCmd.AddCommand(sessionEndCmd)
+	sessionEndCmd.Flags().String("shell", "", "Shell to print code for: sh, bash, zsh, fish or pwsh (defaults to $SHELL)")
+	registerFlagCompletion(sessionEndCmd, "shell", cobra.FixedCompletions(shell.Shells, cobra.ShellCompDirectiveNoFileComp))
+}
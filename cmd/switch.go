@@ -0,0 +1,96 @@
+/*
+Copyright © 2024 Richard Weston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+
+	"github.com/ktr0731/go-fuzzyfinder"
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/finder"
+	"github.com/riweston/aztx/pkg/profile"
+	"github.com/riweston/aztx/pkg/state"
+	"github.com/riweston/aztx/pkg/subscription"
+	"github.com/riweston/aztx/pkg/types"
+
+	"github.com/spf13/cobra"
+)
+
+// switchByQuery switches to the subscription matching query. An exact match on ID,
+// name or alias switches directly; partial matches, even a single one, open the finder
+// restricted to them, or fail with the list of candidates when there is no terminal to
+// prompt on.
+func switchByQuery(cmd *cobra.Command, fa profile.StorageAdapter, logger profile.Logger, sm state.StateManager, query string) error {
+	cfg, err := fa.ReadConfig()
+	if err != nil {
+		return pkgerrors.ErrReadingConfiguration(err)
+	}
+
+	subManager, err := (&subscription.Manager{BaseManager: types.BaseManager{Configuration: cfg}}).Narrow(switchFilter)
+	if err != nil {
+		return err
+	}
+	sub, err := resolveQuery(subManager, query)
+	if err != nil {
+		if errors.Is(err, fuzzyfinder.ErrAbort) {
+			return nil
+		}
+		return err
+	}
+
+	adapter := newSwitchAdapter(cmd, fa, logger).WithHistory(sm, "aztx "+query)
+	if err := adapter.SetContext(sub.Identity()); err != nil {
+		return pkgerrors.ErrOperation("setting context", err)
+	}
+	return reportSwitch(cmd, adapter, "aztx "+query)
+}
+
+// resolveQuery turns a positional query into a single subscription. Only an exact
+// match is taken without asking, so that a typo never switches to a subscription that
+// merely contains the query.
+func resolveQuery(subManager *subscription.Manager, query string) (*types.Subscription, error) {
+	exact, candidates := subManager.MatchSubscriptions(query)
+	if exact != nil {
+		return exact, nil
+	}
+	if len(candidates) == 0 {
+		return nil, pkgerrors.ErrNoMatch(query)
+	}
+
+	if !finder.IsInteractive() {
+		label := subscription.Labeler(candidates)
+		labels := make([]string, 0, len(candidates))
+		for _, c := range candidates {
+			labels = append(labels, label(c))
+		}
+		return nil, pkgerrors.ErrAmbiguous(query, labels)
+	}
+
+	sub, err := subManager.SelectSubscription(candidates)
+	if err != nil {
+		if errors.Is(err, fuzzyfinder.ErrAbort) {
+			return nil, err
+		}
+		return nil, pkgerrors.ErrSelectingSubscription(err)
+	}
+	return sub, nil
+}
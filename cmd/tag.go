@@ -0,0 +1,167 @@
+/*
+Copyright © 2024 Richard Weston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/metadata"
+
+	"github.com/spf13/cobra"
+)
+
+// tagCmd manages free-form labels on subscriptions
+var tagCmd = &cobra.Command{
+	Use:   "tag",
+	Short: "Manage subscription tags",
+	Long: `Label subscriptions with tags such as "prod" or "customer-a". Tags are kept in
+aztx's metadata file and never written to the Azure profile.`,
+}
+
+var tagAddCmd = &cobra.Command{
+	Use:   "add <query> <tag>...",
+	Short: "Add tags to a subscription",
+	Long: `Add tags to the subscription matching query. Pass "" as the query to pick the
+subscription with the fuzzy finder.`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateTags(cmd, args[0], args[1:], true)
+	},
+}
+
+var tagRmCmd = &cobra.Command{
+	Use:     "rm <query> <tag>...",
+	Aliases: []string{"remove"},
+	Short:   "Remove tags from a subscription",
+	Args:    cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return updateTags(cmd, args[0], args[1:], false)
+	},
+}
+
+var tagListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List tagged subscriptions",
+	Args:    cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := newMetadataStore()
+		if err != nil {
+			return err
+		}
+		kept, err := store.Load()
+		if err != nil {
+			return pkgerrors.ErrOperation("reading metadata", err)
+		}
+
+		var ids []string
+		for id, sub := range kept.Subscriptions {
+			if len(sub.Tags) > 0 {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+
+		subs := subscriptionNames()
+		if outputFormat().Structured() {
+			results := make([]tagResult, 0, len(ids))
+			for _, id := range ids {
+				results = append(results, tagResult{SubscriptionID: id, SubscriptionName: subs[id], Tags: kept.Tags(id)})
+			}
+			return printResult(cmd, results)
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SUBSCRIPTION\tID\tTAGS")
+		for _, id := range ids {
+			name, ok := subs[id]
+			if !ok {
+				name = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", name, id, strings.Join(kept.Tags(id), ","))
+		}
+		return w.Flush()
+	},
+}
+
+// updateTags adds tags to, or removes them from, the subscription matching query.
+func updateTags(cmd *cobra.Command, query string, tags []string, add bool) error {
+	store, err := newMetadataStore()
+	if err != nil {
+		return err
+	}
+	var args []string
+	if query != "" {
+		args = []string{query}
+	}
+	sub, err := pickSubscription(args)
+	if err != nil || sub == nil {
+		return err
+	}
+
+	var updated []string
+	err = store.Update(func(m *metadata.Metadata) error {
+		if add {
+			m.AddTags(sub.ID.String(), tags...)
+		} else {
+			m.RemoveTags(sub.ID.String(), tags...)
+		}
+		updated = m.Tags(sub.ID.String())
+		return nil
+	})
+	if err != nil {
+		return pkgerrors.ErrOperation("saving tags", err)
+	}
+
+	summary := strings.Join(updated, ", ")
+	if summary == "" {
+		summary = "none"
+	}
+	newLogger().Success("Tags of %s (%s): %s", sub.Name, sub.ID, summary)
+	return printResult(cmd, tagResult{SubscriptionID: sub.ID.String(), SubscriptionName: sub.Name, Tags: nonNil(updated)})
+}
+
+// tagResult is the tags of a subscription in structured output.
+type tagResult struct {
+	SubscriptionID   string   `json:"subscriptionId"`
+	SubscriptionName string   `json:"subscriptionName"`
+	Tags             []string `json:"tags"`
+}
+
+// nonNil returns an empty slice for nil, so that JSON output has [] instead of null.
+func nonNil(items []string) []string {
+	if items == nil {
+		return []string{}
+	}
+	return items
+}
+
+func init() {
+	rootCmd.AddCommand(tagCmd)
+	tagCmd.AddCommand(tagAddCmd, tagRmCmd, tagListCmd)
+	tagAddCmd.ValidArgsFunction = completeTags
+	tagRmCmd.ValidArgsFunction = completeTags
+}
@@ -0,0 +1,297 @@
+/*
+Copyright © 2024 Richard Weston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ktr0731/go-fuzzyfinder"
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/finder"
+	"github.com/riweston/aztx/pkg/profile"
+	"github.com/riweston/aztx/pkg/tenant"
+	"github.com/riweston/aztx/pkg/types"
+
+	"github.com/spf13/cobra"
+)
+
+// tenantCmd groups the commands that manage how tenants are shown
+var tenantCmd = &cobra.Command{
+	Use:   "tenant",
+	Short: "Manage tenant names",
+	Long: `Give tenants readable names. Without a custom name a tenant is shown by the account
+used to sign in to it, which is the same for every tenant you reach with one account.
+Names are kept in aztx's metadata file, not in the Azure profile. "aztx tenant
+delegations" shows which tenants manage which through Azure Lighthouse.`,
+}
+
+var tenantRenameCmd = &cobra.Command{
+	Use:   "rename [tenant-id | query] <name>",
+	Short: "Set the name shown for a tenant",
+	Long: `Set the name shown for a tenant in the finder and in "aztx tenant list".
+The tenant is matched by its exact ID, name or custom name, or picked with the fuzzy
+finder among the tenants the query partly matches; when only the new name is given
+it is picked from all tenants.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[len(args)-1]
+		if name == "" {
+			return pkgerrors.ErrEmptyTenantName
+		}
+
+		adapter, selected, err := pickTenant(args[:len(args)-1])
+		if err != nil || selected == nil {
+			return err
+		}
+		if err := adapter.SaveTenantName(selected.ID, name); err != nil {
+			return pkgerrors.ErrTenantOperation("renaming", err)
+		}
+		return printResult(cmd, tenantResult{ID: selected.ID.String(), Name: selected.Name, CustomName: name})
+	},
+}
+
+var tenantUnnameCmd = &cobra.Command{
+	Use:   "unname [tenant-id | query]",
+	Short: "Remove the custom name of a tenant",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		adapter, selected, err := pickTenant(args)
+		if err != nil || selected == nil {
+			return err
+		}
+
+		logger := newLogger()
+		cleared, err := adapter.ClearTenantName(selected.ID)
+		if err != nil {
+			return pkgerrors.ErrTenantOperation("removing name", err)
+		}
+		if !cleared {
+			logger.Info("tenant %s has no custom name", selected.ID)
+		} else {
+			logger.Success("removed custom name of tenant %s", selected.ID)
+		}
+		return printResult(cmd, tenantResult{ID: selected.ID.String(), Name: selected.Name})
+	},
+}
+
+// tenantListSort is the field "aztx tenant list" orders tenants by
+var tenantListSort string
+
+var tenantListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List tenants and their names",
+	Long: `List the tenants of the Azure profile with their custom names, the account used to
+sign in to them and how many subscriptions they hold. Use "aztx list --tenant" to
+list the subscriptions of a tenant.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fa, err := newProfileStorage()
+		if err != nil {
+			return err
+		}
+		storage, err := withMetadata(fa)
+		if err != nil {
+			return err
+		}
+		cfg, err := storage.ReadConfig()
+		if err != nil {
+			return pkgerrors.ErrReadingConfiguration(err)
+		}
+
+		tenantManager := tenant.Manager{BaseManager: types.BaseManager{Configuration: cfg}}
+		tenants, err := tenantManager.GetTenants()
+		if err != nil {
+			return err
+		}
+		if err := tenant.Sort(tenants, tenantListSort); err != nil {
+			return err
+		}
+
+		counts := make(map[string]int)
+		for _, sub := range cfg.Subscriptions {
+			counts[sub.TenantID.String()]++
+		}
+
+		if outputFormat().Structured() {
+			results := make([]tenantListResult, 0, len(tenants))
+			for _, t := range tenants {
+				results = append(results, tenantListResult{
+					ID:            t.ID.String(),
+					Name:          t.Name,
+					CustomName:    t.CustomName,
+					Subscriptions: counts[t.ID.String()],
+				})
+			}
+			return printResult(cmd, results)
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TENANT ID\tNAME\tACCOUNT\tSUBSCRIPTIONS")
+		for _, t := range tenants {
+			name := t.CustomName
+			if name == "" {
+				name = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\n", t.ID, name, t.Name, counts[t.ID.String()])
+		}
+		return w.Flush()
+	},
+}
+
+// tenantListResult is a tenant listed by "aztx tenant list" in structured output.
+type tenantListResult struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	CustomName    string `json:"customName"`
+	Subscriptions int    `json:"subscriptions"`
+}
+
+var tenantDelegationsCmd = &cobra.Command{
+	Use:   "delegations",
+	Short: "List the tenants managing others through Azure Lighthouse",
+	Long: `List which tenants manage which through Azure Lighthouse, grouped by managing tenant,
+with the delegated subscriptions of each customer tenant. Use "aztx list --delegated"
+to list the delegated subscriptions themselves.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fa, err := newProfileStorage()
+		if err != nil {
+			return err
+		}
+		storage, err := withMetadata(fa)
+		if err != nil {
+			return err
+		}
+		cfg, err := storage.ReadConfig()
+		if err != nil {
+			return pkgerrors.ErrReadingConfiguration(err)
+		}
+
+		tenantManager := tenant.Manager{BaseManager: types.BaseManager{Configuration: cfg}}
+		delegations := tenantManager.Delegations()
+
+		if outputFormat().Structured() {
+			results := make([]delegationResult, 0, len(delegations))
+			for _, d := range delegations {
+				result := delegationResult{
+					ManagingTenant: newTenantResult(d.Manager),
+					CustomerTenant: newTenantResult(d.Customer),
+					Subscriptions:  make([]subscriptionResult, 0, len(d.Subscriptions)),
+				}
+				for _, sub := range d.Subscriptions {
+					result.Subscriptions = append(result.Subscriptions, newSubscriptionResult(sub))
+				}
+				results = append(results, result)
+			}
+			return printResult(cmd, results)
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "MANAGING TENANT\tCUSTOMER TENANT\tSUBSCRIPTIONS")
+		for _, d := range delegations {
+			names := make([]string, 0, len(d.Subscriptions))
+			for _, sub := range d.Subscriptions {
+				names = append(names, sub.Name)
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\n", tenant.TenantName(d.Manager), tenant.TenantName(d.Customer),
+				strings.Join(names, ", "))
+		}
+		return w.Flush()
+	},
+}
+
+// delegationResult is a delegation listed by "aztx tenant delegations" in structured
+// output.
+type delegationResult struct {
+	ManagingTenant tenantResult         `json:"managingTenant"`
+	CustomerTenant tenantResult         `json:"customerTenant"`
+	Subscriptions  []subscriptionResult `json:"subscriptions"`
+}
+
+// pickTenant resolves the tenant a tenant command applies to, from a query when one
+// is given or with the fuzzy finder otherwise, and returns it with an adapter for the
+// profile it was read from. It returns a nil tenant without an error when the finder
+// is aborted.
+func pickTenant(args []string) (*profile.ConfigurationAdapter, *types.Tenant, error) {
+	fa, err := newProfileStorage()
+	if err != nil {
+		return nil, nil, err
+	}
+	storage, err := withMetadata(fa)
+	if err != nil {
+		return nil, nil, err
+	}
+	adapter := profile.NewConfigurationAdapter(storage, newLogger())
+	tenantManager, err := adapter.GetTenantManager()
+	if err != nil {
+		return nil, nil, pkgerrors.ErrReadingConfiguration(err)
+	}
+
+	var selected *types.Tenant
+	if len(args) > 0 {
+		selected, err = resolveTenant(tenantManager, args[0])
+	} else {
+		selected, err = tenantManager.FindTenantIndex()
+	}
+	if err != nil {
+		if errors.Is(err, fuzzyfinder.ErrAbort) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	return adapter, selected, nil
+}
+
+// resolveTenant turns a query into a single tenant. Only an exact match is taken
+// without asking; partial matches, even a single one, are offered in the finder when a
+// terminal is available.
+func resolveTenant(tenantManager *tenant.Manager, query string) (*types.Tenant, error) {
+	exact, candidates := tenantManager.MatchTenants(query)
+	if exact != nil {
+		return exact, nil
+	}
+	if len(candidates) == 0 {
+		return nil, pkgerrors.ErrNoTenantMatch(query)
+	}
+
+	if !finder.IsInteractive() {
+		labels := make([]string, 0, len(candidates))
+		for _, c := range candidates {
+			labels = append(labels, tenant.Label(c))
+		}
+		return nil, pkgerrors.ErrAmbiguousTenant(query, labels)
+	}
+	return tenantManager.SelectTenant(candidates)
+}
+
+func init() {
+	rootCmd.AddCommand(tenantCmd)
+	tenantCmd.AddCommand(tenantRenameCmd, tenantUnnameCmd, tenantListCmd, tenantDelegationsCmd)
+	tenantListCmd.Flags().StringVar(&tenantListSort, "sort", "name", "Sort by name, id or account")
+	registerFlagCompletion(tenantListCmd, "sort", completeSortKeys(tenant.SortKeys))
+	tenantRenameCmd.ValidArgsFunction = completeTenantArg
+	tenantUnnameCmd.ValidArgsFunction = completeTenantArg
+}
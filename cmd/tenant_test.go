@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"testing"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenant_RenameOnlyExactMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		wantErr error
+	}{
+		{name: "exact ID", query: "11111111-1111-1111-1111-111111111111"},
+		{name: "exact account name", query: "contoso ltd"},
+		{name: "single partial match", query: "Contoso", wantErr: pkgerrors.ErrAmbiguousTenantQuery},
+		{name: "partial ID", query: "1111", wantErr: pkgerrors.ErrAmbiguousTenantQuery},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newTestHome(t)
+
+			_, err := run(t, "tenant", "rename", tt.query, "Contoso")
+			out, currentErr := run(t, "current", "--format", "{{.TenantName}}")
+			require.NoError(t, currentErr)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Equal(t, "Contoso Ltd\n", out, "the tenant keeps its name")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "Contoso\n", out)
+
+			_, err = run(t, "tenant", "unname", "Cont")
+			assert.ErrorIs(t, err, pkgerrors.ErrAmbiguousTenantQuery)
+			_, err = run(t, "tenant", "unname", "Contoso")
+			require.NoError(t, err)
+			out, err = run(t, "current", "--format", "{{.TenantName}}")
+			require.NoError(t, err)
+			assert.Equal(t, "Contoso Ltd\n", out)
+		})
+	}
+}
@@ -0,0 +1,248 @@
+/*
+Copyright © 2024 Richard Weston
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ktr0731/go-fuzzyfinder"
+	"github.com/riweston/aztx/pkg/account"
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/finder"
+	"github.com/riweston/aztx/pkg/subscription"
+	"github.com/riweston/aztx/pkg/types"
+
+	"github.com/spf13/cobra"
+)
+
+// userCmd groups the commands that work with the accounts signed in to the Azure CLI
+var userCmd = &cobra.Command{
+	Use:   "user",
+	Short: "List and switch signed-in accounts",
+	Long: `Work with the accounts signed in to the Azure CLI: users, service principals and
+managed identities. The profile lists a subscription once for every account that can
+reach it, and the account of the default subscription is the one az uses.`,
+}
+
+var userListCmd = &cobra.Command{
+	Use:     "list",
+	Aliases: []string{"ls"},
+	Short:   "List signed-in accounts",
+	Long: `List the accounts of the Azure profile with their type, the tenants they reach and
+how many subscriptions they can use. The account in use is marked with a star.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fa, err := newProfileStorage()
+		if err != nil {
+			return err
+		}
+		storage, err := withMetadata(fa)
+		if err != nil {
+			return err
+		}
+		cfg, err := storage.ReadConfig()
+		if err != nil {
+			return pkgerrors.ErrReadingConfiguration(err)
+		}
+
+		accountManager := account.Manager{BaseManager: types.BaseManager{Configuration: cfg}}
+		accounts, err := accountManager.Accounts()
+		if err != nil {
+			return err
+		}
+		subManager := subscription.Manager{BaseManager: types.BaseManager{Configuration: cfg}}
+		current, _ := subManager.DefaultSubscription()
+
+		if outputFormat().Structured() {
+			results := make([]userResult, 0, len(accounts))
+			for _, a := range accounts {
+				tenants := make([]tenantResult, 0, len(a.Tenants))
+				for _, id := range a.Tenants {
+					name := accountManager.TenantName(id)
+					if name == id.String() {
+						name = ""
+					}
+					tenants = append(tenants, tenantResult{ID: id.String(), Name: a.Name, CustomName: name})
+				}
+				results = append(results, userResult{
+					Name:          a.Name,
+					Type:          a.Kind(),
+					Tenants:       tenants,
+					Subscriptions: len(a.Subscriptions),
+					Current:       current != nil && a.Owns(*current),
+				})
+			}
+			return printResult(cmd, results)
+		}
+
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "CURRENT\tACCOUNT\tTYPE\tTENANTS\tSUBSCRIPTIONS")
+		for _, a := range accounts {
+			mark := ""
+			if current != nil && a.Owns(*current) {
+				mark = "*"
+			}
+			tenants := make([]string, 0, len(a.Tenants))
+			for _, id := range a.Tenants {
+				tenants = append(tenants, accountManager.TenantName(id))
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\n", mark, a.Name, a.Kind(), strings.Join(tenants, ","), len(a.Subscriptions))
+		}
+		return w.Flush()
+	},
+}
+
+var userSwitchCmd = &cobra.Command{
+	Use:   "switch [account]",
+	Short: "Switch to another signed-in account",
+	Long: `Switch the Azure CLI to another signed-in account. The account is matched by its
+exact name, or picked with the fuzzy finder among the accounts the query partly
+matches. When the account can reach the subscription in use, aztx stays on it as
+that account; otherwise one of the account's subscriptions is picked with the finder.`,
+	Args:              cobra.MaximumNArgs(1),
+	ValidArgsFunction: completeAccountArg,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fa, err := newProfileStorage()
+		if err != nil {
+			return err
+		}
+		storage, err := withMetadata(fa)
+		if err != nil {
+			return err
+		}
+		cfg, err := storage.ReadConfig()
+		if err != nil {
+			return pkgerrors.ErrReadingConfiguration(err)
+		}
+
+		accountManager := account.Manager{BaseManager: types.BaseManager{Configuration: cfg}}
+		selected, err := pickAccount(&accountManager, args)
+		if err != nil || selected == nil {
+			return err
+		}
+
+		subManager := subscription.Manager{BaseManager: types.BaseManager{Configuration: cfg}}
+		sub, err := accountSubscription(&subManager, *selected)
+		if err != nil {
+			if errors.Is(err, fuzzyfinder.ErrAbort) {
+				return nil
+			}
+			return err
+		}
+
+		adapter := newSwitchAdapter(cmd, storage, newLogger()).WithHistory(newStateManager(), "aztx user switch")
+		if err := adapter.SetContext(sub.Identity()); err != nil {
+			return pkgerrors.ErrOperation("setting context", err)
+		}
+		return reportSwitch(cmd, adapter, "aztx user switch")
+	},
+}
+
+// userResult is an account listed by "aztx user list" in structured output.
+type userResult struct {
+	Name          string         `json:"name"`
+	Type          string         `json:"type"`
+	Tenants       []tenantResult `json:"tenants"`
+	Subscriptions int            `json:"subscriptions"`
+	Current       bool           `json:"current"`
+}
+
+// pickAccount resolves the account to switch to, from a query when one is given or
+// with the fuzzy finder otherwise. It returns nil without an error when the finder
+// is aborted.
+func pickAccount(accountManager *account.Manager, args []string) (*account.Account, error) {
+	var selected *account.Account
+	var err error
+	if len(args) > 0 {
+		selected, err = resolveAccount(accountManager, args[0])
+	} else {
+		var accounts []account.Account
+		if accounts, err = accountManager.Accounts(); err == nil {
+			selected, err = accountManager.SelectAccount(accounts)
+		}
+	}
+	if errors.Is(err, fuzzyfinder.ErrAbort) {
+		return nil, nil
+	}
+	return selected, err
+}
+
+// resolveAccount turns a query into a single account. Only an exact match is taken
+// without asking; partial matches, even a single one, are offered in the finder when a
+// terminal is available.
+func resolveAccount(accountManager *account.Manager, query string) (*account.Account, error) {
+	exact, candidates := accountManager.MatchAccounts(query)
+	if exact != nil {
+		return exact, nil
+	}
+	if len(candidates) == 0 {
+		return nil, pkgerrors.ErrNoAccountMatch(query)
+	}
+
+	if !finder.IsInteractive() {
+		labels := make([]string, 0, len(candidates))
+		for _, c := range candidates {
+			labels = append(labels, account.Label(c))
+		}
+		return nil, pkgerrors.ErrAmbiguousAccount(query, labels)
+	}
+	return accountManager.SelectAccount(candidates)
+}
+
+// accountSubscription returns the subscription to use with an account: the one in use
+// when the account can reach it, its only subscription, or one picked with the finder.
+func accountSubscription(subManager *subscription.Manager, a account.Account) (*types.Subscription, error) {
+	if current, err := subManager.DefaultSubscription(); err == nil {
+		if sub := a.Subscription(current.ID); sub != nil {
+			return sub, nil
+		}
+	}
+	if len(a.Subscriptions) == 1 {
+		return &a.Subscriptions[0], nil
+	}
+
+	if !finder.IsInteractive() {
+		label := subscription.Labeler(a.Subscriptions)
+		labels := make([]string, 0, len(a.Subscriptions))
+		for _, sub := range a.Subscriptions {
+			labels = append(labels, label(sub))
+		}
+		return nil, pkgerrors.ErrAmbiguous(a.Name, labels)
+	}
+	return subManager.SelectSubscription(a.Subscriptions)
+}
+
+// completeAccountArg completes the account names of the active profile as the only argument.
+func completeAccountArg(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	return completeField(func(s types.Subscription) string { return s.User.Name })(cmd, args, toComplete)
+}
+
+func init() {
+	rootCmd.AddCommand(userCmd)
+	userCmd.AddCommand(userListCmd, userSwitchCmd)
+}
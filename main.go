@@ -22,7 +22,6 @@ THE SOFTWARE.
 package main
 
 import (
-	"fmt"
 	"os"
 
 	"github.com/riweston/aztx/cmd"
@@ -30,7 +29,7 @@ import (
 
 func main() {
 	if err := cmd.Execute(); err != nil {
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		cmd.PrintError(err)
+		os.Exit(cmd.ExitCode(err))
 	}
 }
@@ -0,0 +1,158 @@
+// Package account groups the subscriptions of the Azure profile by the account signed
+// in to them: users, service principals and managed identities.
+package account
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/finder"
+	"github.com/riweston/aztx/pkg/types"
+)
+
+// Kinds of account, as reported by Account.Kind.
+const (
+	KindUser             = "user"
+	KindServicePrincipal = "servicePrincipal"
+	KindManagedIdentity  = "managedIdentity"
+)
+
+// Account is an identity signed in to the Azure CLI, with the subscriptions of the
+// profile it can reach.
+type Account struct {
+	Name          string               // User principal name, application ID or managed identity marker
+	Type          string               // Account type as recorded by the Azure CLI
+	Tenants       []uuid.UUID          // Tenants of the subscriptions, in the order they first appear
+	Subscriptions []types.Subscription // Profile entries of the account
+}
+
+// Kind returns whether the account is a user, a service principal or a managed
+// identity. The Azure CLI records managed identities as service principals named
+// after the kind of identity.
+func (a Account) Kind() string {
+	switch {
+	case a.Name == "systemAssignedIdentity" || a.Name == "userAssignedIdentity":
+		return KindManagedIdentity
+	case strings.EqualFold(a.Type, KindServicePrincipal):
+		return KindServicePrincipal
+	case strings.EqualFold(a.Type, KindUser):
+		return KindUser
+	}
+	return a.Type
+}
+
+// Owns reports whether sub is an entry of the account.
+func (a Account) Owns(sub types.Subscription) bool {
+	return strings.EqualFold(sub.User.Name, a.Name) && strings.EqualFold(sub.User.Type, a.Type)
+}
+
+// Subscription returns the account's entry for a subscription, or nil when the
+// account cannot reach it.
+func (a Account) Subscription(id uuid.UUID) *types.Subscription {
+	for i, sub := range a.Subscriptions {
+		if sub.ID == id {
+			return &a.Subscriptions[i]
+		}
+	}
+	return nil
+}
+
+// Label renders an account the way the finder lists it.
+func Label(a Account) string {
+	return fmt.Sprintf("%s (%s)", a.Name, a.Kind())
+}
+
+type Manager struct {
+	types.BaseManager
+}
+
+// Accounts returns the accounts of the profile ordered by name and kind.
+func (am *Manager) Accounts() ([]Account, error) {
+	var accounts []Account
+	for _, sub := range am.Configuration.Subscriptions {
+		i := 0
+		for ; i < len(accounts) && !accounts[i].Owns(sub); i++ {
+		}
+		if i == len(accounts) {
+			accounts = append(accounts, Account{Name: sub.User.Name, Type: sub.User.Type})
+		}
+		accounts[i].Subscriptions = append(accounts[i].Subscriptions, sub)
+		if !containsID(accounts[i].Tenants, sub.TenantID) {
+			accounts[i].Tenants = append(accounts[i].Tenants, sub.TenantID)
+		}
+	}
+	if len(accounts) == 0 {
+		return nil, pkgerrors.ErrAccountNotFound
+	}
+
+	sort.SliceStable(accounts, func(i, j int) bool {
+		li, lj := strings.ToLower(accounts[i].Name), strings.ToLower(accounts[j].Name)
+		if li != lj {
+			return li < lj
+		}
+		return accounts[i].Kind() < accounts[j].Kind()
+	})
+	return accounts, nil
+}
+
+// TenantName returns the custom name of a tenant, or its ID when it has none.
+func (am *Manager) TenantName(id uuid.UUID) string {
+	for _, t := range am.Configuration.Tenants {
+		if t.ID == id && t.CustomName != "" {
+			return t.CustomName
+		}
+	}
+	return id.String()
+}
+
+// MatchAccounts resolves a query against the account names. A single
+// case-insensitive exact match is returned as exact. Several exact matches, or
+// failing that every account whose name contains the query, are returned as
+// candidates.
+func (am *Manager) MatchAccounts(query string) (exact *Account, candidates []Account) {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil, nil
+	}
+	accounts, err := am.Accounts()
+	if err != nil {
+		return nil, nil
+	}
+
+	var exacts, partials []Account
+	for _, a := range accounts {
+		name := strings.ToLower(a.Name)
+		switch {
+		case name == query:
+			exacts = append(exacts, a)
+		case strings.Contains(name, query):
+			partials = append(partials, a)
+		}
+	}
+
+	if len(exacts) == 1 {
+		return &exacts[0], nil
+	}
+	if len(exacts) > 1 {
+		return nil, exacts
+	}
+	return nil, partials
+}
+
+// SelectAccount uses fuzzy finding to select one of the given accounts, showing the
+// subscriptions of the account under the cursor in a preview pane.
+func (am *Manager) SelectAccount(accounts []Account) (*Account, error) {
+	return finder.FuzzyPreview(accounts, Label, am.Preview)
+}
+
+func containsID(ids []uuid.UUID, id uuid.UUID) bool {
+	for _, known := range ids {
+		if known == id {
+			return true
+		}
+	}
+	return false
+}
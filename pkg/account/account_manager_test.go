@@ -0,0 +1,120 @@
+package account
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	contosoID  = uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	fabrikamID = uuid.MustParse("22222222-2222-2222-2222-222222222222")
+)
+
+func newSubscription(name string, tenantID uuid.UUID, user, userType string) types.Subscription {
+	sub := types.Subscription{ID: uuid.New(), Name: name, TenantID: tenantID}
+	sub.User.Name, sub.User.Type = user, userType
+	return sub
+}
+
+func newTestManager() *Manager {
+	production := newSubscription("Production", contosoID, "alice@contoso.com", "user")
+	deploy := production
+	deploy.User.Name, deploy.User.Type = "deploy-sp", "servicePrincipal"
+	return &Manager{BaseManager: types.BaseManager{Configuration: &types.Configuration{
+		Subscriptions: []types.Subscription{
+			production,
+			newSubscription("Fabrikam", fabrikamID, "alice@contoso.com", "user"),
+			deploy,
+			newSubscription("Runner", contosoID, "systemAssignedIdentity", "servicePrincipal"),
+		},
+		Tenants: []types.Tenant{{ID: fabrikamID, CustomName: "Fabrikam Customer"}},
+	}}}
+}
+
+func TestManager_Accounts(t *testing.T) {
+	accounts, err := newTestManager().Accounts()
+	require.NoError(t, err)
+
+	type summary struct {
+		Name, Kind    string
+		Tenants       []uuid.UUID
+		Subscriptions int
+	}
+	var got []summary
+	for _, a := range accounts {
+		got = append(got, summary{a.Name, a.Kind(), a.Tenants, len(a.Subscriptions)})
+	}
+	assert.Equal(t, []summary{
+		{"alice@contoso.com", KindUser, []uuid.UUID{contosoID, fabrikamID}, 2},
+		{"deploy-sp", KindServicePrincipal, []uuid.UUID{contosoID}, 1},
+		{"systemAssignedIdentity", KindManagedIdentity, []uuid.UUID{contosoID}, 1},
+	}, got)
+
+	empty := &Manager{BaseManager: types.BaseManager{Configuration: &types.Configuration{}}}
+	_, err = empty.Accounts()
+	assert.ErrorIs(t, err, pkgerrors.ErrAccountNotFound)
+}
+
+func TestAccount_Subscription(t *testing.T) {
+	m := newTestManager()
+	accounts, err := m.Accounts()
+	require.NoError(t, err)
+	production := m.Configuration.Subscriptions[0]
+
+	sub := accounts[1].Subscription(production.ID)
+	if assert.NotNil(t, sub) {
+		assert.Equal(t, "deploy-sp", sub.User.Name, "the entry of the account is returned")
+	}
+	assert.Nil(t, accounts[1].Subscription(m.Configuration.Subscriptions[1].ID))
+}
+
+func TestManager_MatchAccounts(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		wantExact      string
+		wantCandidates []string
+	}{
+		{name: "exact name ignores case", query: "Deploy-SP", wantExact: "deploy-sp"},
+		{name: "partial match", query: "a", wantCandidates: []string{"alice@contoso.com", "systemAssignedIdentity"}},
+		{name: "no match", query: "missing"},
+		{name: "empty query", query: " "},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exact, candidates := newTestManager().MatchAccounts(tt.query)
+			if tt.wantExact != "" {
+				if assert.NotNil(t, exact) {
+					assert.Equal(t, tt.wantExact, exact.Name)
+				}
+			} else {
+				assert.Nil(t, exact)
+			}
+			var names []string
+			for _, c := range candidates {
+				names = append(names, c.Name)
+			}
+			assert.Equal(t, tt.wantCandidates, names)
+		})
+	}
+}
+
+func TestManager_Preview(t *testing.T) {
+	m := newTestManager()
+	subs := m.Configuration.Subscriptions
+	subs[1].IsDefault = true
+	accounts, err := m.Accounts()
+	require.NoError(t, err)
+
+	assert.Equal(t, "alice@contoso.com\n\n"+
+		"Type           user\n"+
+		"Tenants        11111111-1111-1111-1111-111111111111, Fabrikam Customer\n"+
+		"Subscriptions  2\n\n"+
+		"  Production ("+subs[0].ID.String()+")\n"+
+		"* Fabrikam ("+subs[1].ID.String()+")\n", m.Preview(accounts[0]))
+}
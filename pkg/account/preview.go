@@ -0,0 +1,37 @@
+package account
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/riweston/aztx/pkg/finder"
+	"github.com/riweston/aztx/pkg/subscription"
+)
+
+// Preview renders the details of a shown next to the finder: its kind, its tenants
+// and the subscriptions it can reach, with the one in use marked with an asterisk.
+func (am *Manager) Preview(a Account) string {
+	tenants := make([]string, 0, len(a.Tenants))
+	for _, id := range a.Tenants {
+		tenants = append(tenants, am.TenantName(id))
+	}
+
+	var b strings.Builder
+	b.WriteString(finder.Details(a.Name,
+		finder.Field{Name: "Type", Value: a.Kind()},
+		finder.Field{Name: "Tenants", Value: strings.Join(tenants, ", ")},
+		finder.Field{Name: "Subscriptions", Value: fmt.Sprint(len(a.Subscriptions))},
+	))
+	if len(a.Subscriptions) > 0 {
+		b.WriteString("\n")
+	}
+	subManager := subscription.Manager{BaseManager: am.BaseManager}
+	for _, sub := range subscription.SortFavoritesFirst(a.Subscriptions) {
+		mark := " "
+		if subManager.IsCurrent(sub) {
+			mark = "*"
+		}
+		fmt.Fprintf(&b, "%s %s\n", mark, subscription.Label(sub))
+	}
+	return b.String()
+}
@@ -6,6 +6,7 @@ package errors
 import (
 	"errors"
 	"fmt"
+	"strings"
 )
 
 var (
@@ -17,6 +18,20 @@ var (
 	ErrFetchingHomePath = errors.New("could not fetch home directory")
 	// ErrPathIsEmpty is returned when a required file path is empty
 	ErrPathIsEmpty = errors.New("path is empty")
+	// ErrBackupNotFound is returned when a requested backup does not exist
+	ErrBackupNotFound = errors.New("backup not found")
+	// ErrInvalidBackup is returned when a backup does not contain valid JSON
+	ErrInvalidBackup = errors.New("backup does not contain valid JSON")
+	// ErrLockTimeout is returned when a file lock could not be acquired in time
+	ErrLockTimeout = errors.New("timed out waiting for file lock")
+
+	// ErrLockHeld wraps lock errors with the locked file and the PID holding the lock
+	ErrLockHeld = func(path string, pid int, err error) error {
+		if pid == 0 {
+			return fmt.Errorf("%s is locked by another process: %w", path, err)
+		}
+		return fmt.Errorf("%s is locked by process %d: %w", path, pid, err)
+	}
 
 	// Configuration related errors
 
@@ -34,10 +49,34 @@ var (
 	// ErrNoPreviousContext is returned when attempting to switch to a previous context that doesn't exist
 	ErrNoPreviousContext = errors.New("no previous context, check ~/.aztx.yml is present and has content")
 
+	// ErrHistoryOutOfRange wraps ErrNoPreviousContext when jumping further back than the history goes
+	ErrHistoryOutOfRange = func(steps, size int) error {
+		return fmt.Errorf("cannot go back %d contexts, history has %d entries: %w", steps, size, ErrNoPreviousContext)
+	}
+
 	// Subscription related errors
 
 	// ErrSubscriptionNotFound is returned when a requested subscription cannot be found
 	ErrSubscriptionNotFound = errors.New("subscription not found")
+	// ErrAmbiguousQuery is returned when a query matches no subscription exactly and
+	// there is no terminal to pick one of its partial matches on
+	ErrAmbiguousQuery = errors.New("query does not match a single subscription exactly")
+
+	// ErrNoMatch wraps ErrSubscriptionNotFound with the query that matched nothing
+	ErrNoMatch = func(query string) error {
+		return fmt.Errorf("no subscription matches %q: %w", query, ErrSubscriptionNotFound)
+	}
+	// ErrNoneMatchFilter wraps ErrSubscriptionNotFound when the filters of a switch leave no subscription
+	ErrNoneMatchFilter = fmt.Errorf("no subscription matches the given filters: %w", ErrSubscriptionNotFound)
+	// ErrAmbiguous wraps ErrAmbiguousQuery with the query and the candidates it matched
+	ErrAmbiguous = func(query string, candidates []string) error {
+		return fmt.Errorf("%w %q:\n  %s", ErrAmbiguousQuery, query, strings.Join(candidates, "\n  "))
+	}
+
+	// ErrAliasNotFound is returned when removing an alias that does not exist
+	ErrAliasNotFound = errors.New("alias not found")
+	// ErrInvalidAlias is returned when an alias could be confused with another argument
+	ErrInvalidAlias = errors.New("invalid alias: must be non-empty, contain no whitespace and not look like a subscription ID or - / -N")
 
 	// File operation errors
 
@@ -113,8 +152,172 @@ var (
 
 	// ErrTenantNotFound is returned when a tenant is not found
 	ErrTenantNotFound = errors.New("tenant not found")
+	// ErrAmbiguousTenantQuery is returned when a query matches no single tenant exactly
+	// and there is no terminal to pick one of its matches on
+	ErrAmbiguousTenantQuery = errors.New("query does not match a single tenant exactly")
+
+	// ErrNoTenantMatch wraps ErrTenantNotFound with the query that matched nothing
+	ErrNoTenantMatch = func(query string) error {
+		return fmt.Errorf("no tenant matches %q: %w", query, ErrTenantNotFound)
+	}
+	// ErrAmbiguousTenant wraps ErrAmbiguousTenantQuery with the query and the candidates it matched
+	ErrAmbiguousTenant = func(query string, candidates []string) error {
+		return fmt.Errorf("%w %q:\n  %s", ErrAmbiguousTenantQuery, query, strings.Join(candidates, "\n  "))
+	}
+
+	// Account related errors
+
+	// ErrAccountNotFound is returned when no signed-in account matches
+	ErrAccountNotFound = errors.New("account not found")
+	// ErrAmbiguousAccountQuery is returned when a query matches no single account
+	// exactly and there is no terminal to pick one of its matches on
+	ErrAmbiguousAccountQuery = errors.New("query does not match a single account exactly")
+
+	// ErrNoAccountMatch wraps ErrAccountNotFound with the query that matched nothing
+	ErrNoAccountMatch = func(query string) error {
+		return fmt.Errorf("no account matches %q: %w", query, ErrAccountNotFound)
+	}
+	// ErrAmbiguousAccount wraps ErrAmbiguousAccountQuery with the query and the candidates it matched
+	ErrAmbiguousAccount = func(query string, candidates []string) error {
+		return fmt.Errorf("%w %q:\n  %s", ErrAmbiguousAccountQuery, query, strings.Join(candidates, "\n  "))
+	}
+
+	// Output errors
+
+	// ErrUnknownOutputFormat is returned when --output names a format aztx cannot write
+	ErrUnknownOutputFormat = errors.New("unknown output format")
+
+	// ErrInvalidOutputFormat wraps ErrUnknownOutputFormat with the rejected format
+	ErrInvalidOutputFormat = func(format string) error {
+		return fmt.Errorf("%w %q: must be one of text, json, yaml, tsv", ErrUnknownOutputFormat, format)
+	}
+
+	// ErrUnknownSortKey is returned when --sort names a field a list cannot be ordered by
+	ErrUnknownSortKey = errors.New("unknown sort key")
+
+	// ErrInvalidSortKey wraps ErrUnknownSortKey with the rejected key and the accepted ones
+	ErrInvalidSortKey = func(key string, keys []string) error {
+		return fmt.Errorf("%w %q: must be one of %s", ErrUnknownSortKey, key, strings.Join(keys, ", "))
+	}
+
+	// Session related errors
+
+	// ErrUnknownShell is returned when aztx is asked for code in a shell it cannot write
+	ErrUnknownShell = errors.New("unknown shell")
+
+	// ErrInvalidShell wraps ErrUnknownShell with the rejected shell
+	ErrInvalidShell = func(shell string) error {
+		return fmt.Errorf("%w %q: must be one of sh, bash, zsh, fish, pwsh", ErrUnknownShell, shell)
+	}
+
+	// ErrNotASession is returned when a config directory is not a session overlay created by aztx
+	ErrNotASession = errors.New("not an aztx session")
+
+	// ErrSessionOutput is returned when --session is combined with a structured output format
+	ErrSessionOutput = errors.New("--session prints shell code and cannot be combined with --output")
+
+	// ErrExecUsage is returned when aztx exec is not given a query followed by -- and a command
+	ErrExecUsage = errors.New("exec needs a subscription, then -- and the command to run")
+
+	// Pin related errors
+
+	// ErrEmptyPin is returned when a pin file does not name a subscription
+	ErrEmptyPin = errors.New("pin names no subscription")
+
+	// ErrInvalidPinFile is returned when a pin file cannot be parsed
+	ErrInvalidPinFile = errors.New("invalid pin file")
+
+	// ErrInvalidPin wraps ErrInvalidPinFile with the path of the file and the parse error
+	ErrInvalidPin = func(path string, err error) error {
+		return fmt.Errorf("%w %s: %v", ErrInvalidPinFile, path, err)
+	}
+
+	// ErrNoPin is returned when no pin file is found in the working directory or its parents
+	ErrNoPin = errors.New("no .aztx pin file found")
+
+	// ErrPinMismatch is returned by aztx current --check when the default subscription is not the pinned one
+	ErrPinMismatch = errors.New("active subscription does not match the pin")
+
+	// ErrPinDiffers wraps ErrPinMismatch with the active subscription, the pin file and the pinned subscription
+	ErrPinDiffers = func(active, path, pinned string) error {
+		return fmt.Errorf("%w: %s is active, %s pins %s", ErrPinMismatch, active, path, pinned)
+	}
+
+	// Protection related errors
+
+	// ErrInvalidProtectRules is returned when the protected rules in ~/.aztx.yml are malformed
+	ErrInvalidProtectRules = errors.New("invalid protected rules")
+
+	// ErrInvalidProtectRule wraps ErrInvalidProtectRules with the position of the rule and what is wrong with it
+	ErrInvalidProtectRule = func(index int, reason string) error {
+		return fmt.Errorf("%w: rule %d: %s", ErrInvalidProtectRules, index+1, reason)
+	}
+
+	// ErrConfirmationRequired is returned when switching into a protected subscription without a terminal to confirm on
+	ErrConfirmationRequired = errors.New("switching to a protected subscription needs confirmation")
+
+	// ErrNeedsConfirmation wraps ErrConfirmationRequired with the subscription name to pass to --confirm
+	ErrNeedsConfirmation = func(name string) error {
+		return fmt.Errorf("%w, pass --confirm %q", ErrConfirmationRequired, name)
+	}
+
+	// ErrConfirmationFailed is returned when the typed confirmation does not name the protected subscription
+	ErrConfirmationFailed = errors.New("confirmation does not match the protected subscription")
 )
 
+// codes maps sentinel errors to the stable codes reported in structured output.
+// More specific errors come first, as an error may wrap several sentinels.
+var codes = []struct {
+	err  error
+	code string
+}{
+	{ErrLockTimeout, "lock_timeout"},
+	{ErrBackupNotFound, "backup_not_found"},
+	{ErrInvalidBackup, "invalid_backup"},
+	{ErrFileDoesNotExist, "file_does_not_exist"},
+	{ErrFetchingHomePath, "home_path_unavailable"},
+	{ErrPathIsEmpty, "path_is_empty"},
+	{ErrNoPreviousContext, "no_previous_context"},
+	{ErrAmbiguousQuery, "ambiguous_query"},
+	{ErrSubscriptionNotFound, "subscription_not_found"},
+	{ErrAliasNotFound, "alias_not_found"},
+	{ErrInvalidAlias, "invalid_alias"},
+	{ErrInvalidContext, "invalid_context"},
+	{ErrInvalidSubscriptionID, "invalid_subscription_id"},
+	{ErrNoDefaultSubscription, "no_default_subscription"},
+	{ErrEmptyConfiguration, "empty_configuration"},
+	{ErrInvalidTenantID, "invalid_tenant_id"},
+	{ErrEmptyTenantName, "empty_tenant_name"},
+	{ErrAmbiguousTenantQuery, "ambiguous_tenant_query"},
+	{ErrTenantNotFound, "tenant_not_found"},
+	{ErrAmbiguousAccountQuery, "ambiguous_account_query"},
+	{ErrAccountNotFound, "account_not_found"},
+	{ErrUnknownOutputFormat, "unknown_output_format"},
+	{ErrUnknownSortKey, "unknown_sort_key"},
+	{ErrUnknownShell, "unknown_shell"},
+	{ErrNotASession, "not_a_session"},
+	{ErrSessionOutput, "session_output"},
+	{ErrExecUsage, "exec_usage"},
+	{ErrInvalidPinFile, "invalid_pin"},
+	{ErrEmptyPin, "invalid_pin"},
+	{ErrNoPin, "no_pin"},
+	{ErrPinMismatch, "pin_mismatch"},
+	{ErrInvalidProtectRules, "invalid_protect_rules"},
+	{ErrConfirmationRequired, "confirmation_required"},
+	{ErrConfirmationFailed, "confirmation_failed"},
+}
+
+// Code returns the stable code of the sentinel error wrapped by err, or "error"
+// when err wraps none of them.
+func Code(err error) string {
+	for _, c := range codes {
+		if errors.Is(err, c.err) {
+			return c.code
+		}
+	}
+	return "error"
+}
+
 // WrapError is a helper function that wraps an error with operation context.
 // It takes an operation name and an error, and returns a new error with additional context.
 func WrapError(op string, err error) error {
@@ -75,6 +75,28 @@ func TestErrorWrapping(t *testing.T) {
 	}
 }
 
+func TestQueryErrors(t *testing.T) {
+	err := ErrNoMatch("prod")
+	assert.EqualError(t, err, `no subscription matches "prod": subscription not found`)
+	assert.ErrorIs(t, err, ErrSubscriptionNotFound)
+
+	err = ErrAmbiguous("prod", []string{"Prod A (1)", "Prod B (2)"})
+	assert.EqualError(t, err, "query does not match a single subscription exactly \"prod\":\n  Prod A (1)\n  Prod B (2)")
+	assert.ErrorIs(t, err, ErrAmbiguousQuery)
+
+	err = ErrNoTenantMatch("contoso")
+	assert.EqualError(t, err, `no tenant matches "contoso": tenant not found`)
+	assert.ErrorIs(t, err, ErrTenantNotFound)
+
+	err = ErrAmbiguousAccount("deploy", []string{"deploy-sp (servicePrincipal)"})
+	assert.EqualError(t, err, "query does not match a single account exactly \"deploy\":\n  deploy-sp (servicePrincipal)")
+	assert.ErrorIs(t, err, ErrAmbiguousAccountQuery)
+
+	err = ErrAmbiguousTenant("contoso", []string{"Contoso (1)", "Contoso Dev (2)"})
+	assert.EqualError(t, err, "query does not match a single tenant exactly \"contoso\":\n  Contoso (1)\n  Contoso Dev (2)")
+	assert.ErrorIs(t, err, ErrAmbiguousTenantQuery)
+}
+
 func TestStaticErrors(t *testing.T) {
 	tests := []struct {
 		name string
@@ -96,6 +118,16 @@ func TestStaticErrors(t *testing.T) {
 			err:  ErrPathIsEmpty,
 			msg:  "path is empty",
 		},
+		{
+			name: "backup not found error",
+			err:  ErrBackupNotFound,
+			msg:  "backup not found",
+		},
+		{
+			name: "invalid backup error",
+			err:  ErrInvalidBackup,
+			msg:  "backup does not contain valid JSON",
+		},
 	}
 
 	for _, tt := range tests {
@@ -104,3 +136,24 @@ func TestStaticErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{name: "sentinel", err: ErrTenantNotFound, want: "tenant_not_found"},
+		{name: "wrapped sentinel", err: ErrReadingConfiguration(ErrFileDoesNotExist), want: "file_does_not_exist"},
+		{name: "query error", err: ErrAmbiguous("prod", nil), want: "ambiguous_query"},
+		{name: "lock timeout wins over wrappers", err: WrapError("locking", ErrLockHeld("f", 1, ErrLockTimeout)), want: "lock_timeout"},
+		{name: "invalid backup", err: ErrFileOperation("validating backup", ErrInvalidBackup), want: "invalid_backup"},
+		{name: "sort key", err: ErrInvalidSortKey("size", []string{"name", "id"}), want: "unknown_sort_key"},
+		{name: "unknown error", err: errors.New("boom"), want: "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Code(tt.err))
+		})
+	}
+}
@@ -5,9 +5,12 @@ package finder
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/ktr0731/go-fuzzyfinder"
+	"golang.org/x/term"
 )
 
 // IDGetter is an interface that both Tenant and Subscription implement
@@ -15,17 +18,37 @@ type IDGetter interface {
 	GetID() uuid.UUID
 }
 
+// Select shows the interactive fuzzy finder and returns the index of the chosen item.
+// Tests replace it to choose an item without a terminal.
+var Select = fuzzyfinder.Find
+
 // Fuzzy is a utility function that provides interactive fuzzy finding capabilities
 func Fuzzy[T any](items []T, displayFunc func(T) string) (*T, error) {
+	return find(items, displayFunc)
+}
+
+// FuzzyPreview is Fuzzy with a preview pane showing the text previewFunc returns for
+// the item under the cursor.
+func FuzzyPreview[T any](items []T, displayFunc func(T) string, previewFunc func(T) string) (*T, error) {
+	return find(items, displayFunc, fuzzyfinder.WithPreviewWindow(func(i, _, _ int) string {
+		if i < 0 || i >= len(items) {
+			return ""
+		}
+		return previewFunc(items[i])
+	}))
+}
+
+func find[T any](items []T, displayFunc func(T) string, opts ...fuzzyfinder.Option) (*T, error) {
 	if len(items) == 0 {
 		return nil, fmt.Errorf("no items to select from")
 	}
 
-	idx, err := fuzzyfinder.Find(
+	idx, err := Select(
 		items,
 		func(i int) string {
 			return displayFunc(items[i])
 		},
+		opts...,
 	)
 	if err != nil {
 		return nil, err
@@ -34,6 +57,31 @@ func Fuzzy[T any](items []T, displayFunc func(T) string) (*T, error) {
 	return &items[idx], nil
 }
 
+// Field is a named value shown in a preview pane.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Details renders a preview pane: the title, then one field per line with the values
+// aligned. Empty values are shown as "-".
+func Details(title string, fields ...Field) string {
+	width := 0
+	for _, f := range fields {
+		width = max(width, len(f.Name))
+	}
+	var b strings.Builder
+	b.WriteString(title + "\n\n")
+	for _, f := range fields {
+		value := f.Value
+		if value == "" {
+			value = "-"
+		}
+		fmt.Fprintf(&b, "%-*s  %s\n", width, f.Name, value)
+	}
+	return b.String()
+}
+
 // ByID finds an item by its UUID in a slice of items that implement IDGetter
 func ByID[T IDGetter](items []T, id uuid.UUID) (*T, error) {
 	for _, item := range items {
@@ -43,3 +91,9 @@ func ByID[T IDGetter](items []T, id uuid.UUID) (*T, error) {
 	}
 	return nil, fmt.Errorf("item not found")
 }
+
+// IsInteractive reports whether both stdin and stdout are attached to a terminal,
+// which the fuzzy finder needs in order to prompt the user.
+func IsInteractive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd())) && term.IsTerminal(int(os.Stdout.Fd()))
+}
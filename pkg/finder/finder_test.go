@@ -106,3 +106,11 @@ func TestFuzzy(t *testing.T) {
 		})
 	}
 }
+
+func TestDetails(t *testing.T) {
+	got := Details("Production",
+		Field{Name: "ID", Value: "9e7969ef-4cb8-4a2d-959f-bfdaae452a3d"},
+		Field{Name: "Home tenant", Value: ""},
+	)
+	assert.Equal(t, "Production\n\nID           9e7969ef-4cb8-4a2d-959f-bfdaae452a3d\nHome tenant  -\n", got)
+}
@@ -0,0 +1,186 @@
+// Package metadata keeps the information aztx owns about subscriptions and tenants,
+// such as tenant names, aliases, favorites and tags, in a file of its own instead of
+// the Azure CLI's azureProfile.json, which az may rewrite at any time.
+package metadata
+
+import (
+	"sort"
+	"strings"
+)
+
+// CurrentVersion is the version of the metadata format written by this build.
+const CurrentVersion = 1
+
+// Tenant holds the metadata kept for a tenant.
+type Tenant struct {
+	Name string `json:"name,omitempty"` // User-defined name shown instead of the account name
+}
+
+// Subscription holds the metadata kept for a subscription.
+type Subscription struct {
+	Alias    string   `json:"alias,omitempty"`    // Short name accepted as a query
+	Favorite bool     `json:"favorite,omitempty"` // Listed first in the finder
+	Tags     []string `json:"tags,omitempty"`     // Free-form labels, lowercased and sorted
+}
+
+// Metadata is the content of the metadata store. Tenants and subscriptions are keyed
+// by their lowercased ID.
+type Metadata struct {
+	Version       int                     `json:"version"`
+	Tenants       map[string]Tenant       `json:"tenants,omitempty"`
+	Subscriptions map[string]Subscription `json:"subscriptions,omitempty"`
+}
+
+// Aliases returns the subscription ID of each alias, keyed by alias.
+func (m *Metadata) Aliases() map[string]string {
+	aliases := make(map[string]string)
+	for id, sub := range m.Subscriptions {
+		if sub.Alias != "" {
+			aliases[sub.Alias] = id
+		}
+	}
+	return aliases
+}
+
+// SetAlias assigns an alias to a subscription. A subscription has at most one alias
+// and an alias names at most one subscription, so both previous owners lose theirs.
+func (m *Metadata) SetAlias(alias, subscriptionID string) {
+	alias = strings.ToLower(alias)
+	for id, sub := range m.Subscriptions {
+		if sub.Alias == alias {
+			sub.Alias = ""
+			m.putSubscription(id, sub)
+		}
+	}
+	m.updateSubscription(subscriptionID, func(sub *Subscription) {
+		sub.Alias = alias
+	})
+}
+
+// RemoveAlias deletes an alias. Returns false if the alias did not exist.
+func (m *Metadata) RemoveAlias(alias string) bool {
+	alias = strings.ToLower(alias)
+	for id, sub := range m.Subscriptions {
+		if sub.Alias == alias {
+			sub.Alias = ""
+			m.putSubscription(id, sub)
+			return true
+		}
+	}
+	return false
+}
+
+// Favorites returns the IDs of the favorite subscriptions, sorted.
+func (m *Metadata) Favorites() []string {
+	var favorites []string
+	for id, sub := range m.Subscriptions {
+		if sub.Favorite {
+			favorites = append(favorites, id)
+		}
+	}
+	sort.Strings(favorites)
+	return favorites
+}
+
+// SetFavorite marks or unmarks a subscription as a favorite.
+func (m *Metadata) SetFavorite(subscriptionID string, favorite bool) {
+	m.updateSubscription(subscriptionID, func(sub *Subscription) {
+		sub.Favorite = favorite
+	})
+}
+
+// Tags returns the tags of a subscription.
+func (m *Metadata) Tags(subscriptionID string) []string {
+	return m.Subscriptions[strings.ToLower(subscriptionID)].Tags
+}
+
+// AddTags adds tags to a subscription, ignoring ones it already has.
+func (m *Metadata) AddTags(subscriptionID string, tags ...string) {
+	m.updateSubscription(subscriptionID, func(sub *Subscription) {
+		set := make(map[string]bool)
+		for _, tag := range append(sub.Tags, tags...) {
+			if tag = strings.ToLower(strings.TrimSpace(tag)); tag != "" {
+				set[tag] = true
+			}
+		}
+		sub.Tags = sortedKeys(set)
+	})
+}
+
+// RemoveTags removes tags from a subscription. Returns false if it had none of them.
+func (m *Metadata) RemoveTags(subscriptionID string, tags ...string) bool {
+	removed := false
+	m.updateSubscription(subscriptionID, func(sub *Subscription) {
+		set := make(map[string]bool)
+		for _, tag := range sub.Tags {
+			set[tag] = true
+		}
+		for _, tag := range tags {
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if set[tag] {
+				delete(set, tag)
+				removed = true
+			}
+		}
+		sub.Tags = sortedKeys(set)
+	})
+	return removed
+}
+
+// TenantNames returns the name of each named tenant, keyed by tenant ID.
+func (m *Metadata) TenantNames() map[string]string {
+	names := make(map[string]string)
+	for id, tenant := range m.Tenants {
+		if tenant.Name != "" {
+			names[id] = tenant.Name
+		}
+	}
+	return names
+}
+
+// SetTenantName sets the name of a tenant. An empty name removes it.
+func (m *Metadata) SetTenantName(tenantID, name string) {
+	tenantID = strings.ToLower(tenantID)
+	if name == "" {
+		delete(m.Tenants, tenantID)
+		return
+	}
+	if m.Tenants == nil {
+		m.Tenants = make(map[string]Tenant)
+	}
+	m.Tenants[tenantID] = Tenant{Name: name}
+}
+
+// updateSubscription applies fn to the metadata of a subscription, dropping the
+// entry once it no longer holds anything.
+func (m *Metadata) updateSubscription(subscriptionID string, fn func(*Subscription)) {
+	id := strings.ToLower(subscriptionID)
+	sub := m.Subscriptions[id]
+	fn(&sub)
+	m.putSubscription(id, sub)
+}
+
+// putSubscription stores the metadata of a subscription, or deletes it when empty.
+func (m *Metadata) putSubscription(id string, sub Subscription) {
+	if sub.Alias == "" && !sub.Favorite && len(sub.Tags) == 0 {
+		delete(m.Subscriptions, id)
+		return
+	}
+	if m.Subscriptions == nil {
+		m.Subscriptions = make(map[string]Subscription)
+	}
+	m.Subscriptions[id] = sub
+}
+
+// sortedKeys returns the keys of a set in order, or nil for an empty set.
+func sortedKeys(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,64 @@
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	prodID = "9e7969ef-4cb8-4a2d-959f-bfdaae452a3d"
+	devID  = "8aa89ebb-5735-4d1b-9c5c-a8f32a858e99"
+)
+
+func TestMetadata_Aliases(t *testing.T) {
+	m := &Metadata{}
+	m.SetAlias("Prod-WEU", "9E7969EF-4CB8-4A2D-959F-BFDAAE452A3D")
+	m.SetAlias("dev", devID)
+	assert.Equal(t, map[string]string{"prod-weu": prodID, "dev": devID}, m.Aliases())
+
+	// A subscription has at most one alias.
+	m.SetAlias("development", devID)
+	assert.Equal(t, map[string]string{"prod-weu": prodID, "development": devID}, m.Aliases())
+
+	// An alias names at most one subscription.
+	m.SetAlias("development", prodID)
+	assert.Equal(t, map[string]string{"development": prodID}, m.Aliases())
+
+	assert.True(t, m.RemoveAlias("DEVELOPMENT"))
+	assert.False(t, m.RemoveAlias("missing"))
+	assert.Empty(t, m.Aliases())
+	assert.Empty(t, m.Subscriptions, "empty entries are dropped")
+}
+
+func TestMetadata_Favorites(t *testing.T) {
+	m := &Metadata{}
+	m.SetFavorite(prodID, true)
+	m.SetFavorite("8AA89EBB-5735-4D1B-9C5C-A8F32A858E99", true)
+	m.SetFavorite(devID, true)
+	assert.Equal(t, []string{devID, prodID}, m.Favorites())
+
+	m.SetFavorite(prodID, false)
+	assert.Equal(t, []string{devID}, m.Favorites())
+}
+
+func TestMetadata_Tags(t *testing.T) {
+	m := &Metadata{}
+	m.AddTags(prodID, "Prod", " eu ", "prod", "")
+	assert.Equal(t, []string{"eu", "prod"}, m.Tags(prodID))
+
+	m.SetAlias("p", prodID)
+	assert.True(t, m.RemoveTags(prodID, "EU", "prod"))
+	assert.False(t, m.RemoveTags(prodID, "eu"))
+	assert.Nil(t, m.Tags(prodID))
+	assert.Equal(t, "p", m.Subscriptions[prodID].Alias, "removing tags keeps the alias")
+}
+
+func TestMetadata_TenantNames(t *testing.T) {
+	m := &Metadata{}
+	m.SetTenantName("22222222-2222-2222-2222-222222222222", "Fabrikam")
+	assert.Equal(t, map[string]string{"22222222-2222-2222-2222-222222222222": "Fabrikam"}, m.TenantNames())
+
+	m.SetTenantName("22222222-2222-2222-2222-222222222222", "")
+	assert.Empty(t, m.TenantNames())
+}
@@ -0,0 +1,104 @@
+package metadata
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/storage"
+)
+
+const (
+	// DirName is the directory, under the XDG config dir, holding aztx's own files.
+	DirName = "aztx"
+	// FileName is the name of the metadata file.
+	FileName = "metadata.json"
+)
+
+// DefaultPath returns the path of the metadata file: $XDG_CONFIG_HOME/aztx/metadata.json,
+// falling back to ~/.config/aztx/metadata.json when XDG_CONFIG_HOME is not set.
+func DefaultPath() (string, error) {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, DirName, FileName), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", pkgerrors.ErrFetchingHomePath
+	}
+	return filepath.Join(home, ".config", DirName, FileName), nil
+}
+
+// Store reads and writes the metadata file. Updates are made under a file lock and
+// written atomically, so concurrent aztx processes never lose each other's changes.
+type Store struct {
+	file *storage.FileAdapter
+}
+
+// NewStore returns a store for the metadata file at path. lockTimeout bounds how long
+// an update waits for another process holding the lock; zero uses the default.
+func NewStore(path string, lockTimeout time.Duration) *Store {
+	return &Store{file: &storage.FileAdapter{Path: path, LockTimeout: lockTimeout}}
+}
+
+// Path returns the location of the metadata file.
+func (s *Store) Path() string {
+	return s.file.Path
+}
+
+// Exists reports whether the metadata file has been written yet.
+func (s *Store) Exists() bool {
+	_, err := os.Stat(s.file.Path)
+	return err == nil
+}
+
+// Load reads the metadata. A missing file yields empty metadata.
+func (s *Store) Load() (*Metadata, error) {
+	data, err := s.file.Read()
+	if err != nil {
+		if err == pkgerrors.ErrFileDoesNotExist {
+			return &Metadata{Version: CurrentVersion}, nil
+		}
+		return nil, pkgerrors.ErrReadingFile(err)
+	}
+
+	var m Metadata
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, pkgerrors.ErrUnmarshallingJSON(err)
+	}
+	return &m, nil
+}
+
+// Update applies fn to the current metadata and writes the result back while holding
+// the store's lock. Nothing is written when fn returns an error.
+func (s *Store) Update(fn func(*Metadata) error) (err error) {
+	if err := os.MkdirAll(filepath.Dir(s.file.Path), 0700); err != nil {
+		return pkgerrors.ErrFileOperation("creating metadata directory for", err)
+	}
+
+	unlock, err := s.file.Lock()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := unlock(); unlockErr != nil && err == nil {
+			err = pkgerrors.ErrFileOperation("unlocking metadata", unlockErr)
+		}
+	}()
+
+	m, err := s.Load()
+	if err != nil {
+		return err
+	}
+	if err := fn(m); err != nil {
+		return err
+	}
+	m.Version = CurrentVersion
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return pkgerrors.ErrMarshallingJSON(err)
+	}
+	return s.file.Write(append(data, '\n'))
+}
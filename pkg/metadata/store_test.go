@@ -0,0 +1,68 @@
+package metadata
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultPath(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", "/xdg")
+	path, err := DefaultPath()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join("/xdg", "aztx", "metadata.json"), path)
+
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	path, err = DefaultPath()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, ".config", "aztx", "metadata.json"), path)
+}
+
+func TestStore_LoadMissing(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "aztx", FileName), 0)
+	m, err := store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, &Metadata{Version: CurrentVersion}, m)
+	assert.False(t, store.Exists())
+}
+
+func TestStore_Update(t *testing.T) {
+	store := NewStore(filepath.Join(t.TempDir(), "aztx", FileName), 0)
+	require.NoError(t, store.Update(func(m *Metadata) error {
+		m.SetAlias("prod", prodID)
+		m.SetTenantName("22222222-2222-2222-2222-222222222222", "Fabrikam")
+		return nil
+	}))
+	assert.True(t, store.Exists())
+
+	m, err := store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, CurrentVersion, m.Version)
+	assert.Equal(t, map[string]string{"prod": prodID}, m.Aliases())
+	assert.Equal(t, "Fabrikam", m.TenantNames()["22222222-2222-2222-2222-222222222222"])
+
+	// A failing update leaves the file untouched.
+	failure := errors.New("boom")
+	err = store.Update(func(m *Metadata) error {
+		m.RemoveAlias("prod")
+		return failure
+	})
+	assert.ErrorIs(t, err, failure)
+	m, err = store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"prod": prodID}, m.Aliases())
+}
+
+func TestStore_LoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	require.NoError(t, os.WriteFile(path, []byte("{not json"), 0600))
+	_, err := NewStore(path, 0).Load()
+	assert.Error(t, err)
+}
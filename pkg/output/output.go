@@ -0,0 +1,249 @@
+// Package output renders command results and errors in the formats selected with
+// --output, so that scripts can consume what aztx did.
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is an output format accepted by --output.
+type Format string
+
+const (
+	Text Format = "text" // Human-readable, styled messages and aligned tables
+	JSON Format = "json" // Indented JSON
+	YAML Format = "yaml" // YAML with the same keys as JSON
+	TSV  Format = "tsv"  // Tab-separated values without a header, like az --output tsv
+)
+
+// Formats lists the accepted formats, default first.
+var Formats = []Format{Text, JSON, YAML, TSV}
+
+// ParseFormat returns the format named by s. An empty name selects Text.
+func ParseFormat(s string) (Format, error) {
+	if s == "" {
+		return Text, nil
+	}
+	for _, f := range Formats {
+		if strings.EqualFold(s, string(f)) {
+			return f, nil
+		}
+	}
+	return "", pkgerrors.ErrInvalidOutputFormat(s)
+}
+
+// Structured reports whether the format is meant for machines rather than people.
+func (f Format) Structured() bool {
+	return f != Text
+}
+
+// Error is the structured form of an error. Code identifies the pkg/errors sentinel
+// the error wraps, so scripts can branch on it without parsing the message.
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// Write renders v, a struct or a slice of structs, in format f. Field names and
+// nesting follow the json tags of v. Text renders an aligned table with a header.
+func Write(w io.Writer, f Format, v interface{}) error {
+	switch f {
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case YAML:
+		return writeYAML(w, v)
+	case TSV:
+		_, rows := table(v)
+		for _, row := range rows {
+			if _, err := fmt.Fprintln(w, strings.Join(row, "\t")); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		header, rows := table(v)
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		if len(header) > 0 {
+			fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t")))
+		}
+		for _, row := range rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+}
+
+// WriteError renders err in format f. Text renders it as aztx always has.
+func WriteError(w io.Writer, f Format, err error) error {
+	if !f.Structured() {
+		_, werr := fmt.Fprintf(w, "Error: %v\n", err)
+		return werr
+	}
+	return Write(w, f, struct {
+		Error Error `json:"error"`
+	}{Error{Code: pkgerrors.Code(err), Message: err.Error()}})
+}
+
+// writeYAML renders v as YAML using its JSON field names and order.
+func writeYAML(w io.Writer, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return err
+	}
+	resetStyle(&node)
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&node); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// resetStyle clears the flow and quoting styles carried over from JSON so that
+// the document is written in block style.
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
+
+// table flattens v into a header and rows. Nested structs become dotted columns.
+func table(v interface{}) (header []string, rows [][]string) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		header = columns("", rv.Type().Elem())
+		for i := 0; i < rv.Len(); i++ {
+			rows = append(rows, values(rv.Index(i)))
+		}
+		return header, rows
+	}
+	return columns("", rv.Type()), [][]string{values(rv)}
+}
+
+// columns returns the column names of a value of type t.
+func columns(prefix string, t reflect.Type) []string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if !isRecord(t) {
+		if prefix == "" {
+			return []string{"value"}
+		}
+		return []string{prefix}
+	}
+
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		name, ok := fieldName(t.Field(i))
+		if !ok {
+			continue
+		}
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		names = append(names, columns(name, t.Field(i).Type)...)
+	}
+	return names
+}
+
+// values returns the cells of v in the order of columns.
+func values(v reflect.Value) []string {
+	t := v.Type()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return make([]string, len(columns("-", t)))
+		}
+		v = v.Elem()
+	}
+	if !isRecord(v.Type()) {
+		return []string{cell(v)}
+	}
+
+	var cells []string
+	for i := 0; i < v.NumField(); i++ {
+		if _, ok := fieldName(v.Type().Field(i)); !ok {
+			continue
+		}
+		cells = append(cells, values(v.Field(i))...)
+	}
+	return cells
+}
+
+// cell renders a scalar, list or map value.
+func cell(v reflect.Value) string {
+	switch value := v.Interface().(type) {
+	case time.Time:
+		if value.IsZero() {
+			return ""
+		}
+		return value.Format(time.RFC3339)
+	case fmt.Stringer:
+		return value.String()
+	}
+
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		items := make([]string, 0, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			items = append(items, cell(v.Index(i)))
+		}
+		return strings.Join(items, ",")
+	case reflect.Map:
+		data, _ := json.Marshal(v.Interface())
+		return string(data)
+	}
+	return fmt.Sprint(v.Interface())
+}
+
+// isRecord reports whether values of type t are flattened into several columns.
+func isRecord(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != reflect.TypeOf(time.Time{})
+}
+
+// fieldName returns the JSON name of an exported struct field.
+func fieldName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name, true
+	}
+	return field.Name, true
+}
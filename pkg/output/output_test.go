@@ -0,0 +1,135 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testSubscription struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type testResult struct {
+	Subscription testSubscription  `json:"subscription"`
+	Previous     *testSubscription `json:"previous"`
+	Tags         []string          `json:"tags"`
+	When         time.Time         `json:"when"`
+	hidden       string
+}
+
+var testValue = testResult{
+	Subscription: testSubscription{ID: "1", Name: "Production"},
+	Tags:         []string{"prod", "eu"},
+	When:         time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+	hidden:       "x",
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		in   string
+		want Format
+	}{
+		{"", Text},
+		{"text", Text},
+		{"JSON", JSON},
+		{"yaml", YAML},
+		{"tsv", TSV},
+	}
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.in)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got)
+	}
+
+	_, err := ParseFormat("xml")
+	assert.ErrorIs(t, err, pkgerrors.ErrUnknownOutputFormat)
+	assert.False(t, Text.Structured())
+	assert.True(t, TSV.Structured())
+}
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		name   string
+		format Format
+		value  interface{}
+		want   string
+	}{
+		{
+			name:   "json",
+			format: JSON,
+			value:  testValue,
+			want: `{
+  "subscription": {
+    "id": "1",
+    "name": "Production"
+  },
+  "previous": null,
+  "tags": [
+    "prod",
+    "eu"
+  ],
+  "when": "2024-01-02T03:04:05Z"
+}
+`,
+		},
+		{
+			name:   "yaml",
+			format: YAML,
+			value:  testValue,
+			want: `subscription:
+  id: "1"
+  name: Production
+previous: null
+tags:
+  - prod
+  - eu
+when: "2024-01-02T03:04:05Z"
+`,
+		},
+		{
+			name:   "tsv flattens nested values",
+			format: TSV,
+			value:  testValue,
+			want:   "1\tProduction\t\t\tprod,eu\t2024-01-02T03:04:05Z\n",
+		},
+		{
+			name:   "tsv writes a row per item",
+			format: TSV,
+			value:  []testSubscription{{ID: "1", Name: "a"}, {ID: "2", Name: "b"}},
+			want:   "1\ta\n2\tb\n",
+		},
+		{
+			name:   "text renders a table",
+			format: Text,
+			value:  []testSubscription{{ID: "1", Name: "a"}},
+			want:   "ID  NAME\n1   a\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			require.NoError(t, Write(&buf, tt.format, tt.value))
+			assert.Equal(t, tt.want, buf.String())
+		})
+	}
+}
+
+func TestWriteError(t *testing.T) {
+	err := fmt.Errorf("switching: %w", pkgerrors.ErrNoMatch("prod"))
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteError(&buf, JSON, err))
+	assert.JSONEq(t, `{"error": {"code": "subscription_not_found", "message": "switching: no subscription matches \"prod\": subscription not found"}}`, buf.String())
+
+	buf.Reset()
+	require.NoError(t, WriteError(&buf, Text, err))
+	assert.Equal(t, "Error: switching: no subscription matches \"prod\": subscription not found\n", buf.String())
+}
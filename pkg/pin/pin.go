@@ -0,0 +1,171 @@
+// Package pin reads the .aztx files that pin a directory tree to a subscription.
+package pin
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/subscription"
+	"github.com/riweston/aztx/pkg/types"
+	"gopkg.in/yaml.v3"
+)
+
+// FileNames lists the names of pin files, in the order they are looked for in a directory.
+var FileNames = []string{".aztx", ".aztx.yml"}
+
+// Pin is the context a directory tree is pinned to.
+type Pin struct {
+	Subscription string `yaml:"subscription"`     // Subscription ID, name or alias
+	Tenant       string `yaml:"tenant,omitempty"` // Tenant ID or name, to tell subscriptions with the same name apart
+	Path         string `yaml:"-"`                // File the pin was read from
+}
+
+// Parse reads a pin file. It holds either just the subscription, or a YAML mapping
+// with a subscription and an optional tenant:
+//
+//	subscription: Contoso Production
+//	tenant: contoso.onmicrosoft.com
+func Parse(data []byte) (*Pin, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	p := &Pin{}
+	if len(node.Content) > 0 && node.Content[0].Kind == yaml.ScalarNode {
+		p.Subscription = node.Content[0].Value
+	} else if err := node.Decode(p); err != nil {
+		return nil, err
+	}
+	p.Subscription = strings.TrimSpace(p.Subscription)
+	p.Tenant = strings.TrimSpace(p.Tenant)
+	if p.Subscription == "" {
+		return nil, pkgerrors.ErrEmptyPin
+	}
+	return p, nil
+}
+
+// Find looks for a pin file in dir and each of its parents, returning the nearest.
+// Files listed in ignore, such as the aztx configuration in the home directory, which
+// shares the name .aztx.yml, are skipped. It returns nil without an error when no
+// pin file is found.
+func Find(dir string, ignore ...string) (*Pin, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, pkgerrors.ErrFileOperation("resolving directory", err)
+	}
+	skip := make(map[string]bool, len(ignore))
+	for _, path := range ignore {
+		skip[filepath.Clean(path)] = true
+	}
+
+	for {
+		for _, name := range FileNames {
+			path := filepath.Join(dir, name)
+			if skip[path] {
+				continue
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				if os.IsNotExist(err) || isDir(path) {
+					continue
+				}
+				return nil, pkgerrors.ErrFileOperation("reading pin", err)
+			}
+			p, err := Parse(data)
+			if err != nil {
+				return nil, pkgerrors.ErrInvalidPin(path, err)
+			}
+			p.Path = path
+			return p, nil
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil, nil
+		}
+		dir = parent
+	}
+}
+
+// Write saves p as a pin file named .aztx in dir and returns its path.
+func Write(dir string, p Pin) (string, error) {
+	var data []byte
+	if p.Tenant == "" {
+		data = []byte(p.Subscription + "\n")
+	} else {
+		var err error
+		if data, err = yaml.Marshal(p); err != nil {
+			return "", pkgerrors.ErrFileOperation("encoding pin", err)
+		}
+	}
+	path := filepath.Join(dir, FileNames[0])
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return "", pkgerrors.ErrFileOperation("writing pin", err)
+	}
+	return path, nil
+}
+
+// Resolve returns the subscription the pin names. Only exact matches on ID, name or
+// alias count, narrowed to the pinned tenant, matched by ID or name, when one is set.
+// Of several matches the current default is taken, and of the entries of a
+// subscription signed in to with several accounts the one of the current account.
+func (p *Pin) Resolve(sm *subscription.Manager) (*types.Subscription, error) {
+	matches := []types.Subscription{}
+	for _, sub := range sm.Configuration.Subscriptions {
+		if !names(sub, p.Subscription) {
+			continue
+		}
+		if p.Tenant == "" || strings.EqualFold(sub.TenantID.String(), p.Tenant) || strings.EqualFold(sm.TenantName(sub), p.Tenant) {
+			matches = append(matches, sub)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return nil, pkgerrors.ErrNoMatch(p.Subscription)
+	case 1:
+		return &matches[0], nil
+	}
+
+	for i, sub := range matches {
+		if sm.IsCurrent(sub) {
+			return &matches[i], nil
+		}
+	}
+	if current, err := sm.DefaultSubscription(); err == nil && sameSubscription(matches) {
+		for i, sub := range matches {
+			if strings.EqualFold(sub.User.Name, current.User.Name) && strings.EqualFold(sub.User.Type, current.User.Type) {
+				return &matches[i], nil
+			}
+		}
+	}
+
+	labels := make([]string, 0, len(matches))
+	label := subscription.Labeler(matches)
+	for _, sub := range matches {
+		labels = append(labels, label(sub))
+	}
+	return nil, pkgerrors.ErrAmbiguous(p.Subscription, labels)
+}
+
+// sameSubscription reports whether subs are all entries of one subscription, listed
+// once for every account signed in to it.
+func sameSubscription(subs []types.Subscription) bool {
+	for _, sub := range subs {
+		if sub.ID != subs[0].ID {
+			return false
+		}
+	}
+	return true
+}
+
+// names reports whether query is the ID, name or alias of sub, ignoring case.
+func names(sub types.Subscription, query string) bool {
+	return strings.EqualFold(sub.ID.String(), query) || strings.EqualFold(sub.Name, query) ||
+		(sub.Alias != "" && strings.EqualFold(sub.Alias, query))
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,185 @@
+package pin
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/subscription"
+	"github.com/riweston/aztx/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    Pin
+		wantErr error
+	}{
+		{
+			name: "subscription only",
+			data: "Contoso Production\n",
+			want: Pin{Subscription: "Contoso Production"},
+		},
+		{
+			name: "subscription ID",
+			data: "9e7969ef-4cb8-4a2d-959f-bfdaae452a3d",
+			want: Pin{Subscription: "9e7969ef-4cb8-4a2d-959f-bfdaae452a3d"},
+		},
+		{
+			name: "mapping with tenant",
+			data: "subscription: prod\ntenant: Contoso\n",
+			want: Pin{Subscription: "prod", Tenant: "Contoso"},
+		},
+		{
+			name:    "empty file",
+			data:    "",
+			wantErr: pkgerrors.ErrEmptyPin,
+		},
+		{
+			name:    "mapping without subscription",
+			data:    "tenant: Contoso\n",
+			wantErr: pkgerrors.ErrEmptyPin,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse([]byte(tt.data))
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, *got)
+		})
+	}
+
+	_, err := Parse([]byte("subscription: [unclosed"))
+	assert.Error(t, err)
+}
+
+func TestFind(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "project")
+	nested := filepath.Join(project, "infra", "modules")
+	require.NoError(t, os.MkdirAll(nested, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, ".aztx.yml"), []byte("log-level: info\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(project, ".aztx"), []byte("prod\n"), 0644))
+
+	t.Run("nearest parent", func(t *testing.T) {
+		got, err := Find(nested)
+		require.NoError(t, err)
+		require.NotNil(t, got)
+		assert.Equal(t, "prod", got.Subscription)
+		assert.Equal(t, filepath.Join(project, ".aztx"), got.Path)
+	})
+
+	t.Run("ignored files are skipped", func(t *testing.T) {
+		got, err := Find(root, filepath.Join(root, ".aztx.yml"))
+		require.NoError(t, err)
+		assert.Nil(t, got)
+	})
+
+	t.Run("invalid file", func(t *testing.T) {
+		_, err := Find(root)
+		assert.ErrorIs(t, err, pkgerrors.ErrInvalidPinFile)
+	})
+}
+
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	path, err := Write(dir, Pin{Subscription: "prod"})
+	require.NoError(t, err)
+	got, err := Find(dir)
+	require.NoError(t, err)
+	assert.Equal(t, &Pin{Subscription: "prod", Path: path}, got)
+
+	_, err = Write(dir, Pin{Subscription: "Shared", Tenant: "Fabrikam"})
+	require.NoError(t, err)
+	got, err = Find(dir)
+	require.NoError(t, err)
+	assert.Equal(t, &Pin{Subscription: "Shared", Tenant: "Fabrikam", Path: path}, got)
+}
+
+func TestPin_Resolve(t *testing.T) {
+	contoso := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	fabrikam := uuid.MustParse("22222222-2222-2222-2222-222222222222")
+	shared := types.Subscription{ID: uuid.MustParse("9e7969ef-4cb8-4a2d-959f-bfdaae452a3d"), Name: "Shared", TenantID: contoso, Alias: "core"}
+	other := types.Subscription{ID: uuid.MustParse("8aa89ebb-5735-4d1b-9c5c-a8f32a858e99"), Name: "Shared", TenantID: fabrikam}
+	sm := &subscription.Manager{BaseManager: types.BaseManager{Configuration: &types.Configuration{
+		Tenants:       []types.Tenant{{ID: fabrikam, CustomName: "Fabrikam"}},
+		Subscriptions: []types.Subscription{shared, other},
+	}}}
+
+	tests := []struct {
+		name    string
+		pin     Pin
+		want    uuid.UUID
+		wantErr error
+	}{
+		{name: "ID", pin: Pin{Subscription: other.ID.String()}, want: other.ID},
+		{name: "alias", pin: Pin{Subscription: "CORE"}, want: shared.ID},
+		{name: "name and tenant name", pin: Pin{Subscription: "shared", Tenant: "fabrikam"}, want: other.ID},
+		{name: "name and tenant ID", pin: Pin{Subscription: "Shared", Tenant: contoso.String()}, want: shared.ID},
+		{name: "ambiguous name", pin: Pin{Subscription: "Shared"}, wantErr: pkgerrors.ErrAmbiguousQuery},
+		{name: "partial name", pin: Pin{Subscription: "Share"}, wantErr: pkgerrors.ErrSubscriptionNotFound},
+		{name: "wrong tenant", pin: Pin{Subscription: "core", Tenant: "Fabrikam"}, wantErr: pkgerrors.ErrSubscriptionNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.pin.Resolve(sm)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got.ID)
+		})
+	}
+}
+
+func TestPin_Resolve_SeveralAccounts(t *testing.T) {
+	production := uuid.MustParse("9e7969ef-4cb8-4a2d-959f-bfdaae452a3d")
+	entry := func(id uuid.UUID, name, user, userType string) types.Subscription {
+		sub := types.Subscription{ID: id, Name: name}
+		sub.User.Name, sub.User.Type = user, userType
+		return sub
+	}
+	alice := entry(production, "Production", "alice@contoso.com", "user")
+	sp := entry(production, "Production", "deploy-sp", "servicePrincipal")
+	development := entry(uuid.MustParse("8aa89ebb-5735-4d1b-9c5c-a8f32a858e99"), "Development", "alice@contoso.com", "user")
+	bob := entry(development.ID, "Development", "bob@contoso.com", "user")
+
+	tests := []struct {
+		name    string
+		current *types.Subscription
+		want    types.Subscription
+		wantErr error
+	}{
+		{name: "current entry", current: &sp, want: sp},
+		{name: "entry of the current account", current: &development, want: alice},
+		{name: "current account without an entry", current: &bob, wantErr: pkgerrors.ErrAmbiguousQuery},
+		{name: "no default", wantErr: pkgerrors.ErrAmbiguousQuery},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subs := []types.Subscription{alice, sp, development, bob}
+			for i := range subs {
+				subs[i].IsDefault = tt.current != nil && subs[i].Identity() == tt.current.Identity()
+			}
+			sm := &subscription.Manager{BaseManager: types.BaseManager{Configuration: &types.Configuration{Subscriptions: subs}}}
+
+			got, err := (&Pin{Subscription: production.String()}).Resolve(sm)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.Identity(), got.Identity())
+		})
+	}
+}
@@ -17,6 +17,19 @@ import (
 type ConfigurationAdapter struct {
 	storage StorageAdapter
 	logger  Logger
+	state   state.StateManager
+	command string
+	guard   Guard
+	filter  subscription.Filter
+	last    *Switch
+}
+
+// Switch describes a context switch made through the adapter.
+type Switch struct {
+	Subscription types.Subscription  // The new default subscription
+	Tenant       types.Tenant        // Tenant of the new default, with its custom name if any
+	Previous     *types.Subscription // The default that was left, nil when there was none
+	CloudChanged bool                // Whether the Azure CLI was pointed at the subscription's cloud
 }
 
 func NewConfigurationAdapter(storage StorageAdapter, logger Logger) *ConfigurationAdapter {
@@ -26,6 +39,28 @@ func NewConfigurationAdapter(storage StorageAdapter, logger Logger) *Configurati
 	}
 }
 
+// WithHistory makes every context change made through the adapter record the
+// context being left in the state manager, attributed to command.
+func (c *ConfigurationAdapter) WithHistory(sm state.StateManager, command string) *ConfigurationAdapter {
+	c.state = sm
+	c.command = command
+	return c
+}
+
+// WithGuard makes every switch into a protected subscription through the adapter
+// ask g for approval first.
+func (c *ConfigurationAdapter) WithGuard(g Guard) *ConfigurationAdapter {
+	c.guard = g
+	return c
+}
+
+// WithFilter restricts the subscriptions offered by SelectWithFinder to those
+// matching f.
+func (c *ConfigurationAdapter) WithFilter(f subscription.Filter) *ConfigurationAdapter {
+	c.filter = f
+	return c
+}
+
 func (c *ConfigurationAdapter) SelectWithFinder() (*types.Subscription, error) {
 	if c.storage == nil {
 		c.logger.Error("storage adapter is nil")
@@ -45,7 +80,11 @@ func (c *ConfigurationAdapter) SelectWithFinder() (*types.Subscription, error) {
 	}
 
 	c.logger.Debug("initiating subscription selection with fuzzy finder")
-	subManager := subscription.Manager{BaseManager: types.BaseManager{Configuration: config}}
+	subManager, err := (&subscription.Manager{BaseManager: types.BaseManager{Configuration: config}}).Narrow(c.filter)
+	if err != nil {
+		c.logger.Warn("no subscriptions match the filters")
+		return nil, err
+	}
 	idx, err := subManager.FindSubscriptionIndex()
 	if err != nil {
 		if errors.Is(err, fuzzyfinder.ErrAbort) {
@@ -55,16 +94,30 @@ func (c *ConfigurationAdapter) SelectWithFinder() (*types.Subscription, error) {
 		return nil, pkgerrors.WrapError("finding subscription", err)
 	}
 
-	if idx < 0 || idx >= len(config.Subscriptions) {
+	if idx < 0 || idx >= len(subManager.Configuration.Subscriptions) {
 		c.logger.Error("selected subscription index %d is out of bounds", idx)
 		return nil, pkgerrors.ErrSubscriptionNotFound
 	}
 
-	selected := &config.Subscriptions[idx]
+	selected := &subManager.Configuration.Subscriptions[idx]
 	return selected, nil
 }
 
-func (c *ConfigurationAdapter) SetContext(subscriptionID uuid.UUID) error {
+// SetContext marks the profile entry with the given identity as the default in the
+// Azure profile. An identity without a user picks the first entry of the subscription.
+// When the adapter has a state manager, the context being left is recorded in its
+// history.
+func (c *ConfigurationAdapter) SetContext(id types.Identity) error {
+	if err := c.approve(id); err != nil {
+		return err
+	}
+	return c.withLock(func() error {
+		return c.setContext(id, c.state, c.command)
+	})
+}
+
+func (c *ConfigurationAdapter) setContext(id types.Identity, sm state.StateManager, command string) error {
+	subscriptionID := id.SubscriptionID
 	if subscriptionID == uuid.Nil {
 		c.logger.Error("invalid subscription ID provided")
 		return pkgerrors.ErrInvalidSubscriptionID
@@ -80,7 +133,7 @@ func (c *ConfigurationAdapter) SetContext(subscriptionID uuid.UUID) error {
 	// First verify the target subscription exists
 	var targetIndex = -1
 	for i, sub := range config.Subscriptions {
-		if sub.ID == subscriptionID {
+		if id.Matches(sub) {
 			targetIndex = i
 			break
 		}
@@ -91,9 +144,19 @@ func (c *ConfigurationAdapter) SetContext(subscriptionID uuid.UUID) error {
 		return pkgerrors.ErrSubscriptionNotFound
 	}
 
-	// Now that we know the target exists, safely update the default flags
+	// The context being left is the default of the cloud the Azure CLI targets
+	subManager := subscription.Manager{BaseManager: types.BaseManager{Configuration: config}}
+	var previous *types.Subscription
+	if current, err := subManager.DefaultSubscription(); err == nil {
+		left := *current
+		previous = &left
+	}
+
+	// Now that we know the target exists, safely update the default flags. The Azure
+	// CLI keeps a default per cloud, so defaults in other clouds are left alone.
+	cloud := config.Subscriptions[targetIndex].EnvironmentName
 	for i := range config.Subscriptions {
-		if config.Subscriptions[i].IsDefault {
+		if config.Subscriptions[i].IsDefault && subscription.SameCloud(config.Subscriptions[i].EnvironmentName, cloud) {
 			c.logger.Debug("clearing default from subscription: %s", config.Subscriptions[i].Name)
 			config.Subscriptions[i].IsDefault = false
 		}
@@ -102,62 +165,214 @@ func (c *ConfigurationAdapter) SetContext(subscriptionID uuid.UUID) error {
 	c.logger.Debug("setting new default subscription: %s", config.Subscriptions[targetIndex].Name)
 	config.Subscriptions[targetIndex].IsDefault = true
 
+	// The cloud is switched first, so that a failure to switch it leaves the default
+	// where it was, and switched back when the configuration cannot be written.
+	cloudChanged, err := c.switchCloud(cloud)
+	if err != nil {
+		return err
+	}
+
 	c.logger.Debug("writing updated configuration")
 	if err := c.storage.WriteConfig(config); err != nil {
 		c.logger.Error("failed to write configuration: %v", err)
+		if cloudChanged {
+			c.restoreCloud(config.ActiveCloud)
+		}
 		return pkgerrors.WrapError("writing configuration", err)
 	}
 
-	c.logger.Success("switched context to: %s (%s)", config.Subscriptions[targetIndex].Name, subscriptionID)
+	target := config.Subscriptions[targetIndex]
+	if severalAccounts(config, subscriptionID) {
+		c.logger.Success("switched context to: %s (%s) as %s", target.Name, subscriptionID, target.User.Name)
+	} else {
+		c.logger.Success("switched context to: %s (%s)", target.Name, subscriptionID)
+	}
+	c.last = &Switch{
+		Subscription: config.Subscriptions[targetIndex],
+		Tenant:       tenantOf(config, config.Subscriptions[targetIndex]),
+		Previous:     previous,
+		CloudChanged: cloudChanged,
+	}
+
+	if sm != nil && previous != nil && previous.Identity() != config.Subscriptions[targetIndex].Identity() {
+		return c.recordContext(sm, previous, command)
+	}
 	return nil
 }
 
-func (c *ConfigurationAdapter) SetPreviousContext(state state.StateManager) error {
-	if state == nil {
-		c.logger.Error("state manager is nil")
-		return pkgerrors.ErrInvalidContext
+// restoreCloud points the Azure CLI back at the cloud it targeted before a switch
+// that could not be completed.
+func (c *ConfigurationAdapter) restoreCloud(cloud string) {
+	if _, err := c.switchCloud(cloud); err != nil {
+		c.logger.Error("the Azure CLI was left targeting another cloud than its default subscription, run az cloud set --name %s", cloud)
 	}
+}
 
-	lastId, lastName := state.GetLastContext()
-	if lastId == "" || lastName == "" {
-		c.logger.Warn("no previous context found")
-		return pkgerrors.ErrNoPreviousContext
+// switchCloud points the Azure CLI at the cloud of the new default, so that az does
+// not keep targeting another cloud. Storage that does not hold the CLI's settings and
+// subscriptions without a cloud are left alone.
+func (c *ConfigurationAdapter) switchCloud(cloud string) (bool, error) {
+	switcher, ok := c.storage.(CloudSwitcher)
+	if !ok || cloud == "" {
+		return false, nil
+	}
+	changed, err := switcher.SetActiveCloud(cloud)
+	if err != nil {
+		c.logger.Error("failed to set the active cloud: %v", err)
+		return false, pkgerrors.WrapError("setting active cloud", err)
 	}
+	if changed {
+		c.logger.Info("switched Azure CLI cloud to: %s", cloud)
+	}
+	return changed, nil
+}
 
-	c.logger.Debug("reading configuration to switch to previous context")
+// severalAccounts reports whether the profile lists a subscription for more than one account.
+func severalAccounts(config *types.Configuration, subscriptionID uuid.UUID) bool {
+	entries := 0
+	for _, sub := range config.Subscriptions {
+		if sub.ID == subscriptionID {
+			entries++
+		}
+	}
+	return entries > 1
+}
+
+// approve asks the guard to approve a switch into a protected subscription. It runs
+// before the lock is taken, so that other processes are not kept waiting while the
+// user confirms.
+func (c *ConfigurationAdapter) approve(id types.Identity) error {
+	if c.guard == nil {
+		return nil
+	}
 	config, err := c.storage.ReadConfig()
 	if err != nil {
 		c.logger.Error("failed to read configuration: %v", err)
 		return pkgerrors.WrapError("reading configuration", err)
 	}
-
-	var currentDefault *types.Subscription
+	subManager := subscription.Manager{BaseManager: types.BaseManager{Configuration: config}}
 	for _, sub := range config.Subscriptions {
-		if sub.IsDefault {
-			currentDefault = &sub
-			break
+		if id.Matches(sub) {
+			if !sub.Protected || subManager.IsCurrent(sub) {
+				return nil
+			}
+			c.logger.Debug("asking for approval to switch to protected subscription: %s", sub.Name)
+			return c.guard.Approve(sub)
 		}
 	}
+	return nil
+}
 
-	if currentDefault == nil {
-		c.logger.Error("no default subscription found in configuration")
-		return pkgerrors.ErrNoDefaultSubscription
+// LastSwitch returns the most recent context switch made through the adapter, or nil.
+func (c *ConfigurationAdapter) LastSwitch() *Switch {
+	return c.last
+}
+
+// tenantOf returns the tenant of a subscription, named by the account used to reach
+// it and by its custom name when the configuration has one.
+func tenantOf(config *types.Configuration, sub types.Subscription) types.Tenant {
+	t := types.Tenant{ID: sub.TenantID, Name: sub.User.Name}
+	for _, known := range config.Tenants {
+		if known.ID == sub.TenantID {
+			t.CustomName = known.CustomName
+			break
+		}
 	}
+	return t
+}
 
-	c.logger.Debug("saving current context: %s", currentDefault.Name)
-	if err := state.SetLastContext(currentDefault.ID.String(), currentDefault.Name); err != nil {
-		c.logger.Error("failed to save current context: %v", err)
+// recordContext saves the context that was left as the most recent history entry,
+// with the config directory of the adapter's storage when it has one.
+func (c *ConfigurationAdapter) recordContext(sm state.StateManager, left *types.Subscription, command string) error {
+	c.logger.Debug("saving previous context: %s", left.Name)
+	var configDir string
+	if locator, ok := c.storage.(ConfigDirLocator); ok {
+		configDir = locator.BaseConfigDir()
+	}
+	if err := sm.PushHistory(state.HistoryEntry{
+		Timestamp:        time.Now(),
+		SubscriptionID:   left.ID.String(),
+		SubscriptionName: left.Name,
+		TenantID:         left.TenantID.String(),
+		User:             left.User.Name,
+		UserType:         left.User.Type,
+		ConfigDir:        configDir,
+		Command:          command,
+	}); err != nil {
+		c.logger.Error("failed to save previous context: %v", err)
 		return pkgerrors.WrapError("saving last context", err)
 	}
+	return nil
+}
+
+// SetPreviousContext switches back to the context recorded by the state manager,
+// recording the current context in its place.
+func (c *ConfigurationAdapter) SetPreviousContext(state state.StateManager) error {
+	return c.SetHistoryContext(state, 1, "aztx -")
+}
+
+// SetHistoryContext switches to the context left steps switches ago, where 1 is the
+// previous context, and records the current context as the most recent history
+// entry. command describes what triggered the switch.
+func (c *ConfigurationAdapter) SetHistoryContext(sm state.StateManager, steps int, command string) error {
+	if sm == nil {
+		c.logger.Error("state manager is nil")
+		return pkgerrors.ErrInvalidContext
+	}
 
-	id, err := uuid.Parse(lastId)
+	id, name, err := c.historyTarget(sm, steps)
+	if err != nil {
+		return err
+	}
+	if err := c.approve(id); err != nil {
+		return err
+	}
+	return c.withLock(func() error {
+		return c.setHistoryContext(sm, id, name, command)
+	})
+}
+
+// historyTarget returns the context left steps switches ago.
+func (c *ConfigurationAdapter) historyTarget(sm state.StateManager, steps int) (types.Identity, string, error) {
+	var entry state.HistoryEntry
+	history := sm.History()
+	if steps >= 1 && steps <= len(history) {
+		entry = history[steps-1]
+	} else if steps == 1 {
+		entry.SubscriptionID, entry.SubscriptionName = sm.GetLastContext()
+	} else {
+		c.logger.Warn("history has %d entries, cannot go back %d", len(history), steps)
+		return types.Identity{}, "", pkgerrors.ErrHistoryOutOfRange(steps, len(history))
+	}
+	if entry.SubscriptionID == "" || entry.SubscriptionName == "" {
+		c.logger.Warn("no previous context found")
+		return types.Identity{}, "", pkgerrors.ErrNoPreviousContext
+	}
+
+	id, err := entry.Identity()
 	if err != nil {
 		c.logger.Error("failed to parse previous subscription ID: %v", err)
-		return pkgerrors.WrapError("parsing subscription ID", err)
+		return types.Identity{}, "", pkgerrors.WrapError("parsing subscription ID", err)
 	}
+	return id, entry.SubscriptionName, nil
+}
 
-	c.logger.Debug("switching to previous context: %s", lastName)
-	return c.SetContext(id)
+func (c *ConfigurationAdapter) setHistoryContext(sm state.StateManager, id types.Identity, name, command string) error {
+	c.logger.Debug("reading configuration to switch to previous context")
+	config, err := c.storage.ReadConfig()
+	if err != nil {
+		c.logger.Error("failed to read configuration: %v", err)
+		return pkgerrors.WrapError("reading configuration", err)
+	}
+
+	subManager := subscription.Manager{BaseManager: types.BaseManager{Configuration: config}}
+	if _, err := subManager.DefaultSubscription(); err != nil {
+		c.logger.Error("no default subscription found in configuration")
+		return pkgerrors.ErrNoDefaultSubscription
+	}
+
+	c.logger.Debug("switching to previous context: %s", name)
+	return c.setContext(id, sm, command)
 }
 
 func (c *ConfigurationAdapter) SaveTenant(id uuid.UUID, name string) error {
@@ -169,21 +384,47 @@ func (c *ConfigurationAdapter) SaveTenant(id uuid.UUID, name string) error {
 		return pkgerrors.ErrEmptyTenantName
 	}
 
-	config, err := c.storage.ReadConfig()
-	if err != nil {
-		return pkgerrors.WrapError("reading configuration", err)
-	}
+	return c.withLock(func() error {
+		config, err := c.storage.ReadConfig()
+		if err != nil {
+			return pkgerrors.WrapError("reading configuration", err)
+		}
 
-	tenantManager := tenant.Manager{BaseManager: types.BaseManager{Configuration: config}}
-	if err := tenantManager.SaveTenantName(id, name); err != nil {
-		return pkgerrors.WrapError("saving tenant name", err)
-	}
+		tenantManager := tenant.Manager{BaseManager: types.BaseManager{Configuration: config}}
+		if err := tenantManager.SaveTenantName(id, name); err != nil {
+			return pkgerrors.WrapError("saving tenant name", err)
+		}
 
-	if err := c.storage.WriteConfig(config); err != nil {
-		return pkgerrors.WrapError("writing configuration", err)
-	}
+		if err := c.storage.WriteConfig(config); err != nil {
+			return pkgerrors.WrapError("writing configuration", err)
+		}
 
-	return nil
+		return nil
+	})
+}
+
+// ClearTenantName removes the custom name of a tenant. It returns false when the
+// tenant had no custom name, in which case the profile is left untouched.
+func (c *ConfigurationAdapter) ClearTenantName(id uuid.UUID) (bool, error) {
+	var cleared bool
+	err := c.withLock(func() error {
+		config, err := c.storage.ReadConfig()
+		if err != nil {
+			return pkgerrors.WrapError("reading configuration", err)
+		}
+
+		tm := tenant.Manager{BaseManager: types.BaseManager{Configuration: config}}
+		cleared, err = tm.ClearTenantName(id)
+		if err != nil || !cleared {
+			return err
+		}
+
+		if err := c.storage.WriteConfig(config); err != nil {
+			return pkgerrors.WrapError("writing configuration", err)
+		}
+		return nil
+	})
+	return cleared, err
 }
 
 // Add context to key operations
@@ -196,13 +437,13 @@ func (c *ConfigurationAdapter) SelectWithFinderContext(ctx context.Context) (*ty
 	}
 }
 
-func (c *ConfigurationAdapter) SetContextWithTimeout(subscriptionID uuid.UUID, timeout time.Duration) error {
+func (c *ConfigurationAdapter) SetContextWithTimeout(id types.Identity, timeout time.Duration) error {
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	done := make(chan error, 1)
 	go func() {
-		done <- c.SetContext(subscriptionID)
+		done <- c.SetContext(id)
 	}()
 
 	select {
@@ -225,6 +466,12 @@ func (c *ConfigurationAdapter) GetTenantManager() (*tenant.Manager, error) {
 
 // SaveTenantName saves a custom name for a tenant
 func (c *ConfigurationAdapter) SaveTenantName(id uuid.UUID, name string) error {
+	return c.withLock(func() error {
+		return c.saveTenantName(id, name)
+	})
+}
+
+func (c *ConfigurationAdapter) saveTenantName(id uuid.UUID, name string) error {
 	// Read the latest configuration
 	config, err := c.storage.ReadConfig()
 	if err != nil {
@@ -248,3 +495,27 @@ func (c *ConfigurationAdapter) SaveTenantName(id uuid.UUID, name string) error {
 	c.logger.Success("saved custom name '%s' for tenant %s", name, id)
 	return nil
 }
+
+// withLock runs fn while holding the storage lock, so that the read-modify-write
+// cycle in fn cannot interleave with another process. Storage adapters that do not
+// support locking run fn directly.
+func (c *ConfigurationAdapter) withLock(fn func() error) error {
+	locker, ok := c.storage.(Locker)
+	if !ok {
+		return fn()
+	}
+
+	c.logger.Debug("acquiring configuration lock")
+	unlock, err := locker.Lock()
+	if err != nil {
+		c.logger.Error("failed to lock configuration: %v", err)
+		return pkgerrors.WrapError("locking configuration", err)
+	}
+	defer func() {
+		if err := unlock(); err != nil {
+			c.logger.Warn("failed to release configuration lock: %v", err)
+		}
+	}()
+
+	return fn()
+}
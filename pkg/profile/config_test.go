@@ -0,0 +1,477 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/protect"
+	"github.com/riweston/aztx/pkg/state"
+	"github.com/riweston/aztx/pkg/storage"
+	"github.com/riweston/aztx/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestStorage copies the sample profile into a temporary directory.
+func newTestStorage(t *testing.T) *storage.FileAdapter {
+	t.Helper()
+	data, err := os.ReadFile("azureProfile.json")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "azureProfile.json")
+	require.NoError(t, os.WriteFile(path, data, 0644))
+	return &storage.FileAdapter{Path: path, LockTimeout: 10 * time.Second}
+}
+
+func TestConfigurationAdapter_SaveTenantName_Concurrent(t *testing.T) {
+	fa := newTestStorage(t)
+	names := map[uuid.UUID]string{
+		uuid.MustParse("11111111-1111-1111-1111-111111111111"): "contoso",
+		uuid.MustParse("22222222-2222-2222-2222-222222222222"): "fabrikam",
+		uuid.MustParse("33333333-3333-3333-3333-333333333333"): "acme",
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, len(names))
+	for id, name := range names {
+		wg.Add(1)
+		go func(id uuid.UUID, name string) {
+			defer wg.Done()
+			// Each goroutine uses its own adapter, as separate processes would.
+			adapter := NewConfigurationAdapter(&storage.FileAdapter{Path: fa.Path, LockTimeout: fa.LockTimeout}, NewLogger("error"))
+			errs <- adapter.SaveTenantName(id, name)
+		}(id, name)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		require.NoError(t, err)
+	}
+
+	cfg, err := fa.ReadConfig()
+	require.NoError(t, err)
+	saved := make(map[uuid.UUID]string)
+	for _, tenant := range cfg.Tenants {
+		saved[tenant.ID] = tenant.CustomName
+	}
+	assert.Equal(t, names, saved, "no tenant name should be lost")
+}
+
+func TestConfigurationAdapter_SetContext_WaitsForLock(t *testing.T) {
+	fa := newTestStorage(t)
+	target := uuid.MustParse("8aa89ebb-5735-4d1b-9c5c-a8f32a858e99")
+
+	unlock, err := fa.Lock()
+	require.NoError(t, err)
+
+	done := make(chan error, 1)
+	go func() {
+		adapter := NewConfigurationAdapter(&storage.FileAdapter{Path: fa.Path, LockTimeout: fa.LockTimeout}, NewLogger("error"))
+		done <- adapter.SetContext(types.Identity{SubscriptionID: target})
+	}()
+
+	select {
+	case err := <-done:
+		t.Fatalf("SetContext returned while the lock was held: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, unlock())
+	require.NoError(t, <-done)
+
+	cfg, err := fa.ReadConfig()
+	require.NoError(t, err)
+	for _, sub := range cfg.Subscriptions {
+		assert.Equal(t, sub.ID == target, sub.IsDefault, sub.Name)
+	}
+}
+
+func TestConfigurationAdapter_SetContext_LockTimeout(t *testing.T) {
+	fa := newTestStorage(t)
+
+	unlock, err := fa.Lock()
+	require.NoError(t, err)
+	defer unlock()
+
+	adapter := NewConfigurationAdapter(&storage.FileAdapter{Path: fa.Path, LockTimeout: 50 * time.Millisecond}, NewLogger("error"))
+	err = adapter.SetContext(types.Identity{SubscriptionID: uuid.MustParse("8aa89ebb-5735-4d1b-9c5c-a8f32a858e99")})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "locked by process")
+}
+
+// memoryState is an in-memory state.StateManager for tests.
+type memoryState struct {
+	history []state.HistoryEntry
+}
+
+func (m *memoryState) GetLastContext() (string, string) {
+	if len(m.history) == 0 {
+		return "", ""
+	}
+	return m.history[0].SubscriptionID, m.history[0].SubscriptionName
+}
+
+func (m *memoryState) SetLastContext(id string, name string) error {
+	return m.PushHistory(state.HistoryEntry{SubscriptionID: id, SubscriptionName: name})
+}
+
+func (m *memoryState) History() []state.HistoryEntry {
+	return m.history
+}
+
+func (m *memoryState) PushHistory(entry state.HistoryEntry) error {
+	m.history = append([]state.HistoryEntry{entry}, m.history...)
+	return nil
+}
+
+// defaultSubscription returns the name of the default subscription in fa.
+func defaultSubscription(t *testing.T, fa *storage.FileAdapter) string {
+	t.Helper()
+	cfg, err := fa.ReadConfig()
+	require.NoError(t, err)
+	for _, sub := range cfg.Subscriptions {
+		if sub.IsDefault {
+			return sub.Name
+		}
+	}
+	return ""
+}
+
+func TestConfigurationAdapter_SetHistoryContext(t *testing.T) {
+	fa := newTestStorage(t)
+	adapter := NewConfigurationAdapter(fa, NewLogger("error"))
+	require.NoError(t, adapter.SetContext(types.Identity{SubscriptionID: uuid.MustParse("9e7969ef-4cb8-4a2d-959f-bfdaae452a3d")}))
+
+	sm := &memoryState{history: []state.HistoryEntry{
+		{SubscriptionID: "8aa89ebb-5735-4d1b-9c5c-a8f32a858e99", SubscriptionName: "Development Environment"},
+		{SubscriptionID: "8fff24dd-2842-4dbb-8a66-1410c7bc231f", SubscriptionName: "Acme Corp Main"},
+	}}
+
+	require.NoError(t, adapter.SetHistoryContext(sm, 2, "aztx -2"))
+	assert.Equal(t, "Acme Corp Main", defaultSubscription(t, fa))
+	require.Len(t, sm.history, 3)
+	assert.Equal(t, "Production Workloads", sm.history[0].SubscriptionName)
+	assert.Equal(t, "11111111-1111-1111-1111-111111111111", sm.history[0].TenantID)
+	assert.Equal(t, "aztx -2", sm.history[0].Command)
+	assert.False(t, sm.history[0].Timestamp.IsZero())
+
+	require.NoError(t, adapter.SetPreviousContext(sm))
+	assert.Equal(t, "Production Workloads", defaultSubscription(t, fa))
+	assert.Equal(t, "Acme Corp Main", sm.history[0].SubscriptionName)
+}
+
+func TestConfigurationAdapter_SetHistoryContext_OutOfRange(t *testing.T) {
+	fa := newTestStorage(t)
+	adapter := NewConfigurationAdapter(fa, NewLogger("error"))
+
+	err := adapter.SetHistoryContext(&memoryState{}, 3, "aztx -3")
+	assert.ErrorIs(t, err, pkgerrors.ErrNoPreviousContext)
+
+	err = adapter.SetPreviousContext(&memoryState{})
+	assert.ErrorIs(t, err, pkgerrors.ErrNoPreviousContext)
+}
+
+func TestConfigurationAdapter_SetContext_RecordsPreviousContext(t *testing.T) {
+	production := uuid.MustParse("9e7969ef-4cb8-4a2d-959f-bfdaae452a3d")
+	fabrikam := uuid.MustParse("9bb28eee-ebaa-442a-83ba-5511810fb151")
+
+	// Each switch path in the CLI creates its adapter with its own command.
+	tests := []struct {
+		name    string
+		command string
+	}{
+		{name: "finder", command: "aztx"},
+		{name: "by tenant", command: "aztx --by-tenant"},
+		{name: "direct argument", command: "aztx Fabrikam Production"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fa := newTestStorage(t)
+			require.NoError(t, NewConfigurationAdapter(fa, NewLogger("error")).SetContext(types.Identity{SubscriptionID: production}))
+
+			sm := &memoryState{}
+			adapter := NewConfigurationAdapter(fa, NewLogger("error")).WithHistory(sm, tt.command)
+			require.NoError(t, adapter.SetContext(types.Identity{SubscriptionID: fabrikam}))
+
+			assert.Equal(t, "Fabrikam Production", defaultSubscription(t, fa))
+			require.Len(t, sm.history, 1)
+			assert.Equal(t, production.String(), sm.history[0].SubscriptionID)
+			assert.Equal(t, "Production Workloads", sm.history[0].SubscriptionName)
+			assert.Equal(t, "11111111-1111-1111-1111-111111111111", sm.history[0].TenantID)
+			assert.Equal(t, tt.command, sm.history[0].Command)
+			assert.Equal(t, fa.ConfigDir(), sm.history[0].ConfigDir, "the entry remembers the config dir it was used in")
+
+			// "aztx -" now returns to where the switch came from.
+			require.NoError(t, adapter.SetPreviousContext(sm))
+			assert.Equal(t, "Production Workloads", defaultSubscription(t, fa))
+			assert.Equal(t, "Fabrikam Production", sm.history[0].SubscriptionName)
+		})
+	}
+}
+
+func TestConfigurationAdapter_SetContext_SkipsRecordingWithoutChange(t *testing.T) {
+	production := uuid.MustParse("9e7969ef-4cb8-4a2d-959f-bfdaae452a3d")
+
+	t.Run("no previous default", func(t *testing.T) {
+		fa := newTestStorage(t)
+		sm := &memoryState{}
+		require.NoError(t, NewConfigurationAdapter(fa, NewLogger("error")).WithHistory(sm, "aztx").SetContext(types.Identity{SubscriptionID: production}))
+		assert.Empty(t, sm.history)
+	})
+
+	t.Run("same subscription", func(t *testing.T) {
+		fa := newTestStorage(t)
+		sm := &memoryState{}
+		adapter := NewConfigurationAdapter(fa, NewLogger("error")).WithHistory(sm, "aztx")
+		require.NoError(t, adapter.SetContext(types.Identity{SubscriptionID: production}))
+		require.NoError(t, adapter.SetContext(types.Identity{SubscriptionID: production}))
+		assert.Empty(t, sm.history)
+	})
+}
+
+// recordingGuard is a Guard that records the subscriptions it is asked about.
+type recordingGuard struct {
+	asked []string
+	err   error
+}
+
+func (g *recordingGuard) Approve(sub types.Subscription) error {
+	g.asked = append(g.asked, sub.Name)
+	return g.err
+}
+
+func TestConfigurationAdapter_SetContext_Guard(t *testing.T) {
+	production := uuid.MustParse("9e7969ef-4cb8-4a2d-959f-bfdaae452a3d")
+	development := uuid.MustParse("8aa89ebb-5735-4d1b-9c5c-a8f32a858e99")
+	rules := protect.Rules{{Name: "production*"}}
+
+	t.Run("approved", func(t *testing.T) {
+		fa := newTestStorage(t)
+		guard := &recordingGuard{}
+		adapter := NewConfigurationAdapter(&MetadataStorage{StorageAdapter: fa, Rules: rules}, NewLogger("error")).WithGuard(guard)
+		require.NoError(t, adapter.SetContext(types.Identity{SubscriptionID: development}))
+		require.NoError(t, adapter.SetContext(types.Identity{SubscriptionID: production}))
+		assert.Equal(t, []string{"Production Workloads"}, guard.asked, "only protected subscriptions need approval")
+		assert.Equal(t, "Production Workloads", defaultSubscription(t, fa))
+
+		require.NoError(t, adapter.SetContext(types.Identity{SubscriptionID: production}))
+		assert.Len(t, guard.asked, 1, "staying in a protected subscription needs no approval")
+	})
+
+	t.Run("refused", func(t *testing.T) {
+		fa := newTestStorage(t)
+		guard := &recordingGuard{err: pkgerrors.ErrConfirmationFailed}
+		adapter := NewConfigurationAdapter(&MetadataStorage{StorageAdapter: fa, Rules: rules}, NewLogger("error")).WithGuard(guard)
+		require.NoError(t, adapter.SetContext(types.Identity{SubscriptionID: development}))
+		assert.ErrorIs(t, adapter.SetContext(types.Identity{SubscriptionID: production}), pkgerrors.ErrConfirmationFailed)
+		assert.Equal(t, "Development Environment", defaultSubscription(t, fa))
+
+		sm := &memoryState{history: []state.HistoryEntry{{SubscriptionID: production.String(), SubscriptionName: "Production Workloads"}}}
+		assert.ErrorIs(t, adapter.SetPreviousContext(sm), pkgerrors.ErrConfirmationFailed)
+		assert.Equal(t, "Development Environment", defaultSubscription(t, fa))
+		assert.Len(t, sm.history, 1)
+	})
+}
+
+func TestConfigurationAdapter_SetContext_SwitchesCloud(t *testing.T) {
+	fa := newTestStorage(t)
+	gov := uuid.MustParse("7cc65eaa-f64e-442a-8b8a-3211810ac151")
+	cfg, err := fa.ReadConfig()
+	require.NoError(t, err)
+	for i := range cfg.Subscriptions {
+		if cfg.Subscriptions[i].ID == gov {
+			cfg.Subscriptions[i].EnvironmentName = "AzureUSGovernment"
+		}
+	}
+	require.NoError(t, fa.WriteConfig(cfg))
+
+	settings := filepath.Join(fa.ConfigDir(), storage.CLIConfigFileName)
+	require.NoError(t, os.WriteFile(settings, []byte("[core]\noutput = table\n"), 0600))
+
+	adapter := NewConfigurationAdapter(fa, NewLogger("error"))
+	require.NoError(t, adapter.SetContext(types.Identity{SubscriptionID: gov}))
+	assert.True(t, adapter.LastSwitch().CloudChanged)
+	data, err := os.ReadFile(settings)
+	require.NoError(t, err)
+	assert.Equal(t, "[core]\noutput = table\n\n[cloud]\nname = AzureUSGovernment\n", string(data))
+
+	require.NoError(t, adapter.SetContext(types.Identity{SubscriptionID: uuid.MustParse("8aa89ebb-5735-4d1b-9c5c-a8f32a858e99")}))
+	assert.True(t, adapter.LastSwitch().CloudChanged)
+	cloud, err := fa.ActiveCloud()
+	require.NoError(t, err)
+	assert.Equal(t, "AzureCloud", cloud)
+
+	require.NoError(t, adapter.SetContext(types.Identity{SubscriptionID: uuid.MustParse("9e7969ef-4cb8-4a2d-959f-bfdaae452a3d")}))
+	assert.False(t, adapter.LastSwitch().CloudChanged, "switching within a cloud leaves it alone")
+}
+
+// faultyStorage is a file adapter that fails to write the profile or to switch the
+// cloud when told to.
+type faultyStorage struct {
+	*storage.FileAdapter
+	writeErr, cloudErr error
+}
+
+func (f faultyStorage) WriteConfig(config *types.Configuration) error {
+	if f.writeErr != nil {
+		return f.writeErr
+	}
+	return f.FileAdapter.WriteConfig(config)
+}
+
+func (f faultyStorage) SetActiveCloud(name string) (bool, error) {
+	if f.cloudErr != nil {
+		return false, f.cloudErr
+	}
+	return f.FileAdapter.SetActiveCloud(name)
+}
+
+func TestConfigurationAdapter_SetContext_NoPartialSwitch(t *testing.T) {
+	gov := uuid.MustParse("7cc65eaa-f64e-442a-8b8a-3211810ac151")
+	setup := func(t *testing.T) *storage.FileAdapter {
+		fa := newTestStorage(t)
+		cfg, err := fa.ReadConfig()
+		require.NoError(t, err)
+		for i := range cfg.Subscriptions {
+			if cfg.Subscriptions[i].ID == gov {
+				cfg.Subscriptions[i].EnvironmentName = "AzureUSGovernment"
+			}
+			cfg.Subscriptions[i].IsDefault = i == 0
+		}
+		require.NoError(t, fa.WriteConfig(cfg))
+		return fa
+	}
+	isDefault := func(t *testing.T, fa *storage.FileAdapter) bool {
+		cfg, err := fa.ReadConfig()
+		require.NoError(t, err)
+		for _, sub := range cfg.Subscriptions {
+			if sub.ID == gov {
+				return sub.IsDefault
+			}
+		}
+		return false
+	}
+
+	t.Run("cloud cannot be switched", func(t *testing.T) {
+		fa := setup(t)
+		err := NewConfigurationAdapter(faultyStorage{FileAdapter: fa, cloudErr: os.ErrPermission}, NewLogger("error")).SetContext(types.Identity{SubscriptionID: gov})
+		require.ErrorIs(t, err, os.ErrPermission)
+		assert.False(t, isDefault(t, fa), "the default is left where it was")
+	})
+
+	t.Run("profile cannot be written", func(t *testing.T) {
+		fa := setup(t)
+		err := NewConfigurationAdapter(faultyStorage{FileAdapter: fa, writeErr: os.ErrPermission}, NewLogger("error")).SetContext(types.Identity{SubscriptionID: gov})
+		require.ErrorIs(t, err, os.ErrPermission)
+		assert.False(t, isDefault(t, fa))
+		cloud, err := fa.ActiveCloud()
+		require.NoError(t, err)
+		assert.Equal(t, "AzureCloud", cloud, "the cloud is switched back")
+	})
+}
+
+func TestConfigurationAdapter_SetContext_KeepsDefaultPerCloud(t *testing.T) {
+	fa := newTestStorage(t)
+	var (
+		publicDefault = uuid.MustParse("9e7969ef-4cb8-4a2d-959f-bfdaae452a3d")
+		public        = uuid.MustParse("8aa89ebb-5735-4d1b-9c5c-a8f32a858e99")
+		govDefault    = uuid.MustParse("9bb28eee-ebaa-442a-83ba-5511810fb151")
+		gov           = uuid.MustParse("7cc65eaa-f64e-442a-8b8a-3211810ac151")
+	)
+	cfg, err := fa.ReadConfig()
+	require.NoError(t, err)
+	for i, sub := range cfg.Subscriptions {
+		if sub.ID == govDefault || sub.ID == gov {
+			cfg.Subscriptions[i].EnvironmentName = "AzureUSGovernment"
+		}
+		cfg.Subscriptions[i].IsDefault = sub.ID == publicDefault || sub.ID == govDefault
+	}
+	require.NoError(t, fa.WriteConfig(cfg))
+
+	defaults := func() []uuid.UUID {
+		cfg, err := fa.ReadConfig()
+		require.NoError(t, err)
+		var ids []uuid.UUID
+		for _, sub := range cfg.Subscriptions {
+			if sub.IsDefault {
+				ids = append(ids, sub.ID)
+			}
+		}
+		return ids
+	}
+
+	sm := &memoryState{}
+	adapter := NewConfigurationAdapter(fa, NewLogger("error")).WithHistory(sm, "test")
+
+	require.NoError(t, adapter.SetContext(types.Identity{SubscriptionID: public}))
+	assert.ElementsMatch(t, []uuid.UUID{public, govDefault}, defaults(), "the government default is kept")
+	assert.Equal(t, publicDefault, adapter.LastSwitch().Previous.ID)
+
+	require.NoError(t, adapter.SetContext(types.Identity{SubscriptionID: gov}))
+	assert.ElementsMatch(t, []uuid.UUID{public, gov}, defaults(), "the public default is kept")
+	assert.Equal(t, public, adapter.LastSwitch().Previous.ID, "the context left is the default of the active cloud")
+	assert.True(t, adapter.LastSwitch().CloudChanged)
+
+	require.NoError(t, adapter.SetPreviousContext(sm))
+	assert.ElementsMatch(t, []uuid.UUID{public, gov}, defaults())
+	assert.Equal(t, public, adapter.LastSwitch().Subscription.ID)
+	assert.Equal(t, gov, adapter.LastSwitch().Previous.ID)
+	cloud, err := fa.ActiveCloud()
+	require.NoError(t, err)
+	assert.Equal(t, "AzureCloud", cloud)
+}
+
+func TestConfigurationAdapter_SetContext_SeveralAccounts(t *testing.T) {
+	fa := newTestStorage(t)
+	production := uuid.MustParse("9e7969ef-4cb8-4a2d-959f-bfdaae452a3d")
+	cfg, err := fa.ReadConfig()
+	require.NoError(t, err)
+	var user types.Subscription
+	for i, sub := range cfg.Subscriptions {
+		cfg.Subscriptions[i].IsDefault = false
+		if sub.ID == production {
+			user = sub
+		}
+	}
+	sp := user
+	sp.User.Name, sp.User.Type = "deploy-sp", "servicePrincipal"
+	cfg.Subscriptions = append(cfg.Subscriptions, sp)
+	require.NoError(t, fa.WriteConfig(cfg))
+
+	current := func() types.Identity {
+		cfg, err := fa.ReadConfig()
+		require.NoError(t, err)
+		var defaults []types.Identity
+		for _, sub := range cfg.Subscriptions {
+			if sub.IsDefault {
+				defaults = append(defaults, sub.Identity())
+			}
+		}
+		require.Len(t, defaults, 1)
+		return defaults[0]
+	}
+
+	sm := &memoryState{}
+	adapter := NewConfigurationAdapter(fa, NewLogger("error")).WithHistory(sm, "test")
+	require.NoError(t, adapter.SetContext(user.Identity()))
+	assert.Equal(t, user.Identity(), current())
+
+	require.NoError(t, adapter.SetContext(sp.Identity()))
+	assert.Equal(t, sp.Identity(), current(), "the entry of the other account becomes the default")
+	require.Len(t, sm.History(), 1, "switching accounts is a context switch")
+	assert.Equal(t, user.User.Name, sm.History()[0].User)
+	assert.Equal(t, user.User.Type, sm.History()[0].UserType)
+
+	require.NoError(t, adapter.SetPreviousContext(sm))
+	assert.Equal(t, user.Identity(), current())
+	assert.Equal(t, "deploy-sp", sm.History()[0].User)
+
+	require.NoError(t, adapter.SetPreviousContext(sm))
+	assert.Equal(t, sp.Identity(), current())
+}
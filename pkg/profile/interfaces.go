@@ -4,6 +4,8 @@ package profile
 
 import (
 	"github.com/google/uuid"
+	"github.com/riweston/aztx/pkg/metadata"
+	"github.com/riweston/aztx/pkg/state"
 	"github.com/riweston/aztx/pkg/types"
 )
 
@@ -19,6 +21,53 @@ type StorageAdapter interface {
 	WriteConfig(*types.Configuration) error
 }
 
+// Locker is implemented by storage adapters that can guard a read-modify-write
+// cycle against concurrent writers in other processes.
+type Locker interface {
+	// Lock acquires an exclusive lock and returns a function that releases it.
+	// Returns an error if the lock cannot be acquired in time.
+	Lock() (func() error, error)
+}
+
+// CloudSwitcher is implemented by storage adapters whose config directory also holds
+// the cloud the Azure CLI targets.
+type CloudSwitcher interface {
+	// SetActiveCloud makes the Azure CLI target the named cloud.
+	// Returns false when it already did.
+	SetActiveCloud(name string) (bool, error)
+}
+
+// ConfigDirLocator is implemented by storage adapters backed by an Azure CLI config
+// directory, so that history entries can record where a context was used.
+type ConfigDirLocator interface {
+	// BaseConfigDir returns the absolute path of the config directory.
+	BaseConfigDir() string
+}
+
+// MetadataStore defines the interface for the store holding aztx's own metadata
+// about subscriptions and tenants.
+type MetadataStore interface {
+	// Load retrieves the current metadata.
+	Load() (*metadata.Metadata, error)
+
+	// Update applies fn to the current metadata and persists the result.
+	// Returns an error if fn or the write fails.
+	Update(fn func(*metadata.Metadata) error) error
+}
+
+// HistorySource provides the contexts recorded by aztx, most recent first.
+// state.StateManager implements it.
+type HistorySource interface {
+	History() []state.HistoryEntry
+}
+
+// Guard approves switches into protected subscriptions.
+type Guard interface {
+	// Approve is called before the default is switched to a protected subscription.
+	// Returns an error, such as a failed confirmation, to prevent the switch.
+	Approve(types.Subscription) error
+}
+
 // TenantService defines the interface for tenant-related operations.
 // It provides functionality for managing Azure tenant information.
 type TenantService interface {
@@ -45,6 +45,7 @@ type DefaultLogger struct {
 	logger *log.Logger
 	level  LogLevel
 	writer io.Writer
+	out    io.Writer // Destination of info and success messages
 }
 
 func NewLogger(level string) *DefaultLogger {
@@ -59,9 +60,16 @@ func NewLogger(level string) *DefaultLogger {
 		logger: logger,
 		level:  parseLevel(level),
 		writer: os.Stderr,
+		out:    os.Stdout,
 	}
 }
 
+// SetOutput redirects info and success messages, which go to stdout by default.
+// Warnings, errors and debug messages always go to stderr.
+func (l *DefaultLogger) SetOutput(w io.Writer) {
+	l.out = w
+}
+
 func parseLevel(level string) LogLevel {
 	switch strings.ToLower(level) {
 	case "debug":
@@ -95,14 +103,14 @@ func (l *DefaultLogger) Info(msg string, args ...interface{}) {
 	if l.level <= LevelInfo {
 		formattedMsg := l.formatMessage(msg, args...)
 		// For Info, we'll use a simpler, user-friendly output
-		fmt.Println(infoStyle.Render(formattedMsg))
+		fmt.Fprintln(l.out, infoStyle.Render(formattedMsg))
 	}
 }
 
 func (l *DefaultLogger) Success(msg string, args ...interface{}) {
 	if l.level <= LevelInfo {
 		formattedMsg := l.formatMessage(msg, args...)
-		fmt.Println(successStyle.Render(formattedMsg))
+		fmt.Fprintln(l.out, successStyle.Render(formattedMsg))
 	}
 }
 
@@ -0,0 +1,243 @@
+package profile
+
+import (
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/metadata"
+	"github.com/riweston/aztx/pkg/protect"
+	"github.com/riweston/aztx/pkg/subscription"
+	"github.com/riweston/aztx/pkg/types"
+)
+
+// MetadataStorage decorates a StorageAdapter with the metadata aztx keeps in its own
+// store. Configurations read through it carry tenant names, aliases, favorites and
+// tags, are marked protected by Rules and carry when they were last used according
+// to History; tenant names changed on a configuration are saved to the store when it is
+// written back, and none of that metadata ever reaches the Azure profile.
+type MetadataStorage struct {
+	StorageAdapter
+	Metadata MetadataStore
+	Rules    protect.Rules // Rules marking subscriptions as protected
+	History  HistorySource // History the last use of subscriptions is read from
+
+	names map[string]string // tenant names merged in by the last ReadConfig, by tenant ID
+}
+
+// NewMetadataStorage wraps storage with the given metadata store.
+func NewMetadataStorage(storage StorageAdapter, store MetadataStore) *MetadataStorage {
+	return &MetadataStorage{StorageAdapter: storage, Metadata: store}
+}
+
+// ReadConfig reads the configuration from the wrapped storage and merges the metadata into it.
+func (m *MetadataStorage) ReadConfig() (*types.Configuration, error) {
+	config, err := m.StorageAdapter.ReadConfig()
+	if err != nil {
+		return nil, err
+	}
+	if m.Metadata == nil {
+		m.annotate(config)
+		return config, nil
+	}
+
+	kept, err := m.Metadata.Load()
+	if err != nil {
+		return nil, pkgerrors.WrapError("reading metadata", err)
+	}
+
+	subManager := subscription.Manager{BaseManager: types.BaseManager{Configuration: config}}
+	subManager.ApplyMetadata(kept)
+
+	names := kept.TenantNames()
+	m.names = make(map[string]string, len(names))
+	for id, name := range names {
+		m.names[id] = name
+	}
+	for i, tenant := range config.Tenants {
+		if name, ok := names[tenant.ID.String()]; ok {
+			config.Tenants[i].CustomName = name
+			delete(names, tenant.ID.String())
+		}
+	}
+	for id, name := range names {
+		tenant := types.Tenant{CustomName: name}
+		if err := tenant.ID.UnmarshalText([]byte(id)); err != nil {
+			continue
+		}
+		config.Tenants = append(config.Tenants, tenant)
+	}
+	m.annotate(config)
+	return config, nil
+}
+
+// annotate applies the protected rules and the history to the subscriptions of config.
+func (m *MetadataStorage) annotate(config *types.Configuration) {
+	m.Rules.Apply(config)
+	if m.History == nil {
+		return
+	}
+	history := m.History.History()
+	for i, sub := range config.Subscriptions {
+		for _, entry := range history {
+			if id, err := entry.Identity(); err == nil && id.Matches(sub) {
+				config.Subscriptions[i].LastUsed = entry.Timestamp
+				break
+			}
+		}
+	}
+}
+
+// WriteConfig saves the tenant names changed on the configuration since ReadConfig
+// to the metadata store and writes the configuration without them to the wrapped
+// storage. Only those changes are written to the store, so that a name another
+// process saved in the meantime is kept; a name missing from the configuration that
+// ReadConfig merged in has been removed.
+func (m *MetadataStorage) WriteConfig(config *types.Configuration) error {
+	if m.Metadata == nil {
+		return m.StorageAdapter.WriteConfig(config)
+	}
+
+	names := tenantNames(config)
+	changed := make(map[string]string)
+	for id := range m.names {
+		if _, ok := names[id]; !ok {
+			changed[id] = ""
+		}
+	}
+	for id, name := range names {
+		if m.names[id] != name {
+			changed[id] = name
+		}
+	}
+	if len(changed) > 0 {
+		err := m.Metadata.Update(func(kept *metadata.Metadata) error {
+			for id, name := range changed {
+				kept.SetTenantName(id, name)
+			}
+			return nil
+		})
+		if err != nil {
+			return pkgerrors.WrapError("writing metadata", err)
+		}
+		m.names = names
+	}
+
+	return m.StorageAdapter.WriteConfig(withoutTenantNames(config))
+}
+
+// Lock delegates to the wrapped storage when it supports locking.
+func (m *MetadataStorage) Lock() (func() error, error) {
+	if locker, ok := m.StorageAdapter.(Locker); ok {
+		return locker.Lock()
+	}
+	return func() error { return nil }, nil
+}
+
+// SetActiveCloud delegates to the wrapped storage when it holds the Azure CLI's cloud.
+func (m *MetadataStorage) SetActiveCloud(name string) (bool, error) {
+	if switcher, ok := m.StorageAdapter.(CloudSwitcher); ok {
+		return switcher.SetActiveCloud(name)
+	}
+	return false, nil
+}
+
+// BaseConfigDir delegates to the wrapped storage when it is backed by a config
+// directory.
+func (m *MetadataStorage) BaseConfigDir() string {
+	if locator, ok := m.StorageAdapter.(ConfigDirLocator); ok {
+		return locator.BaseConfigDir()
+	}
+	return ""
+}
+
+// Migrate moves tenant names stored as customName in the Azure profile by earlier
+// aztx versions into the metadata store and removes them from the profile. Names
+// already in the store win. Returns the number of names found in the profile.
+func (m *MetadataStorage) Migrate() (int, error) {
+	if m.Metadata == nil {
+		return 0, nil
+	}
+
+	// Most profiles hold no names, so look before taking the lock.
+	if config, err := m.StorageAdapter.ReadConfig(); err != nil || !hasTenantNames(config) {
+		return 0, err
+	}
+
+	migrated := 0
+	err := m.withLock(func() error {
+		config, err := m.StorageAdapter.ReadConfig()
+		if err != nil {
+			return err
+		}
+
+		names := tenantNames(config)
+		if len(names) == 0 {
+			return nil
+		}
+
+		err = m.Metadata.Update(func(kept *metadata.Metadata) error {
+			existing := kept.TenantNames()
+			for id, name := range names {
+				if _, ok := existing[id]; !ok {
+					kept.SetTenantName(id, name)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return pkgerrors.WrapError("writing metadata", err)
+		}
+
+		migrated = len(names)
+		return m.StorageAdapter.WriteConfig(withoutTenantNames(config))
+	})
+	return migrated, err
+}
+
+// withLock runs fn while holding the wrapped storage's lock, if it has one.
+func (m *MetadataStorage) withLock(fn func() error) (err error) {
+	unlock, err := m.Lock()
+	if err != nil {
+		return pkgerrors.WrapError("locking configuration", err)
+	}
+	defer func() {
+		if unlockErr := unlock(); unlockErr != nil && err == nil {
+			err = pkgerrors.WrapError("unlocking configuration", unlockErr)
+		}
+	}()
+	return fn()
+}
+
+// hasTenantNames reports whether any tenant of config has a custom name.
+func hasTenantNames(config *types.Configuration) bool {
+	for _, tenant := range config.Tenants {
+		if tenant.CustomName != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// tenantNames returns the tenant custom names held by config, by tenant ID.
+func tenantNames(config *types.Configuration) map[string]string {
+	names := make(map[string]string)
+	for _, tenant := range config.Tenants {
+		if tenant.CustomName != "" {
+			names[tenant.ID.String()] = tenant.CustomName
+		}
+	}
+	return names
+}
+
+// withoutTenantNames returns a copy of config without tenant custom names. Tenant
+// entries that only existed to hold a custom name are dropped.
+func withoutTenantNames(config *types.Configuration) *types.Configuration {
+	stripped := *config
+	stripped.Tenants = nil
+	for _, tenant := range config.Tenants {
+		if tenant.Name == "" {
+			continue
+		}
+		tenant.CustomName = ""
+		stripped.Tenants = append(stripped.Tenants, tenant)
+	}
+	return &stripped
+}
@@ -0,0 +1,162 @@
+package profile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/riweston/aztx/pkg/metadata"
+	"github.com/riweston/aztx/pkg/protect"
+	"github.com/riweston/aztx/pkg/state"
+	"github.com/riweston/aztx/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fabrikamTenant = uuid.MustParse("22222222-2222-2222-2222-222222222222")
+
+func newTestMetadataStore(t *testing.T) *metadata.Store {
+	t.Helper()
+	return metadata.NewStore(filepath.Join(t.TempDir(), "aztx", metadata.FileName), 0)
+}
+
+// rawTenants returns the tenants array of the profile on disk.
+func rawTenants(t *testing.T, path string) []map[string]interface{} {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var doc struct {
+		Tenants []map[string]interface{} `json:"tenants"`
+	}
+	require.NoError(t, json.Unmarshal(data, &doc))
+	return doc.Tenants
+}
+
+func TestMetadataStorage_ReadConfig(t *testing.T) {
+	fa := newTestStorage(t)
+	store := newTestMetadataStore(t)
+	require.NoError(t, store.Update(func(m *metadata.Metadata) error {
+		m.SetAlias("main", "9e7969ef-4cb8-4a2d-959f-bfdaae452a3d")
+		m.SetFavorite("9e7969ef-4cb8-4a2d-959f-bfdaae452a3d", true)
+		m.AddTags("9e7969ef-4cb8-4a2d-959f-bfdaae452a3d", "prod")
+		m.SetTenantName(fabrikamTenant.String(), "Fabrikam")
+		return nil
+	}))
+
+	cfg, err := NewMetadataStorage(fa, store).ReadConfig()
+	require.NoError(t, err)
+	assert.Equal(t, "main", cfg.Subscriptions[0].Alias)
+	assert.True(t, cfg.Subscriptions[0].Favorite)
+	assert.Equal(t, []string{"prod"}, cfg.Subscriptions[0].Tags)
+	require.Len(t, cfg.Tenants, 1)
+	assert.Equal(t, fabrikamTenant, cfg.Tenants[0].ID)
+	assert.Equal(t, "Fabrikam", cfg.Tenants[0].CustomName)
+}
+
+func TestMetadataStorage_SaveTenantName(t *testing.T) {
+	fa := newTestStorage(t)
+	store := newTestMetadataStore(t)
+	adapter := NewConfigurationAdapter(NewMetadataStorage(fa, store), NewLogger("error"))
+
+	require.NoError(t, adapter.SaveTenantName(fabrikamTenant, "Fabrikam"))
+	m, err := store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{fabrikamTenant.String(): "Fabrikam"}, m.TenantNames())
+	assert.Empty(t, rawTenants(t, fa.Path), "names must not reach the Azure profile")
+
+	// Switching keeps the name.
+	require.NoError(t, adapter.SetContext(types.Identity{SubscriptionID: uuid.MustParse("9bb28eee-ebaa-442a-83ba-5511810fb151")}))
+	m, err = store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, "Fabrikam", m.TenantNames()[fabrikamTenant.String()])
+
+	cleared, err := adapter.ClearTenantName(fabrikamTenant)
+	require.NoError(t, err)
+	assert.True(t, cleared)
+	m, err = store.Load()
+	require.NoError(t, err)
+	assert.Empty(t, m.TenantNames())
+}
+
+func TestMetadataStorage_WriteConfig_KeepsConcurrentRename(t *testing.T) {
+	store := newTestMetadataStore(t)
+	require.NoError(t, store.Update(func(m *metadata.Metadata) error {
+		m.SetTenantName(fabrikamTenant.String(), "Fabrikam")
+		return nil
+	}))
+
+	// A switch reads the names, then "aztx tenant rename" in another process saves a
+	// new name before the switch writes the configuration back.
+	switching := NewMetadataStorage(newTestStorage(t), store)
+	cfg, err := switching.ReadConfig()
+	require.NoError(t, err)
+	renaming := NewConfigurationAdapter(NewMetadataStorage(newTestStorage(t), store), NewLogger("error"))
+	require.NoError(t, renaming.SaveTenantName(fabrikamTenant, "Fabrikam Inc"))
+	require.NoError(t, switching.WriteConfig(cfg))
+
+	m, err := store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{fabrikamTenant.String(): "Fabrikam Inc"}, m.TenantNames())
+}
+
+func TestMetadataStorage_Migrate(t *testing.T) {
+	fa := newTestStorage(t)
+	data, err := os.ReadFile(fa.Path)
+	require.NoError(t, err)
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &doc))
+	doc["tenants"] = []map[string]string{
+		{"tenantId": fabrikamTenant.String(), "customName": "Fabrikam"},
+		{"tenantId": "11111111-1111-1111-1111-111111111111", "name": "Contoso", "customName": "Legacy"},
+	}
+	data, err = json.MarshalIndent(doc, "", "  ")
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(fa.Path, data, 0644))
+
+	store := newTestMetadataStore(t)
+	require.NoError(t, store.Update(func(m *metadata.Metadata) error {
+		m.SetTenantName("11111111-1111-1111-1111-111111111111", "Contoso")
+		return nil
+	}))
+
+	storage := NewMetadataStorage(fa, store)
+	migrated, err := storage.Migrate()
+	require.NoError(t, err)
+	assert.Equal(t, 2, migrated)
+
+	m, err := store.Load()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		fabrikamTenant.String():                "Fabrikam",
+		"11111111-1111-1111-1111-111111111111": "Contoso",
+	}, m.TenantNames(), "names already in the store win")
+	assert.Equal(t, []map[string]interface{}{
+		{"tenantId": "11111111-1111-1111-1111-111111111111", "name": "Contoso"},
+	}, rawTenants(t, fa.Path))
+
+	migrated, err = storage.Migrate()
+	require.NoError(t, err)
+	assert.Zero(t, migrated, "migration only happens once")
+}
+
+func TestMetadataStorage_ReadConfig_History(t *testing.T) {
+	fa := newTestStorage(t)
+	used := time.Date(2024, 10, 18, 9, 30, 0, 0, time.UTC)
+	sm := &memoryState{history: []state.HistoryEntry{
+		{Timestamp: used, SubscriptionID: "9e7969ef-4cb8-4a2d-959f-bfdaae452a3d"},
+		{Timestamp: used.Add(-time.Hour), SubscriptionID: "9e7969ef-4cb8-4a2d-959f-bfdaae452a3d"},
+	}}
+
+	storage := NewMetadataStorage(fa, nil)
+	storage.History = sm
+	storage.Rules = protect.Rules{{Tenant: fabrikamTenant.String()}}
+	cfg, err := storage.ReadConfig()
+	require.NoError(t, err)
+	assert.True(t, used.Equal(cfg.Subscriptions[0].LastUsed), "the most recent entry counts")
+	assert.True(t, cfg.Subscriptions[1].LastUsed.IsZero())
+	assert.False(t, cfg.Subscriptions[0].Protected)
+	assert.True(t, cfg.Subscriptions[2].Protected)
+}
@@ -0,0 +1,89 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/finder"
+	"github.com/riweston/aztx/pkg/subscription"
+	"github.com/riweston/aztx/pkg/types"
+)
+
+// Source is a named Azure CLI config directory together with the storage for its profile.
+type Source struct {
+	Name    string
+	Storage StorageAdapter
+}
+
+// SourcedSubscription is a subscription along with the source it was read from.
+type SourcedSubscription struct {
+	types.Subscription
+	Source Source
+}
+
+// LoadSubscriptions reads the subscriptions of every source. Sources whose profile
+// does not exist are skipped, so a configured but not yet logged-in directory does
+// not prevent switching in the others.
+func LoadSubscriptions(sources []Source, logger Logger) ([]SourcedSubscription, error) {
+	subs, _, err := loadSources(sources, logger)
+	return subs, err
+}
+
+// loadSources reads the subscriptions of every source like LoadSubscriptions, along
+// with the tenants of all of them.
+func loadSources(sources []Source, logger Logger) ([]SourcedSubscription, []types.Tenant, error) {
+	var subs []SourcedSubscription
+	var tenants []types.Tenant
+	for _, source := range sources {
+		cfg, err := source.Storage.ReadConfig()
+		if err != nil {
+			if errors.Is(err, pkgerrors.ErrFileDoesNotExist) {
+				logger.Debug("skipping config dir %s: no profile found", source.Name)
+				continue
+			}
+			logger.Error("failed to read configuration for %s: %v", source.Name, err)
+			return nil, nil, pkgerrors.WrapError(fmt.Sprintf("reading configuration %s", source.Name), err)
+		}
+		tenants = append(tenants, cfg.Tenants...)
+		for _, sub := range cfg.Subscriptions {
+			subs = append(subs, SourcedSubscription{Subscription: sub, Source: source})
+		}
+	}
+	if len(subs) == 0 {
+		return nil, nil, pkgerrors.ErrSubscriptionNotFound
+	}
+	return subs, tenants, nil
+}
+
+// SelectAcrossSources lets the user pick a subscription matching f from all sources
+// with the fuzzy finder. Each entry is labelled with the name of its config dir, which
+// the preview pane shows along with the details of the subscription.
+func SelectAcrossSources(sources []Source, f subscription.Filter, logger Logger) (*SourcedSubscription, error) {
+	loaded, tenants, err := loadSources(sources, logger)
+	if err != nil {
+		return nil, err
+	}
+	subManager := subscription.Manager{BaseManager: types.BaseManager{Configuration: &types.Configuration{Tenants: tenants}}}
+
+	var subs []SourcedSubscription
+	for _, sub := range loaded {
+		if subManager.Matches(sub.Subscription, f) {
+			subs = append(subs, sub)
+			subManager.Configuration.Subscriptions = append(subManager.Configuration.Subscriptions, sub.Subscription)
+		}
+	}
+	if len(subs) == 0 {
+		return nil, pkgerrors.ErrNoneMatchFilter
+	}
+	sort.SliceStable(subs, func(i, j int) bool {
+		return subs[i].Favorite && !subs[j].Favorite
+	})
+	label := subscription.Labeler(subManager.Configuration.Subscriptions)
+	return finder.FuzzyPreview(subs, func(s SourcedSubscription) string {
+		return fmt.Sprintf("[%s] %s", s.Source.Name, label(s.Subscription))
+	}, func(s SourcedSubscription) string {
+		return fmt.Sprintf("Config dir: %s\n\n%s", s.Source.Name, subManager.Preview(s.Subscription))
+	})
+}
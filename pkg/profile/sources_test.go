@@ -0,0 +1,56 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/storage"
+	"github.com/riweston/aztx/pkg/subscription"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadSubscriptions(t *testing.T) {
+	work := newTestStorage(t)
+	missing := &storage.FileAdapter{}
+	missing.UseConfigDir(filepath.Join(t.TempDir(), "missing"))
+
+	sources := []Source{
+		{Name: "missing", Storage: missing},
+		{Name: "work", Storage: work},
+	}
+	subs, err := LoadSubscriptions(sources, NewLogger("error"))
+	require.NoError(t, err)
+	require.Len(t, subs, 5)
+	for _, sub := range subs {
+		assert.Equal(t, "work", sub.Source.Name)
+		assert.Same(t, work, sub.Source.Storage)
+	}
+}
+
+func TestLoadSubscriptions_NoProfiles(t *testing.T) {
+	missing := &storage.FileAdapter{}
+	missing.UseConfigDir(t.TempDir())
+
+	_, err := LoadSubscriptions([]Source{{Name: "missing", Storage: missing}}, NewLogger("error"))
+	assert.ErrorIs(t, err, pkgerrors.ErrSubscriptionNotFound)
+}
+
+func TestLoadSubscriptions_InvalidProfile(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, storage.ProfileFileName), []byte("{"), 0644))
+	broken := &storage.FileAdapter{}
+	broken.UseConfigDir(dir)
+
+	_, err := LoadSubscriptions([]Source{{Name: "broken", Storage: broken}}, NewLogger("error"))
+	assert.Error(t, err)
+}
+
+func TestSelectAcrossSources_NoneMatchFilter(t *testing.T) {
+	sources := []Source{{Name: "work", Storage: newTestStorage(t)}}
+
+	_, err := SelectAcrossSources(sources, subscription.Filter{Cloud: "AzureChinaCloud"}, NewLogger("error"))
+	assert.ErrorIs(t, err, pkgerrors.ErrNoneMatchFilter)
+}
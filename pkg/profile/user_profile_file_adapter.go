@@ -47,12 +47,7 @@ func (u *UserProfileFileAdapter) Read() (*types.Configuration, error) {
 
 // Write writes the configuration back to the file.
 func (u *UserProfileFileAdapter) Write(cfg *types.Configuration) error {
-	jsonData, err := json.MarshalIndent(cfg, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	return u.fileAdapter.Write(jsonData)
+	return u.fileAdapter.WriteConfig(cfg)
 }
 
 func (u *UserProfileFileAdapter) unmarshalConfig(data []byte, cfg *types.Configuration) error {
@@ -0,0 +1,94 @@
+// Package protect decides which subscriptions are protected by the rules in ~/.aztx.yml.
+// Switching into a protected subscription needs typed confirmation.
+package protect
+
+import (
+	"path"
+	"strings"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/types"
+)
+
+// Rule marks the subscriptions matching every field it sets as protected:
+//
+//	protected:
+//	  - name: "*-prod"
+//	  - tenant: Contoso
+//	    tag: critical
+type Rule struct {
+	ID     string `mapstructure:"id"`     // Subscription ID
+	Name   string `mapstructure:"name"`   // Glob matched against the subscription name, ignoring case
+	Tenant string `mapstructure:"tenant"` // Tenant ID or custom name
+	Tag    string `mapstructure:"tag"`    // Tag given with "aztx tag add"
+}
+
+// Rules protects the subscriptions matched by any of its rules.
+type Rules []Rule
+
+// Validate checks that every rule sets at least one field and that name globs parse.
+func (r Rules) Validate() error {
+	for i, rule := range r {
+		if rule == (Rule{}) {
+			return pkgerrors.ErrInvalidProtectRule(i, "it sets none of id, name, tenant or tag")
+		}
+		if _, err := path.Match(rule.Name, ""); err != nil {
+			return pkgerrors.ErrInvalidProtectRule(i, "bad name pattern "+rule.Name)
+		}
+	}
+	return nil
+}
+
+// Matches reports whether rule matches sub, whose tenant has the given custom name.
+func (rule Rule) Matches(sub types.Subscription, tenantName string) bool {
+	if rule == (Rule{}) {
+		return false
+	}
+	if rule.ID != "" && !strings.EqualFold(rule.ID, sub.ID.String()) {
+		return false
+	}
+	if rule.Name != "" {
+		if ok, _ := path.Match(strings.ToLower(rule.Name), strings.ToLower(sub.Name)); !ok {
+			return false
+		}
+	}
+	if rule.Tenant != "" && !strings.EqualFold(rule.Tenant, sub.TenantID.String()) &&
+		(tenantName == "" || !strings.EqualFold(rule.Tenant, tenantName)) {
+		return false
+	}
+	if rule.Tag != "" && !hasTag(sub.Tags, rule.Tag) {
+		return false
+	}
+	return true
+}
+
+// Apply marks the subscriptions of config matched by a rule as protected. Tags and
+// tenant names must already be merged into config.
+func (r Rules) Apply(config *types.Configuration) {
+	names := make(map[string]string, len(config.Tenants))
+	for _, t := range config.Tenants {
+		names[t.ID.String()] = t.CustomName
+	}
+	for i, sub := range config.Subscriptions {
+		config.Subscriptions[i].Protected = r.Match(sub, names[sub.TenantID.String()])
+	}
+}
+
+// Match reports whether any rule matches sub, whose tenant has the given custom name.
+func (r Rules) Match(sub types.Subscription, tenantName string) bool {
+	for _, rule := range r {
+		if rule.Matches(sub, tenantName) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
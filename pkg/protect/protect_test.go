@@ -0,0 +1,72 @@
+package protect
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var contosoTenant = uuid.MustParse("11111111-1111-1111-1111-111111111111")
+
+func newSubscription(name string, tags ...string) types.Subscription {
+	return types.Subscription{
+		ID:       uuid.MustParse("9e7969ef-4cb8-4a2d-959f-bfdaae452a3d"),
+		Name:     name,
+		TenantID: contosoTenant,
+		Tags:     tags,
+	}
+}
+
+func TestRule_Matches(t *testing.T) {
+	tests := []struct {
+		name       string
+		rule       Rule
+		sub        types.Subscription
+		tenantName string
+		want       bool
+	}{
+		{name: "ID", rule: Rule{ID: "9E7969EF-4CB8-4A2D-959F-BFDAAE452A3D"}, sub: newSubscription("Workloads"), want: true},
+		{name: "other ID", rule: Rule{ID: "8aa89ebb-5735-4d1b-9c5c-a8f32a858e99"}, sub: newSubscription("Workloads")},
+		{name: "name glob ignores case", rule: Rule{Name: "*-PROD"}, sub: newSubscription("payments-prod"), want: true},
+		{name: "name glob", rule: Rule{Name: "*-prod"}, sub: newSubscription("payments-dev")},
+		{name: "tenant ID", rule: Rule{Tenant: contosoTenant.String()}, sub: newSubscription("Workloads"), want: true},
+		{name: "tenant name", rule: Rule{Tenant: "contoso"}, sub: newSubscription("Workloads"), tenantName: "Contoso", want: true},
+		{name: "tenant without name", rule: Rule{Tenant: "contoso"}, sub: newSubscription("Workloads")},
+		{name: "tag", rule: Rule{Tag: "critical"}, sub: newSubscription("Workloads", "Critical"), want: true},
+		{name: "every field must match", rule: Rule{Name: "work*", Tag: "critical"}, sub: newSubscription("Workloads")},
+		{name: "empty rule", rule: Rule{}, sub: newSubscription("Workloads")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.rule.Matches(tt.sub, tt.tenantName))
+		})
+	}
+}
+
+func TestRules_Apply(t *testing.T) {
+	prod := newSubscription("payments-prod")
+	dev := newSubscription("payments-dev")
+	dev.ID = uuid.MustParse("8aa89ebb-5735-4d1b-9c5c-a8f32a858e99")
+	dev.Protected = true
+	config := &types.Configuration{
+		Tenants:       []types.Tenant{{ID: contosoTenant, CustomName: "Contoso"}},
+		Subscriptions: []types.Subscription{prod, dev},
+	}
+
+	Rules{{Name: "*-prod"}}.Apply(config)
+	assert.True(t, config.Subscriptions[0].Protected)
+	assert.False(t, config.Subscriptions[1].Protected)
+
+	Rules{{Tenant: "Contoso"}}.Apply(config)
+	assert.True(t, config.Subscriptions[1].Protected)
+}
+
+func TestRules_Validate(t *testing.T) {
+	assert.NoError(t, Rules{{Name: "*-prod"}, {Tag: "prod"}}.Validate())
+	assert.NoError(t, Rules(nil).Validate())
+	assert.ErrorIs(t, Rules{{Tag: "prod"}, {}}.Validate(), pkgerrors.ErrInvalidProtectRules)
+	assert.ErrorIs(t, Rules{{Name: "[prod"}}.Validate(), pkgerrors.ErrInvalidProtectRules)
+}
@@ -0,0 +1,63 @@
+package shell
+
+import (
+	"embed"
+	"strings"
+	"text/template"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+)
+
+// EvalFileEnv names the file the shell integration sources after running aztx. Shell
+// code that must run in the calling shell, such as the exports of a session, is
+// written there instead of to stdout when it is set.
+const EvalFileEnv = "AZTX_EVAL_FILE"
+
+// Shells lists the shells Init writes integration scripts for.
+var Shells = []string{"bash", "zsh", "fish", "pwsh"}
+
+//go:embed scripts
+var scripts embed.FS
+
+// scriptFiles maps each shell to its template in scripts.
+var scriptFiles = map[string]string{
+	"bash": "scripts/bash.sh",
+	"zsh":  "scripts/zsh.zsh",
+	"fish": "scripts/fish.fish",
+	"pwsh": "scripts/pwsh.ps1",
+}
+
+// InitOptions selects what an integration script sets up besides the aztx wrapper
+// function.
+type InitOptions struct {
+	Completion string // Completion script to register, left out when empty
+	Prompt     bool   // Show the active subscription in the prompt
+	Session    bool   // Switch only the current shell by default, as with --session
+	Pin        bool   // Switch to the pinned subscription when the working directory changes
+	IdleRevert bool   // Offer to switch back from an idle protected subscription before each prompt
+}
+
+// Init returns the integration script for the named shell. The script defines an
+// aztx function that runs the aztx binary and sources the shell code it leaves in
+// the file named by EvalFileEnv, so that commands can change the calling shell.
+func Init(name string, opts InitOptions) (string, error) {
+	name = strings.ToLower(name)
+	if name == "powershell" {
+		name = "pwsh"
+	}
+	file, ok := scriptFiles[name]
+	if !ok {
+		return "", pkgerrors.ErrInvalidShell(name)
+	}
+
+	tmpl, err := template.ParseFS(scripts, file)
+	if err != nil {
+		return "", pkgerrors.ErrOperation("parsing "+name+" script", err)
+	}
+	opts.Completion = strings.TrimRight(opts.Completion, "\n")
+	var b strings.Builder
+	if err := tmpl.Execute(&b, opts); err != nil {
+		return "", pkgerrors.ErrOperation("rendering "+name+" script", err)
+	}
+	return strings.TrimRight(b.String(), "\n") + "\n", nil
+}
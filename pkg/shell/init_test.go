@@ -0,0 +1,53 @@
+package shell
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of the init scripts")
+
+func TestInit(t *testing.T) {
+	variants := []struct {
+		suffix string
+		opts   InitOptions
+	}{
+		{suffix: "", opts: InitOptions{}},
+		{suffix: ".full", opts: InitOptions{Completion: "# completion script\n", Prompt: true, Session: true, Pin: true, IdleRevert: true}},
+	}
+
+	for _, name := range Shells {
+		for _, v := range variants {
+			golden := filepath.Join("testdata", "init."+name+v.suffix+".golden")
+			t.Run(filepath.Base(golden), func(t *testing.T) {
+				got, err := Init(name, v.opts)
+				require.NoError(t, err)
+				if *update {
+					require.NoError(t, os.WriteFile(golden, []byte(got), 0644))
+				}
+				want, err := os.ReadFile(golden)
+				require.NoError(t, err)
+				assert.Equal(t, string(want), got)
+			})
+		}
+	}
+}
+
+func TestInit_PowerShellAlias(t *testing.T) {
+	pwsh, err := Init("pwsh", InitOptions{})
+	require.NoError(t, err)
+	powershell, err := Init("PowerShell", InitOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, pwsh, powershell)
+}
+
+func TestInit_UnknownShell(t *testing.T) {
+	_, err := Init("cmd", InitOptions{})
+	assert.ErrorIs(t, err, pkgerrors.ErrUnknownShell)
+}
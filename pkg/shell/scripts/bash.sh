@@ -0,0 +1,52 @@
+# aztx shell integration for bash. Load it from ~/.bashrc with:
+#   eval "$(aztx init bash)"
+
+aztx() {
+  local aztx_eval aztx_status
+  aztx_eval="$(mktemp "${TMPDIR:-/tmp}/aztx-eval.XXXXXX")" || return 1
+  AZTX_EVAL_FILE="$aztx_eval" AZTX_SHELL=bash{{if .Session}} AZTX_SESSION=true{{end}} command aztx "$@"
+  aztx_status=$?
+  if [ -s "$aztx_eval" ]; then
+    . "$aztx_eval"
+  fi
+  rm -f "$aztx_eval"
+  return $aztx_status
+}
+{{- if .Prompt}}
+
+_aztx_prompt() {
+  AZTX_PROMPT="$(command aztx current --prompt)"
+}
+
+if [[ ";${PROMPT_COMMAND:-};" != *";_aztx_prompt;"* ]]; then
+  PROMPT_COMMAND="_aztx_prompt${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+  PS1='${AZTX_PROMPT:+($AZTX_PROMPT) }'"$PS1"
+fi
+{{- end}}
+{{- if .IdleRevert}}
+
+_aztx_idle() {
+  aztx protect check
+}
+
+if [[ ";${PROMPT_COMMAND:-};" != *";_aztx_idle;"* ]]; then
+  PROMPT_COMMAND="_aztx_idle${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+{{- end}}
+{{- if .Pin}}
+
+_aztx_pin() {
+  if [ "$PWD" != "${_AZTX_PIN_DIR:-}" ]; then
+    _AZTX_PIN_DIR="$PWD"
+    aztx pin apply
+  fi
+}
+
+if [[ ";${PROMPT_COMMAND:-};" != *";_aztx_pin;"* ]]; then
+  PROMPT_COMMAND="_aztx_pin${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+{{- end}}
+{{- if .Completion}}
+
+{{.Completion}}
+{{- end}}
@@ -0,0 +1,47 @@
+# aztx shell integration for fish. Load it from ~/.config/fish/config.fish with:
+#   aztx init fish | source
+
+function aztx --description 'Switch Azure subscriptions'
+    set -l aztx_eval (mktemp)
+    or return 1
+    env AZTX_EVAL_FILE=$aztx_eval AZTX_SHELL=fish{{if .Session}} AZTX_SESSION=true{{end}} aztx $argv
+    set -l aztx_status $status
+    if test -s $aztx_eval
+        source $aztx_eval
+    end
+    rm -f $aztx_eval
+    return $aztx_status
+end
+{{- if .IdleRevert}}
+
+function __aztx_idle --on-event fish_prompt
+    aztx protect check
+end
+{{- end}}
+{{- if .Prompt}}
+
+function __aztx_prompt --on-event fish_prompt
+    set -g AZTX_PROMPT (command aztx current --prompt)
+end
+
+if functions -q fish_prompt; and not functions -q __aztx_fish_prompt
+    functions -c fish_prompt __aztx_fish_prompt
+    function fish_prompt
+        test -n "$AZTX_PROMPT"; and printf '(%s) ' $AZTX_PROMPT
+        __aztx_fish_prompt
+    end
+end
+{{- end}}
+{{- if .Pin}}
+
+if not functions -q __aztx_pin
+    function __aztx_pin --on-variable PWD
+        aztx pin apply
+    end
+    __aztx_pin
+end
+{{- end}}
+{{- if .Completion}}
+
+{{.Completion}}
+{{- end}}
@@ -0,0 +1,63 @@
+# aztx shell integration for PowerShell. Load it from $PROFILE with:
+#   aztx init pwsh | Out-String | Invoke-Expression
+
+function global:aztx {
+    $aztxEval = New-TemporaryFile
+    $env:AZTX_EVAL_FILE = $aztxEval.FullName
+    $env:AZTX_SHELL = 'pwsh'
+{{- if .Session}}
+    $env:AZTX_SESSION = 'true'
+{{- end}}
+    try {
+        & (Get-Command aztx -CommandType Application | Select-Object -First 1) @args
+    } finally {
+        Remove-Item Env:AZTX_EVAL_FILE, Env:AZTX_SHELL{{if .Session}}, Env:AZTX_SESSION{{end}} -ErrorAction SilentlyContinue
+        $aztxCode = Get-Content -Raw $aztxEval.FullName
+        Remove-Item $aztxEval.FullName -ErrorAction SilentlyContinue
+        if ($aztxCode) {
+            Invoke-Expression $aztxCode
+        }
+    }
+}
+{{- if .Prompt}}
+
+if (-not (Test-Path Function:\__aztxPrompt)) {
+    Set-Item Function:\global:__aztxPrompt (Get-Item Function:\prompt).ScriptBlock
+    function global:prompt {
+        $aztxPrompt = & (Get-Command aztx -CommandType Application | Select-Object -First 1) current --prompt
+        if ($aztxPrompt) {
+            "($aztxPrompt) " + (__aztxPrompt)
+        } else {
+            __aztxPrompt
+        }
+    }
+}
+{{- end}}
+{{- if .IdleRevert}}
+
+if (-not (Test-Path Function:\__aztxIdlePrompt)) {
+    Set-Item Function:\global:__aztxIdlePrompt (Get-Item Function:\prompt).ScriptBlock
+    function global:prompt {
+        aztx protect check
+        __aztxIdlePrompt
+    }
+}
+{{- end}}
+{{- if .Pin}}
+
+if (-not (Test-Path Variable:\__aztxLocationChanged)) {
+    $global:__aztxLocationChanged = $ExecutionContext.InvokeCommand.LocationChangedAction
+    $ExecutionContext.InvokeCommand.LocationChangedAction = {
+        param($source, $eventArgs)
+        if ($global:__aztxLocationChanged) {
+            & $global:__aztxLocationChanged $source $eventArgs
+        }
+        aztx pin apply
+    }
+    aztx pin apply
+}
+{{- end}}
+{{- if .Completion}}
+
+{{.Completion}}
+{{- end}}
@@ -0,0 +1,134 @@
+// Package shell writes the shell code aztx prints for eval, such as the statements
+// that export the variables of a session, in the syntax of each supported shell.
+package shell
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/types"
+)
+
+// Dialect is the syntax family of a shell.
+type Dialect string
+
+const (
+	Posix      Dialect = "sh"     // sh, bash, zsh and other POSIX shells
+	Fish       Dialect = "fish"   // fish
+	PowerShell Dialect = "pwsh"   // PowerShell, on any platform
+	DotEnv     Dialect = "dotenv" // .env files read by docker compose and dotenv libraries
+)
+
+// ParseDialect returns the dialect of the named shell, accepting the name of the
+// shell binary with or without a path or .exe suffix.
+func ParseDialect(name string) (Dialect, error) {
+	base := name[strings.LastIndexAny(name, `/\`)+1:]
+	base = strings.TrimSuffix(strings.ToLower(base), ".exe")
+	switch base {
+	case "sh", "bash", "zsh", "ksh", "dash":
+		return Posix, nil
+	case "fish":
+		return Fish, nil
+	case "pwsh", "powershell":
+		return PowerShell, nil
+	default:
+		return "", pkgerrors.ErrInvalidShell(name)
+	}
+}
+
+// Detect guesses the dialect of the user's shell from $SHELL, falling back to
+// PowerShell on Windows and POSIX everywhere else.
+func Detect() Dialect {
+	if d, err := ParseDialect(os.Getenv("SHELL")); err == nil {
+		return d
+	}
+	if runtime.GOOS == "windows" {
+		return PowerShell
+	}
+	return Posix
+}
+
+// ParseEnvDialect returns the dialect of the named shell like ParseDialect, also
+// accepting dotenv for .env files.
+func ParseEnvDialect(name string) (Dialect, error) {
+	if strings.EqualFold(name, string(DotEnv)) {
+		return DotEnv, nil
+	}
+	return ParseDialect(name)
+}
+
+// Var is an environment variable to set.
+type Var struct {
+	Name  string
+	Value string
+}
+
+// ContextVars returns the variables Terraform's azurerm provider and the Azure SDKs
+// read the subscription and tenant to use from.
+func ContextVars(sub types.Subscription) []Var {
+	id, tenant := sub.ID.String(), sub.TenantID.String()
+	return []Var{
+		{Name: "ARM_SUBSCRIPTION_ID", Value: id},
+		{Name: "ARM_TENANT_ID", Value: tenant},
+		{Name: "AZURE_SUBSCRIPTION_ID", Value: id},
+		{Name: "AZURE_TENANT_ID", Value: tenant},
+	}
+}
+
+// Export returns the statements that set vars in the current shell, one per line.
+func Export(d Dialect, vars []Var) string {
+	var b strings.Builder
+	for _, v := range vars {
+		switch d {
+		case Fish:
+			fmt.Fprintf(&b, "set -gx %s %s;\n", v.Name, Quote(d, v.Value))
+		case PowerShell:
+			fmt.Fprintf(&b, "$env:%s = %s\n", v.Name, Quote(d, v.Value))
+		case DotEnv:
+			fmt.Fprintf(&b, "%s=%s\n", v.Name, Quote(d, v.Value))
+		default:
+			fmt.Fprintf(&b, "export %s=%s\n", v.Name, Quote(d, v.Value))
+		}
+	}
+	return b.String()
+}
+
+// Unset returns the statements that remove the named variables from the current
+// shell, one per line. Variables that are not set are ignored. A .env file cannot
+// unset variables, so nothing is returned for DotEnv.
+func Unset(d Dialect, names []string) string {
+	var b strings.Builder
+	for _, name := range names {
+		switch d {
+		case DotEnv:
+		case Fish:
+			fmt.Fprintf(&b, "set -e %s;\n", name)
+		case PowerShell:
+			fmt.Fprintf(&b, "Remove-Item Env:%s -ErrorAction SilentlyContinue\n", name)
+		default:
+			fmt.Fprintf(&b, "unset %s\n", name)
+		}
+	}
+	return b.String()
+}
+
+// Quote returns s as a single-quoted literal of the dialect. In a .env file, values
+// made of letters, digits and -_.:/ are left bare and others are double-quoted.
+func Quote(d Dialect, s string) string {
+	switch d {
+	case DotEnv:
+		if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.:/") == "" {
+			return s
+		}
+		return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "$", `\$`).Replace(s) + `"`
+	case Fish:
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+	case PowerShell:
+		return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+	default:
+		return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+	}
+}
@@ -0,0 +1,91 @@
+package shell
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDialect(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Dialect
+		wantErr bool
+	}{
+		{name: "bash", want: Posix},
+		{name: "/usr/bin/zsh", want: Posix},
+		{name: "/opt/homebrew/bin/fish", want: Fish},
+		{name: "pwsh", want: PowerShell},
+		{name: `C:\Windows\System32\WindowsPowerShell\v1.0\powershell.exe`, want: PowerShell},
+		{name: "cmd", wantErr: true},
+		{name: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseDialect(tt.name)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, pkgerrors.ErrUnknownShell)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestExport(t *testing.T) {
+	vars := []Var{{Name: "A", Value: "plain"}, {Name: "B", Value: `it's a \ test`}}
+	tests := []struct {
+		dialect Dialect
+		want    string
+	}{
+		{dialect: Posix, want: "export A='plain'\nexport B='it'\\''s a \\ test'\n"},
+		{dialect: Fish, want: "set -gx A 'plain';\nset -gx B 'it\\'s a \\\\ test';\n"},
+		{dialect: PowerShell, want: "$env:A = 'plain'\n$env:B = 'it''s a \\ test'\n"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.dialect), func(t *testing.T) {
+			assert.Equal(t, tt.want, Export(tt.dialect, vars))
+		})
+	}
+}
+
+func TestUnset(t *testing.T) {
+	names := []string{"A", "B"}
+	assert.Equal(t, "unset A\nunset B\n", Unset(Posix, names))
+	assert.Equal(t, "set -e A;\nset -e B;\n", Unset(Fish, names))
+	assert.Equal(t, "Remove-Item Env:A -ErrorAction SilentlyContinue\nRemove-Item Env:B -ErrorAction SilentlyContinue\n", Unset(PowerShell, names))
+}
+
+func TestParseEnvDialect(t *testing.T) {
+	d, err := ParseEnvDialect("DotEnv")
+	assert.NoError(t, err)
+	assert.Equal(t, DotEnv, d)
+	d, err = ParseEnvDialect("fish")
+	assert.NoError(t, err)
+	assert.Equal(t, Fish, d)
+	_, err = ParseEnvDialect("cmd")
+	assert.ErrorIs(t, err, pkgerrors.ErrUnknownShell)
+}
+
+func TestExport_DotEnv(t *testing.T) {
+	vars := []Var{{Name: "A", Value: "9e7969ef-4cb8-4a2d-959f-bfdaae452a3d"}, {Name: "B", Value: `say "$HOME"`}, {Name: "C"}}
+	assert.Equal(t, "A=9e7969ef-4cb8-4a2d-959f-bfdaae452a3d\nB=\"say \\\"\\$HOME\\\"\"\nC=\"\"\n", Export(DotEnv, vars))
+	assert.Empty(t, Unset(DotEnv, []string{"A"}))
+}
+
+func TestContextVars(t *testing.T) {
+	sub := types.Subscription{
+		ID:       uuid.MustParse("9e7969ef-4cb8-4a2d-959f-bfdaae452a3d"),
+		TenantID: uuid.MustParse("11111111-1111-1111-1111-111111111111"),
+	}
+	assert.Equal(t, []Var{
+		{Name: "ARM_SUBSCRIPTION_ID", Value: "9e7969ef-4cb8-4a2d-959f-bfdaae452a3d"},
+		{Name: "ARM_TENANT_ID", Value: "11111111-1111-1111-1111-111111111111"},
+		{Name: "AZURE_SUBSCRIPTION_ID", Value: "9e7969ef-4cb8-4a2d-959f-bfdaae452a3d"},
+		{Name: "AZURE_TENANT_ID", Value: "11111111-1111-1111-1111-111111111111"},
+	}, ContextVars(sub))
+}
@@ -1,15 +1,55 @@
 package state
 
-import "github.com/spf13/viper"
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/riweston/aztx/pkg/types"
+	"github.com/spf13/viper"
+)
+
+// DefaultHistorySize is the number of history entries kept when HistorySize is not set.
+const DefaultHistorySize = 50
+
+// HistoryEntry records a context that was left when switching to another one.
+type HistoryEntry struct {
+	Timestamp        time.Time // When the context was left
+	SubscriptionID   string    // ID of the subscription that was the default
+	SubscriptionName string    // Display name of that subscription
+	TenantID         string    // Tenant of that subscription
+	User             string    // Account the subscription was used with, empty in entries of older versions
+	UserType         string    // Type of that account, e.g. user or servicePrincipal
+	ConfigDir        string    // Azure CLI config dir the context was used in, empty in entries of older versions
+	Command          string    // Command that triggered the switch away from it
+}
+
+// Identity returns the profile entry the history entry refers to. Entries recorded by
+// older versions carry no account and refer to any entry of their subscription.
+func (e HistoryEntry) Identity() (types.Identity, error) {
+	id, err := uuid.Parse(e.SubscriptionID)
+	if err != nil {
+		return types.Identity{}, err
+	}
+	return types.Identity{SubscriptionID: id, User: e.User, UserType: e.UserType}, nil
+}
 
 // StateManager handles all state operations
 type StateManager interface {
 	GetLastContext() (id string, name string)
 	SetLastContext(id string, name string) error
+	// History returns the recorded contexts, most recent first.
+	History() []HistoryEntry
+	// PushHistory records a context as the most recent entry, dropping the oldest
+	// entries beyond the configured history size.
+	PushHistory(entry HistoryEntry) error
 }
 
+// ViperStateManager keeps the state in a viper config file. HistorySize is part of the
+// configuration of aztx, not of its state, so it is set by the caller.
 type ViperStateManager struct {
-	viper *viper.Viper
+	viper       *viper.Viper
+	HistorySize int // Number of history entries to keep; zero uses DefaultHistorySize
 }
 
 func NewViperStateManager(v *viper.Viper) *ViperStateManager {
@@ -17,12 +57,116 @@ func NewViperStateManager(v *viper.Viper) *ViperStateManager {
 }
 
 func (v *ViperStateManager) GetLastContext() (string, string) {
+	if history := v.History(); len(history) > 0 {
+		return history[0].SubscriptionID, history[0].SubscriptionName
+	}
 	return v.viper.GetString("lastContextId"),
 		v.viper.GetString("lastContextDisplayName")
 }
 
 func (v *ViperStateManager) SetLastContext(id string, name string) error {
-	v.viper.Set("lastContextId", id)
-	v.viper.Set("lastContextDisplayName", name)
+	return v.PushHistory(HistoryEntry{
+		Timestamp:        time.Now(),
+		SubscriptionID:   id,
+		SubscriptionName: name,
+	})
+}
+
+func (v *ViperStateManager) History() []HistoryEntry {
+	raw, ok := v.viper.Get("history").([]interface{})
+	if !ok {
+		return nil
+	}
+
+	history := make([]HistoryEntry, 0, len(raw))
+	for _, item := range raw {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		entry := HistoryEntry{
+			SubscriptionID:   stringField(fields, "subscription-id"),
+			SubscriptionName: stringField(fields, "subscription-name"),
+			TenantID:         stringField(fields, "tenant-id"),
+			User:             stringField(fields, "user"),
+			UserType:         stringField(fields, "user-type"),
+			ConfigDir:        stringField(fields, "config-dir"),
+			Command:          stringField(fields, "command"),
+		}
+		switch ts := fields["timestamp"].(type) {
+		case time.Time:
+			entry.Timestamp = ts
+		case string:
+			if parsed, err := time.Parse(time.RFC3339, ts); err == nil {
+				entry.Timestamp = parsed
+			}
+		}
+		if entry.SubscriptionID == "" {
+			continue
+		}
+		history = append(history, entry)
+	}
+	return history
+}
+
+func (v *ViperStateManager) PushHistory(entry HistoryEntry) error {
+	size := v.HistorySize
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+
+	history := append([]HistoryEntry{entry}, v.History()...)
+	if len(history) > size {
+		history = history[:size]
+	}
+
+	raw := make([]interface{}, 0, len(history))
+	for _, e := range history {
+		raw = append(raw, map[string]interface{}{
+			"timestamp":         e.Timestamp.Format(time.RFC3339),
+			"subscription-id":   e.SubscriptionID,
+			"subscription-name": e.SubscriptionName,
+			"tenant-id":         e.TenantID,
+			"user":              e.User,
+			"user-type":         e.UserType,
+			"config-dir":        e.ConfigDir,
+			"command":           e.Command,
+		})
+	}
+
+	v.viper.Set("history", raw)
+	// Keep the single last context for older aztx versions sharing ~/.aztx.yml.
+	v.viper.Set("lastContextId", entry.SubscriptionID)
+	v.viper.Set("lastContextDisplayName", entry.SubscriptionName)
+	return v.viper.WriteConfig()
+}
+
+// stringField returns a field of a decoded history entry as a string.
+func stringField(fields map[string]interface{}, key string) string {
+	value, ok := fields[key]
+	if !ok || value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// ProtectedActivity returns when aztx last saw a protected subscription in use, or
+// the zero time when none is.
+func (v *ViperStateManager) ProtectedActivity() time.Time {
+	t, err := time.Parse(time.RFC3339, v.viper.GetString("protected-activity"))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// SetProtectedActivity records when a protected subscription was last in use. The
+// zero time clears it.
+func (v *ViperStateManager) SetProtectedActivity(t time.Time) error {
+	if t.IsZero() {
+		v.viper.Set("protected-activity", "")
+	} else {
+		v.viper.Set("protected-activity", t.UTC().Format(time.RFC3339))
+	}
 	return v.viper.WriteConfig()
 }
@@ -0,0 +1,136 @@
+package state
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestViper returns a viper instance backed by a temporary ~/.aztx.yml.
+func newTestViper(t *testing.T) *viper.Viper {
+	t.Helper()
+	v := viper.New()
+	v.SetConfigFile(filepath.Join(t.TempDir(), ".aztx.yml"))
+	v.SetConfigType("yml")
+	require.NoError(t, v.WriteConfig())
+	return v
+}
+
+// reload reads the config file written by v into a fresh viper instance.
+func reload(t *testing.T, v *viper.Viper) *viper.Viper {
+	t.Helper()
+	fresh := viper.New()
+	fresh.SetConfigFile(v.ConfigFileUsed())
+	fresh.SetConfigType("yml")
+	require.NoError(t, fresh.ReadInConfig())
+	return fresh
+}
+
+func TestViperStateManager_History(t *testing.T) {
+	v := newTestViper(t)
+	sm := NewViperStateManager(v)
+
+	assert.Empty(t, sm.History())
+
+	first := time.Date(2024, 10, 18, 9, 0, 0, 0, time.UTC)
+	require.NoError(t, sm.PushHistory(HistoryEntry{
+		Timestamp:        first,
+		SubscriptionID:   "9e7969ef-4cb8-4a2d-959f-bfdaae452a3d",
+		SubscriptionName: "Production",
+		TenantID:         "11111111-1111-1111-1111-111111111111",
+		User:             "deploy-sp",
+		UserType:         "servicePrincipal",
+		ConfigDir:        "/home/user/.azure-work",
+		Command:          "aztx",
+	}))
+	require.NoError(t, sm.PushHistory(HistoryEntry{
+		Timestamp:        first.Add(time.Hour),
+		SubscriptionID:   "8aa89ebb-5735-4d1b-9c5c-a8f32a858e99",
+		SubscriptionName: "Development",
+		Command:          "aztx -",
+	}))
+
+	history := NewViperStateManager(reload(t, v)).History()
+	require.Len(t, history, 2)
+	assert.Equal(t, "Development", history[0].SubscriptionName)
+	assert.Equal(t, "aztx -", history[0].Command)
+	assert.True(t, first.Add(time.Hour).Equal(history[0].Timestamp))
+	assert.Equal(t, "Production", history[1].SubscriptionName)
+	assert.Equal(t, "11111111-1111-1111-1111-111111111111", history[1].TenantID)
+	assert.Equal(t, "deploy-sp", history[1].User)
+	assert.Equal(t, "servicePrincipal", history[1].UserType)
+	assert.Equal(t, "/home/user/.azure-work", history[1].ConfigDir)
+	assert.Empty(t, history[0].User)
+	assert.Empty(t, history[0].ConfigDir)
+
+	id, name := sm.GetLastContext()
+	assert.Equal(t, "8aa89ebb-5735-4d1b-9c5c-a8f32a858e99", id)
+	assert.Equal(t, "Development", name)
+}
+
+func TestViperStateManager_HistoryIsBounded(t *testing.T) {
+	tests := []struct {
+		name string
+		size int
+		want int
+	}{
+		{name: "configured size", size: 3, want: 3},
+		{name: "default size", want: DefaultHistorySize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := newTestViper(t)
+			// A history-size in the state file is not the configured one.
+			v.Set("history-size", 1)
+			sm := NewViperStateManager(v)
+			sm.HistorySize = tt.size
+
+			for i := 0; i < DefaultHistorySize+2; i++ {
+				require.NoError(t, sm.PushHistory(HistoryEntry{
+					SubscriptionID:   fmt.Sprintf("id-%d", i),
+					SubscriptionName: fmt.Sprintf("sub-%d", i),
+				}))
+			}
+
+			history := sm.History()
+			require.Len(t, history, tt.want)
+			assert.Equal(t, fmt.Sprintf("id-%d", DefaultHistorySize+1), history[0].SubscriptionID)
+			assert.Equal(t, fmt.Sprintf("id-%d", DefaultHistorySize+2-tt.want), history[tt.want-1].SubscriptionID)
+		})
+	}
+}
+
+func TestViperStateManager_LegacyLastContext(t *testing.T) {
+	v := newTestViper(t)
+	v.Set("lastContextId", "legacy-id")
+	v.Set("lastContextDisplayName", "Legacy")
+	sm := NewViperStateManager(v)
+
+	id, name := sm.GetLastContext()
+	assert.Equal(t, "legacy-id", id)
+	assert.Equal(t, "Legacy", name)
+
+	require.NoError(t, sm.SetLastContext("new-id", "New"))
+	id, name = sm.GetLastContext()
+	assert.Equal(t, "new-id", id)
+	assert.Equal(t, "New", name)
+	assert.Equal(t, "new-id", v.GetString("lastContextId"))
+}
+
+func TestViperStateManager_ProtectedActivity(t *testing.T) {
+	v := newTestViper(t)
+	sm := NewViperStateManager(v)
+	assert.True(t, sm.ProtectedActivity().IsZero())
+
+	used := time.Date(2024, 10, 18, 9, 30, 0, 0, time.UTC)
+	require.NoError(t, sm.SetProtectedActivity(used))
+	assert.True(t, used.Equal(NewViperStateManager(reload(t, v)).ProtectedActivity()))
+
+	require.NoError(t, sm.SetProtectedActivity(time.Time{}))
+	assert.True(t, NewViperStateManager(reload(t, v)).ProtectedActivity().IsZero())
+}
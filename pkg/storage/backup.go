@@ -0,0 +1,155 @@
+package storage
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+)
+
+// backupDirName is the directory, next to the managed file, that holds backups.
+const backupDirName = ".aztx-backups"
+
+// backupTimeFormat sorts lexically in chronological order.
+const backupTimeFormat = "20060102T150405.000000000Z"
+
+// Backup describes a timestamped copy of a file taken before it was overwritten.
+type Backup struct {
+	Name string    // File name of the backup
+	Path string    // Full path to the backup file
+	Time time.Time // When the backup was taken
+}
+
+// BackupDir returns the directory where backups of the adapter's file are kept.
+func (fa *FileAdapter) BackupDir() string {
+	return filepath.Join(filepath.Dir(fa.Path), backupDirName)
+}
+
+// ListBackups returns the available backups of the adapter's file, newest first.
+func (fa *FileAdapter) ListBackups() ([]Backup, error) {
+	if fa.Path == "" {
+		return nil, pkgerrors.ErrPathIsEmpty
+	}
+
+	entries, err := os.ReadDir(fa.BackupDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, pkgerrors.ErrFileOperation("listing backup", err)
+	}
+
+	prefix := filepath.Base(fa.Path) + "."
+	var backups []Backup
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), prefix) {
+			continue
+		}
+		taken, err := time.Parse(backupTimeFormat, strings.TrimPrefix(entry.Name(), prefix))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{
+			Name: entry.Name(),
+			Path: filepath.Join(fa.BackupDir(), entry.Name()),
+			Time: taken,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Time.After(backups[j].Time)
+	})
+	return backups, nil
+}
+
+// FindBackup returns the backup with the given file name.
+func (fa *FileAdapter) FindBackup(name string) (*Backup, error) {
+	backups, err := fa.ListBackups()
+	if err != nil {
+		return nil, err
+	}
+	for _, backup := range backups {
+		if backup.Name == name {
+			return &backup, nil
+		}
+	}
+	return nil, pkgerrors.ErrBackupNotFound
+}
+
+// Restore replaces the adapter's file with the content of a backup. The file being
+// replaced is itself backed up first, so a restore can be undone. The file is locked
+// while it is replaced, like any other write made by aztx.
+func (fa *FileAdapter) Restore(backup Backup) error {
+	unlock, err := fa.Lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	data, err := os.ReadFile(backup.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return pkgerrors.ErrBackupNotFound
+		}
+		return pkgerrors.ErrFileOperation("reading backup", err)
+	}
+	if !json.Valid(trimBOM(data)) {
+		return pkgerrors.ErrFileOperation("validating backup", pkgerrors.ErrInvalidBackup)
+	}
+	return fa.Write(data)
+}
+
+// backup copies the current file into the backup directory and prunes the oldest
+// backups so that at most fa.Backups remain. It does nothing when backups are
+// disabled or the file does not exist yet.
+func (fa *FileAdapter) backup() error {
+	if fa.Backups <= 0 {
+		return nil
+	}
+
+	data, err := os.ReadFile(fa.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return pkgerrors.ErrFileOperation("reading", err)
+	}
+
+	if err := os.MkdirAll(fa.BackupDir(), 0700); err != nil {
+		return pkgerrors.ErrFileOperation("creating backup", err)
+	}
+
+	// Coarse clocks can hand out the same timestamp twice, so never reuse a name.
+	taken := time.Now().UTC()
+	target := fa.backupPath(taken)
+	for {
+		if _, err := os.Stat(target); err != nil {
+			break
+		}
+		taken = taken.Add(time.Nanosecond)
+		target = fa.backupPath(taken)
+	}
+	if err := writeFileAtomic(target, data); err != nil {
+		return pkgerrors.ErrFileOperation("writing backup", err)
+	}
+
+	backups, err := fa.ListBackups()
+	if err != nil {
+		return err
+	}
+	for i := fa.Backups; i < len(backups); i++ {
+		if err := os.Remove(backups[i].Path); err != nil && !os.IsNotExist(err) {
+			return pkgerrors.ErrFileOperation("removing backup", err)
+		}
+	}
+	return nil
+}
+
+// backupPath returns the path of a backup taken at the given time.
+func (fa *FileAdapter) backupPath(taken time.Time) string {
+	return filepath.Join(fa.BackupDir(), filepath.Base(fa.Path)+"."+taken.Format(backupTimeFormat))
+}
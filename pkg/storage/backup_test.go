@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileAdapter_Write_IsAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "azureProfile.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"old": true}`), 0600))
+
+	fa := &FileAdapter{Path: path}
+	require.NoError(t, fa.Write([]byte(`{"new": true}`)))
+
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{"new": true}`, string(got))
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(path)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm(), "file mode should be preserved")
+	}
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary files should be left behind")
+}
+
+func TestFileAdapter_Write_RotatesBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "azureProfile.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"version": 0}`), 0644))
+
+	fa := &FileAdapter{Path: path, Backups: 2}
+	for _, content := range []string{`{"version": 1}`, `{"version": 2}`, `{"version": 3}`} {
+		require.NoError(t, fa.Write([]byte(content)))
+	}
+
+	backups, err := fa.ListBackups()
+	require.NoError(t, err)
+	require.Len(t, backups, 2)
+
+	newest, err := os.ReadFile(backups[0].Path)
+	require.NoError(t, err)
+	assert.Equal(t, `{"version": 2}`, string(newest))
+
+	oldest, err := os.ReadFile(backups[1].Path)
+	require.NoError(t, err)
+	assert.Equal(t, `{"version": 1}`, string(oldest))
+}
+
+func TestFileAdapter_Write_BackupsDisabled(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "azureProfile.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{}`), 0644))
+
+	fa := &FileAdapter{Path: path}
+	require.NoError(t, fa.Write([]byte(`{"new": true}`)))
+
+	_, err := os.Stat(fa.BackupDir())
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFileAdapter_Restore(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "azureProfile.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"version": 1}`), 0644))
+
+	fa := &FileAdapter{Path: path, Backups: 5}
+	require.NoError(t, fa.Write([]byte(`{"version": 2}`)))
+
+	backups, err := fa.ListBackups()
+	require.NoError(t, err)
+	require.Len(t, backups, 1)
+
+	backup, err := fa.FindBackup(backups[0].Name)
+	require.NoError(t, err)
+	require.NoError(t, fa.Restore(*backup))
+
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{"version": 1}`, string(got))
+
+	backups, err = fa.ListBackups()
+	require.NoError(t, err)
+	assert.Len(t, backups, 2, "restoring should back up the replaced file")
+}
+
+func TestFileAdapter_Restore_Errors(t *testing.T) {
+	dir := t.TempDir()
+	fa := &FileAdapter{Path: filepath.Join(dir, "azureProfile.json")}
+
+	_, err := fa.FindBackup("missing")
+	assert.ErrorIs(t, err, pkgerrors.ErrBackupNotFound)
+
+	unlock, err := fa.Lock()
+	require.NoError(t, err)
+	contender := &FileAdapter{Path: fa.Path, LockTimeout: 50 * time.Millisecond}
+	err = contender.Restore(Backup{Name: "missing", Path: filepath.Join(dir, "missing")})
+	assert.ErrorIs(t, err, pkgerrors.ErrLockTimeout, "restoring waits for the profile lock")
+	require.NoError(t, unlock())
+
+	invalid := filepath.Join(dir, "invalid")
+	require.NoError(t, os.WriteFile(invalid, []byte(`{not json`), 0644))
+	err = fa.Restore(Backup{Name: "invalid", Path: invalid})
+	assert.ErrorIs(t, err, pkgerrors.ErrInvalidBackup)
+}
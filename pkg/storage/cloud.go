@@ -0,0 +1,151 @@
+package storage
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+)
+
+// CLIConfigFileName is the name of the Azure CLI settings file inside a config directory.
+const CLIConfigFileName = "config"
+
+// DefaultCloud is the cloud the Azure CLI targets when its settings name none.
+const DefaultCloud = "AzureCloud"
+
+// ActiveCloud returns the cloud the Azure CLI using the adapter's config directory
+// targets, as set by "az cloud set".
+func (fa *FileAdapter) ActiveCloud() (string, error) {
+	data, err := os.ReadFile(filepath.Join(fa.ConfigDir(), CLIConfigFileName))
+	if os.IsNotExist(err) {
+		return DefaultCloud, nil
+	}
+	if err != nil {
+		return "", pkgerrors.ErrFileOperation("reading Azure CLI config", err)
+	}
+	if name, ok := iniValue(data, "cloud", "name"); ok && name != "" {
+		return name, nil
+	}
+	return DefaultCloud, nil
+}
+
+// SetActiveCloud makes the Azure CLI using the adapter's config directory target the
+// named cloud. Only the name of the [cloud] section is changed; other settings,
+// comments and sections are kept as they are. Returns false when the CLI already
+// targets the cloud, in which case the file is left untouched.
+func (fa *FileAdapter) SetActiveCloud(name string) (bool, error) {
+	active, err := fa.ActiveCloud()
+	if err != nil {
+		return false, err
+	}
+	if strings.EqualFold(active, name) {
+		return false, nil
+	}
+
+	path := filepath.Join(fa.ConfigDir(), CLIConfigFileName)
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return false, pkgerrors.ErrFileOperation("reading Azure CLI config", err)
+	}
+	if err := writeFileAtomic(path, setINIValue(data, "cloud", "name", name)); err != nil {
+		return false, pkgerrors.ErrFileOperation("writing Azure CLI config", err)
+	}
+	return true, nil
+}
+
+// iniValue returns the value of key in section of an INI document.
+func iniValue(data []byte, section, key string) (string, bool) {
+	current := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		if name, ok := iniSection(line); ok {
+			current = name
+			continue
+		}
+		if !strings.EqualFold(current, section) {
+			continue
+		}
+		if k, v, ok := iniEntry(line); ok && strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+	return "", false
+}
+
+// setINIValue returns data with key in section set to value. An existing entry is
+// replaced in place, a missing one is added at the end of the section and a missing
+// section is appended to the document.
+func setINIValue(data []byte, section, key, value string) []byte {
+	entry := key + " = " + value
+	lines := strings.Split(string(data), "\n")
+	current, end := "", -1
+	for i, line := range lines {
+		if name, ok := iniSection(line); ok {
+			current = name
+			if strings.EqualFold(name, section) {
+				end = i + 1
+			}
+			continue
+		}
+		if !strings.EqualFold(current, section) {
+			continue
+		}
+		if k, _, ok := iniEntry(line); ok && strings.EqualFold(k, key) {
+			lines[i] = entry + lineEnding(line)
+			return []byte(strings.Join(lines, "\n"))
+		}
+		if strings.TrimSpace(line) != "" {
+			end = i + 1
+		}
+	}
+
+	if end >= 0 {
+		lines = append(lines[:end], append([]string{entry}, lines[end:]...)...)
+		return []byte(strings.Join(lines, "\n"))
+	}
+
+	var buf bytes.Buffer
+	buf.Write(data)
+	if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+		buf.WriteString("\n")
+	}
+	if len(bytes.TrimSpace(data)) > 0 {
+		buf.WriteString("\n")
+	}
+	buf.WriteString("[" + section + "]\n" + entry + "\n")
+	return buf.Bytes()
+}
+
+// iniSection returns the name of the section a line opens.
+func iniSection(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+		return "", false
+	}
+	return strings.TrimSpace(line[1 : len(line)-1]), true
+}
+
+// iniEntry splits a "key = value" line. Comments and blank lines are not entries.
+func iniEntry(line string) (string, string, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+		return "", "", false
+	}
+	k, v, ok := strings.Cut(line, "=")
+	if !ok {
+		k, v, ok = strings.Cut(line, ":")
+	}
+	if !ok {
+		return "", "", false
+	}
+	return strings.TrimSpace(k), strings.TrimSpace(v), true
+}
+
+// lineEnding returns the carriage return ending a line of a CRLF document, if any.
+func lineEnding(line string) string {
+	if strings.HasSuffix(line, "\r") {
+		return "\r"
+	}
+	return ""
+}
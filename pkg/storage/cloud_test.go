@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileAdapter_ActiveCloud(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+	}{
+		{name: "no config file", want: DefaultCloud},
+		{name: "no cloud section", config: "[core]\noutput = table\n", want: DefaultCloud},
+		{name: "cloud section", config: "[core]\noutput = table\n\n[cloud]\nname = AzureUSGovernment\n", want: "AzureUSGovernment"},
+		{name: "name in another section", config: "[defaults]\nname = AzureChinaCloud\n", want: DefaultCloud},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tt.config != "" {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, CLIConfigFileName), []byte(tt.config), 0600))
+			}
+			fa := &FileAdapter{}
+			fa.UseConfigDir(dir)
+
+			got, err := fa.ActiveCloud()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestFileAdapter_SetActiveCloud(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		cloud       string
+		want        string
+		wantChanged bool
+	}{
+		{
+			name:        "creates the config file",
+			cloud:       "AzureUSGovernment",
+			want:        "[cloud]\nname = AzureUSGovernment\n",
+			wantChanged: true,
+		},
+		{
+			name:        "replaces the name and keeps other sections",
+			config:      "# managed by az\n[core]\noutput = table\n\n[cloud]\nname = AzureCloud\nprofile = latest\n",
+			cloud:       "AzureChinaCloud",
+			want:        "# managed by az\n[core]\noutput = table\n\n[cloud]\nname = AzureChinaCloud\nprofile = latest\n",
+			wantChanged: true,
+		},
+		{
+			name:        "adds the name to an existing section",
+			config:      "[cloud]\nprofile = latest\n\n[core]\noutput = json\n",
+			cloud:       "AzureUSGovernment",
+			want:        "[cloud]\nprofile = latest\nname = AzureUSGovernment\n\n[core]\noutput = json\n",
+			wantChanged: true,
+		},
+		{
+			name:        "appends the section",
+			config:      "[core]\noutput = json",
+			cloud:       "AzureUSGovernment",
+			want:        "[core]\noutput = json\n\n[cloud]\nname = AzureUSGovernment\n",
+			wantChanged: true,
+		},
+		{
+			name:   "leaves the file alone when the cloud is active",
+			config: "[cloud]\nname=azurecloud\n",
+			cloud:  "AzureCloud",
+			want:   "[cloud]\nname=azurecloud\n",
+		},
+		{
+			name:  "does not create a file for the default cloud",
+			cloud: DefaultCloud,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, CLIConfigFileName)
+			if tt.config != "" {
+				require.NoError(t, os.WriteFile(path, []byte(tt.config), 0600))
+			}
+			fa := &FileAdapter{}
+			fa.UseConfigDir(dir)
+
+			changed, err := fa.SetActiveCloud(tt.cloud)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantChanged, changed)
+
+			data, err := os.ReadFile(path)
+			if tt.want == "" {
+				assert.True(t, os.IsNotExist(err))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(data))
+
+			active, err := fa.ActiveCloud()
+			require.NoError(t, err)
+			assert.True(t, strings.EqualFold(tt.cloud, active), "active cloud %s", active)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+)
+
+// ProfileFileName is the name of the Azure CLI profile inside a config directory.
+const ProfileFileName = "azureProfile.json"
+
+// ConfigDirEnv is the environment variable the Azure CLI reads its config directory from.
+const ConfigDirEnv = "AZURE_CONFIG_DIR"
+
+// ConfigDir is a named Azure CLI configuration directory.
+type ConfigDir struct {
+	Name string // Name used to refer to the directory in aztx
+	Path string // Absolute path of the directory
+}
+
+// ResolveConfigDir returns the Azure CLI configuration directory. An explicit
+// override wins, with a leading ~ expanded. Otherwise it follows the Azure CLI:
+// AZURE_CONFIG_DIR when set, taken as is since az does not expand ~ in it, falling
+// back to ~/.azure.
+func ResolveConfigDir(override string) (string, error) {
+	if override != "" {
+		return expandHome(override)
+	}
+	if dir := os.Getenv(ConfigDirEnv); dir != "" {
+		return dir, nil
+	}
+	return expandHome(filepath.Join("~", ".azure"))
+}
+
+// NewConfigDirs builds a sorted list of named config directories, expanding a
+// leading ~ in each path.
+func NewConfigDirs(dirs map[string]string) ([]ConfigDir, error) {
+	result := make([]ConfigDir, 0, len(dirs))
+	for name, path := range dirs {
+		expanded, err := expandHome(path)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ConfigDir{Name: name, Path: expanded})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// UseConfigDir points the adapter at the profile inside an Azure CLI config directory.
+func (fa *FileAdapter) UseConfigDir(dir string) {
+	fa.Path = filepath.Join(dir, ProfileFileName)
+}
+
+// ConfigDir returns the Azure CLI config directory containing the adapter's file.
+func (fa *FileAdapter) ConfigDir() string {
+	return filepath.Dir(fa.Path)
+}
+
+// BaseConfigDir returns the absolute path of the Azure CLI config directory the
+// adapter's profile belongs to. For the private profile of a session that is the
+// config directory the session overlays.
+func (fa *FileAdapter) BaseConfigDir() string {
+	dir := fa.ConfigDir()
+	if s, err := OpenSession(dir); err == nil {
+		dir = s.Base
+	}
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	return dir
+}
+
+// expandHome replaces a leading ~ with the user's home directory.
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, `~\`) {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", pkgerrors.ErrFetchingHomePath
+	}
+	return filepath.Join(home, path[1:]), nil
+}
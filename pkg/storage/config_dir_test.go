@@ -0,0 +1,80 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveConfigDir(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		override string
+		env      string
+		want     string
+	}{
+		{
+			name: "defaults to ~/.azure",
+			want: filepath.Join(home, ".azure"),
+		},
+		{
+			name: "honors AZURE_CONFIG_DIR",
+			env:  filepath.Join("custom", "azure"),
+			want: filepath.Join("custom", "azure"),
+		},
+		{
+			name: "keeps ~ in AZURE_CONFIG_DIR like az",
+			env:  "~/.azure-customer",
+			want: "~/.azure-customer",
+		},
+		{
+			name:     "expands ~ in the override",
+			override: "~/.azure-customer",
+			want:     filepath.Join(home, ".azure-customer"),
+		},
+		{
+			name:     "override wins over AZURE_CONFIG_DIR",
+			override: filepath.Join("override"),
+			env:      filepath.Join("custom", "azure"),
+			want:     filepath.Join("override"),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv(ConfigDirEnv, tt.env)
+			got, err := ResolveConfigDir(tt.override)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestNewConfigDirs(t *testing.T) {
+	home, err := os.UserHomeDir()
+	require.NoError(t, err)
+
+	dirs, err := NewConfigDirs(map[string]string{
+		"work":     "~/.azure-work",
+		"customer": filepath.Join("srv", "customer"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []ConfigDir{
+		{Name: "customer", Path: filepath.Join("srv", "customer")},
+		{Name: "work", Path: filepath.Join(home, ".azure-work")},
+	}, dirs)
+}
+
+func TestFileAdapter_UseConfigDir(t *testing.T) {
+	fa := &FileAdapter{}
+	dir := filepath.Join("some", "dir")
+	fa.UseConfigDir(dir)
+	assert.Equal(t, filepath.Join(dir, ProfileFileName), fa.Path)
+	assert.Equal(t, dir, fa.ConfigDir())
+}
@@ -0,0 +1,485 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// The document file implements a lossless overlay of typed configuration onto the
+// raw azureProfile.json owned by the Azure CLI. Keys that aztx does not model, the
+// original key order and the original formatting are all preserved, so the only
+// bytes that change on disk are the values aztx actually modified.
+
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// node is an order-preserving representation of a JSON value.
+type node struct {
+	kind     byte // '{' for objects, '[' for arrays, 0 for scalars
+	keys     []string
+	fields   map[string]*node
+	items    []*node
+	raw      json.RawMessage
+	verbatim bool // raw holds the original encoding and can be written as-is
+}
+
+// style describes the whitespace conventions of an encoded JSON document.
+type style struct {
+	newline string // empty when the document is written on a single line
+	indent  string
+	colon   string
+	comma   string
+}
+
+// mergeDocument encodes v on top of the original document, keeping everything in
+// original that v does not model. When original is empty or not valid JSON, v is
+// encoded with the default indented format.
+func mergeDocument(original []byte, v interface{}) ([]byte, error) {
+	updated, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	hasBOM := bytes.HasPrefix(original, utf8BOM)
+	body := bytes.TrimPrefix(original, utf8BOM)
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) == 0 {
+		return json.MarshalIndent(v, "", "  ")
+	}
+
+	origNode, err := parseNode(trimmed, true)
+	if err != nil {
+		return json.MarshalIndent(v, "", "  ")
+	}
+	newNode, err := parseNode(updated, false)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := mergeNode(origNode, newNode, reflect.TypeOf(v))
+
+	var buf bytes.Buffer
+	if hasBOM {
+		buf.Write(utf8BOM)
+	}
+	// Keep any whitespace surrounding the root value, e.g. a trailing newline.
+	start := bytes.Index(body, trimmed[:1])
+	buf.Write(body[:start])
+	encodeNode(&buf, merged, detectStyle(trimmed), "")
+	buf.Write(body[start+len(trimmed):])
+	return buf.Bytes(), nil
+}
+
+// parseNode decodes data into an ordered node tree, retaining the raw bytes of
+// every scalar value. When verbatim is set, composite values also keep their raw
+// bytes so that unchanged subtrees can be written back exactly as they were read.
+func parseNode(data []byte, verbatim bool) (*node, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, &json.SyntaxError{}
+	}
+
+	switch data[0] {
+	case '{':
+		dec := json.NewDecoder(bytes.NewReader(data))
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		n := &node{kind: '{', fields: make(map[string]*node)}
+		if verbatim {
+			n.raw, n.verbatim = append(json.RawMessage(nil), data...), true
+		}
+		for dec.More() {
+			tok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, _ := tok.(string)
+			var raw json.RawMessage
+			if err := dec.Decode(&raw); err != nil {
+				return nil, err
+			}
+			child, err := parseNode(raw, verbatim)
+			if err != nil {
+				return nil, err
+			}
+			if _, exists := n.fields[key]; !exists {
+				n.keys = append(n.keys, key)
+			}
+			n.fields[key] = child
+		}
+		return n, nil
+	case '[':
+		var raws []json.RawMessage
+		if err := json.Unmarshal(data, &raws); err != nil {
+			return nil, err
+		}
+		n := &node{kind: '['}
+		if verbatim {
+			n.raw, n.verbatim = append(json.RawMessage(nil), data...), true
+		}
+		for _, raw := range raws {
+			child, err := parseNode(raw, verbatim)
+			if err != nil {
+				return nil, err
+			}
+			n.items = append(n.items, child)
+		}
+		return n, nil
+	default:
+		if !json.Valid(data) {
+			return nil, &json.SyntaxError{}
+		}
+		return &node{raw: append(json.RawMessage(nil), data...), verbatim: verbatim}, nil
+	}
+}
+
+// mergeNode overlays updated onto original. t is the Go type that produced updated
+// and is used to tell removed fields apart from fields aztx does not model.
+func mergeNode(original, updated *node, t reflect.Type) *node {
+	if original == nil || updated == nil || original.kind != updated.kind {
+		return updated
+	}
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch updated.kind {
+	case '{':
+		modeled := jsonFields(t)
+		merged := &node{kind: '{', fields: make(map[string]*node)}
+		for _, key := range original.keys {
+			if child, ok := updated.fields[key]; ok {
+				var fieldType reflect.Type
+				if modeled != nil {
+					fieldType = modeled[key]
+				}
+				merged.keys = append(merged.keys, key)
+				merged.fields[key] = mergeNode(original.fields[key], child, fieldType)
+				continue
+			}
+			if _, ok := modeled[key]; ok || modeled == nil {
+				// The field is modeled but was omitted, so it has been removed.
+				continue
+			}
+			merged.keys = append(merged.keys, key)
+			merged.fields[key] = original.fields[key]
+		}
+		for _, key := range updated.keys {
+			if _, ok := merged.fields[key]; ok {
+				continue
+			}
+			if _, ok := original.fields[key]; ok {
+				continue
+			}
+			if zeroNode(updated.fields[key]) {
+				// Older Azure CLI versions do not write every modeled key; a missing
+				// key still holding its zero value has not been changed.
+				continue
+			}
+			merged.keys = append(merged.keys, key)
+			merged.fields[key] = updated.fields[key]
+		}
+		if unchangedObject(original, merged) {
+			return original
+		}
+		return merged
+	case '[':
+		var elemType reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elemType = t.Elem()
+		}
+		used := make([]bool, len(original.items))
+		merged := &node{kind: '['}
+		for i, item := range updated.items {
+			match := matchItem(original.items, used, item, i)
+			var previous *node
+			if match >= 0 {
+				used[match] = true
+				previous = original.items[match]
+			}
+			merged.items = append(merged.items, mergeNode(previous, item, elemType))
+		}
+		if unchangedArray(original, merged) {
+			return original
+		}
+		return merged
+	default:
+		if scalarEqual(original.raw, updated.raw) {
+			return original
+		}
+		return updated
+	}
+}
+
+// zeroNode reports whether n encodes the zero value of its Go type: null, false, 0,
+// an empty string or the nil UUID, an empty array, or an object of zero values.
+func zeroNode(n *node) bool {
+	switch n.kind {
+	case '{':
+		for _, key := range n.keys {
+			if !zeroNode(n.fields[key]) {
+				return false
+			}
+		}
+		return true
+	case '[':
+		return len(n.items) == 0
+	}
+	var value interface{}
+	if json.Unmarshal(n.raw, &value) != nil {
+		return false
+	}
+	switch v := value.(type) {
+	case nil:
+		return true
+	case bool:
+		return !v
+	case float64:
+		return v == 0
+	case string:
+		return v == "" || v == uuid.Nil.String()
+	}
+	return false
+}
+
+// unchangedObject reports whether merged holds exactly the children of original.
+func unchangedObject(original, merged *node) bool {
+	if len(original.keys) != len(merged.keys) {
+		return false
+	}
+	for i, key := range original.keys {
+		if merged.keys[i] != key || merged.fields[key] != original.fields[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// unchangedArray reports whether merged holds exactly the items of original.
+func unchangedArray(original, merged *node) bool {
+	if len(original.items) != len(merged.items) {
+		return false
+	}
+	for i := range original.items {
+		if merged.items[i] != original.items[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// matchItem finds the unused element of items that corresponds to item. Objects are
+// matched by their identifying keys, anything else by position.
+func matchItem(items []*node, used []bool, item *node, position int) int {
+	if item.kind == '{' {
+		id := identity(item)
+		if id != "" {
+			for i, candidate := range items {
+				if !used[i] && candidate.kind == '{' && identity(candidate) == id {
+					return i
+				}
+			}
+			return -1
+		}
+	}
+	if position < len(items) && !used[position] {
+		return position
+	}
+	return -1
+}
+
+// identity builds a comparable key from the fields that identify profile entries:
+// the subscription or tenant ID and, for subscriptions, the signed-in user.
+func identity(n *node) string {
+	var parts []string
+	for _, path := range [][]string{{"id"}, {"tenantId"}, {"user", "name"}, {"user", "type"}} {
+		child := n
+		for _, key := range path {
+			if child == nil || child.kind != '{' {
+				child = nil
+				break
+			}
+			child = child.fields[key]
+		}
+		if child == nil || child.kind != 0 {
+			continue
+		}
+		var value interface{}
+		if json.Unmarshal(child.raw, &value) != nil {
+			continue
+		}
+		parts = append(parts, strings.Join(path, ".")+"="+strings.ToLower(fmt.Sprint(value)))
+	}
+	return strings.Join(parts, ";")
+}
+
+// scalarEqual reports whether two raw scalars hold the same value, treating UUIDs
+// that differ only in letter case as equal.
+func scalarEqual(a, b json.RawMessage) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	var av, bv interface{}
+	if json.Unmarshal(a, &av) != nil || json.Unmarshal(b, &bv) != nil {
+		return false
+	}
+	as, aok := av.(string)
+	bs, bok := bv.(string)
+	if aok && bok {
+		if as == bs {
+			return true
+		}
+		au, aerr := uuid.Parse(as)
+		bu, berr := uuid.Parse(bs)
+		return aerr == nil && berr == nil && au == bu
+	}
+	return reflect.DeepEqual(av, bv)
+}
+
+// jsonFields returns the JSON keys modeled by a struct type, mapped to their Go
+// types. It returns nil for types that are not structs.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// detectStyle inspects an encoded document to reproduce its whitespace conventions.
+func detectStyle(data []byte) style {
+	s := style{colon: ": ", comma: ","}
+	if len(data) < 2 || (data[0] != '{' && data[0] != '[') {
+		return s
+	}
+
+	// Whitespace between the opening bracket and the first element.
+	opening := leadingSpace(data[1:])
+	if i := strings.LastIndex(opening, "\n"); i >= 0 {
+		s.newline = "\n"
+		if strings.HasSuffix(opening[:i], "\r") {
+			s.newline = "\r\n"
+		}
+		s.indent = opening[i+1:]
+	}
+
+	if data[0] != '{' {
+		if s.newline == "" {
+			s.comma = ", "
+		}
+		return s
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if _, err := dec.Token(); err != nil {
+		return s
+	}
+	if !dec.More() {
+		return s
+	}
+	if _, err := dec.Token(); err != nil {
+		return s
+	}
+	keyEnd := int(dec.InputOffset())
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return s
+	}
+	valueEnd := int(dec.InputOffset())
+	s.colon = string(data[keyEnd : valueEnd-len(raw)])
+
+	if s.newline == "" {
+		s.comma = ","
+		rest := data[valueEnd:]
+		if end := bytes.IndexAny(rest, "\"}"); end > 0 && rest[end] == '"' {
+			s.comma = string(rest[:end])
+		} else if strings.HasSuffix(s.colon, " ") {
+			s.comma = ", "
+		}
+	}
+	return s
+}
+
+// leadingSpace returns the JSON whitespace at the start of data.
+func leadingSpace(data []byte) string {
+	end := 0
+	for end < len(data) && strings.IndexByte(" \t\r\n", data[end]) >= 0 {
+		end++
+	}
+	return string(data[:end])
+}
+
+// encodeNode writes n to buf using the supplied style.
+func encodeNode(buf *bytes.Buffer, n *node, s style, prefix string) {
+	if n.verbatim {
+		buf.Write(n.raw)
+		return
+	}
+	switch n.kind {
+	case '{':
+		if len(n.keys) == 0 {
+			buf.WriteString("{}")
+			return
+		}
+		buf.WriteByte('{')
+		inner := prefix + s.indent
+		for i, key := range n.keys {
+			if i > 0 {
+				buf.WriteString(s.comma)
+			}
+			if s.newline != "" {
+				buf.WriteString(s.newline + inner)
+			}
+			encodedKey, _ := json.Marshal(key)
+			buf.Write(encodedKey)
+			buf.WriteString(s.colon)
+			encodeNode(buf, n.fields[key], s, inner)
+		}
+		if s.newline != "" {
+			buf.WriteString(s.newline + prefix)
+		}
+		buf.WriteByte('}')
+	case '[':
+		if len(n.items) == 0 {
+			buf.WriteString("[]")
+			return
+		}
+		buf.WriteByte('[')
+		inner := prefix + s.indent
+		for i, item := range n.items {
+			if i > 0 {
+				buf.WriteString(s.comma)
+			}
+			if s.newline != "" {
+				buf.WriteString(s.newline + inner)
+			}
+			encodeNode(buf, item, s, inner)
+		}
+		if s.newline != "" {
+			buf.WriteString(s.newline + prefix)
+		}
+		buf.WriteByte(']')
+	default:
+		buf.Write(n.raw)
+	}
+}
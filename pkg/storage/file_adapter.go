@@ -5,14 +5,19 @@ import (
 	"encoding/json"
 	"io"
 	"os"
+	"time"
 
 	pkgerrors "github.com/riweston/aztx/pkg/errors"
 	"github.com/riweston/aztx/pkg/types"
 )
 
 // FileAdapter handles file read and write operations.
+// Writes are atomic, and when Backups is greater than zero the previous content of
+// the file is kept as a timestamped backup before it is replaced.
 type FileAdapter struct {
-	Path string
+	Path        string
+	Backups     int           // Number of backups to keep; zero disables backups
+	LockTimeout time.Duration // How long Lock waits for other holders; zero uses DefaultLockTimeout
 }
 
 // FetchDefaultPath sets the path to the default file location.
@@ -56,34 +61,53 @@ func (fa *FileAdapter) ReadConfig() (*types.Configuration, error) {
 		return nil, pkgerrors.ErrFileOperation("reading", err)
 	}
 
-	// Handle BOM (Byte Order Mark)
-	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
-
 	var config types.Configuration
-	if err := json.Unmarshal(data, &config); err != nil {
+	if err := json.Unmarshal(trimBOM(data), &config); err != nil {
 		return nil, pkgerrors.ErrFileOperation("unmarshaling", err)
 	}
+	if config.ActiveCloud, err = fa.ActiveCloud(); err != nil {
+		return nil, err
+	}
 	return &config, nil
 }
 
-// Write writes data to the file at the specified path.
+// Write atomically replaces the file at the specified path with data, backing up
+// the previous content first.
 func (fa *FileAdapter) Write(data []byte) error {
 	if fa.Path == "" {
 		return pkgerrors.ErrPathIsEmpty
 	}
-	return os.WriteFile(fa.Path, data, 0644)
+	if err := fa.backup(); err != nil {
+		return err
+	}
+	if err := writeFileAtomic(fa.Path, data); err != nil {
+		return pkgerrors.ErrFileOperation("writing", err)
+	}
+	return nil
 }
 
-// WriteConfig marshals and writes configuration to file
+// WriteConfig marshals and writes configuration to file. The configuration is
+// overlaid onto the existing file so that fields aztx does not model, key order
+// and formatting written by the Azure CLI are preserved.
 func (fa *FileAdapter) WriteConfig(config *types.Configuration) error {
 	if fa.Path == "" {
 		return pkgerrors.ErrPathIsEmpty
 	}
 
-	data, err := json.MarshalIndent(config, "", "  ")
+	original, err := os.ReadFile(fa.Path)
+	if err != nil && !os.IsNotExist(err) {
+		return pkgerrors.ErrFileOperation("reading", err)
+	}
+
+	data, err := mergeDocument(original, config)
 	if err != nil {
 		return pkgerrors.ErrFileOperation("marshaling", err)
 	}
 
-	return os.WriteFile(fa.Path, data, 0644)
+	return fa.Write(data)
+}
+
+// trimBOM removes a leading UTF-8 byte order mark, which the Azure CLI writes.
+func trimBOM(data []byte) []byte {
+	return bytes.TrimPrefix(data, utf8BOM)
 }
@@ -188,3 +188,99 @@ func TestFileAdapter_ReadConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestFileAdapter_WriteConfig_PreservesUnmodeledFields(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		golden string
+	}{
+		{
+			name:   "indented profile",
+			input:  "testdata/azureProfile.json",
+			golden: "testdata/azureProfile.switched.golden.json",
+		},
+		{
+			name:   "azure cli profile with BOM",
+			input:  "testdata/azureProfile.cli.json",
+			golden: "testdata/azureProfile.cli.switched.golden.json",
+		},
+		{
+			name:   "profile of an older azure cli without home tenants",
+			input:  "testdata/azureProfile.legacy.json",
+			golden: "testdata/azureProfile.legacy.switched.golden.json",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input, err := os.ReadFile(tt.input)
+			require.NoError(t, err)
+			want, err := os.ReadFile(tt.golden)
+			require.NoError(t, err)
+
+			path := filepath.Join(t.TempDir(), "azureProfile.json")
+			require.NoError(t, os.WriteFile(path, input, 0644))
+
+			fa := &FileAdapter{Path: path}
+			cfg, err := fa.ReadConfig()
+			require.NoError(t, err)
+			for i := range cfg.Subscriptions {
+				cfg.Subscriptions[i].IsDefault = !cfg.Subscriptions[i].IsDefault
+			}
+			require.NoError(t, fa.WriteConfig(cfg))
+
+			got, err := os.ReadFile(path)
+			require.NoError(t, err)
+			assert.Equal(t, string(want), string(got))
+		})
+	}
+}
+
+func TestFileAdapter_WriteConfig_UnchangedConfigIsIdentical(t *testing.T) {
+	input, err := os.ReadFile("testdata/azureProfile.json")
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "azureProfile.json")
+	require.NoError(t, os.WriteFile(path, input, 0644))
+
+	fa := &FileAdapter{Path: path}
+	cfg, err := fa.ReadConfig()
+	require.NoError(t, err)
+	require.NoError(t, fa.WriteConfig(cfg))
+
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, string(input), string(got))
+}
+
+func TestFileAdapter_WriteConfig_RemovesClearedFields(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "azureProfile.json")
+	input := `{"installationId": "a1a2a3a4-b1b2-c1c2-d1d2-d3d4d5d6d7d8", "tenants": [{"tenantId": "a1a2a3a4-b1b2-c1c2-d1d2-d3d4d5d6d7d8", "name": "Test", "customName": "Old", "extra": true}], "subscriptions": []}`
+	require.NoError(t, os.WriteFile(path, []byte(input), 0644))
+
+	fa := &FileAdapter{Path: path}
+	cfg, err := fa.ReadConfig()
+	require.NoError(t, err)
+	cfg.Tenants[0].CustomName = ""
+	require.NoError(t, fa.WriteConfig(cfg))
+
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `{"installationId": "a1a2a3a4-b1b2-c1c2-d1d2-d3d4d5d6d7d8", "tenants": [{"tenantId": "a1a2a3a4-b1b2-c1c2-d1d2-d3d4d5d6d7d8", "name": "Test", "extra": true}], "subscriptions": []}`, string(got))
+}
+
+func TestFileAdapter_WriteConfig_NewFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "azureProfile.json")
+	cfg := &types.Configuration{
+		InstallationID: uuid.MustParse("a1a2a3a4-b1b2-c1c2-d1d2-d3d4d5d6d7d8"),
+		Subscriptions:  []types.Subscription{},
+	}
+
+	fa := &FileAdapter{Path: path}
+	require.NoError(t, fa.WriteConfig(cfg))
+
+	got, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "{\n  \"installationId\": \"a1a2a3a4-b1b2-c1c2-d1d2-d3d4d5d6d7d8\",\n  \"subscriptions\": []\n}", string(got))
+}
@@ -0,0 +1,111 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+)
+
+// DefaultLockTimeout is used when FileAdapter.LockTimeout is not set.
+const DefaultLockTimeout = 10 * time.Second
+
+// lockRetryInterval is how often a contended lock is retried.
+const lockRetryInterval = 25 * time.Millisecond
+
+// errLocked is returned by tryLock when another process holds the lock.
+var errLocked = errors.New("lock is held by another process")
+
+// FileLock is an exclusive advisory lock guarding a file against concurrent
+// read-modify-write cycles from other aztx processes. The Azure CLI does not take
+// it, so it offers no protection against az commands such as "az login" writing the
+// file at the same time.
+type FileLock struct {
+	file *os.File
+}
+
+// LockPath returns the path of the lock file guarding the adapter's file.
+func (fa *FileAdapter) LockPath() string {
+	return fa.Path + ".lock"
+}
+
+// Lock acquires an exclusive lock on the adapter's file, waiting up to
+// LockTimeout for other holders to release it. The lock is advisory: it
+// coordinates aztx processes only, and the returned function must be called to
+// release it.
+func (fa *FileAdapter) Lock() (func() error, error) {
+	lock, err := fa.acquire()
+	if err != nil {
+		return nil, err
+	}
+	return lock.Unlock, nil
+}
+
+func (fa *FileAdapter) acquire() (*FileLock, error) {
+	if fa.Path == "" {
+		return nil, pkgerrors.ErrPathIsEmpty
+	}
+
+	timeout := fa.LockTimeout
+	if timeout <= 0 {
+		timeout = DefaultLockTimeout
+	}
+
+	file, err := os.OpenFile(fa.LockPath(), os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, pkgerrors.ErrFileOperation("opening lock", err)
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		err := tryLock(file)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, errLocked) {
+			file.Close()
+			return nil, pkgerrors.ErrFileOperation("locking", err)
+		}
+		if time.Now().After(deadline) {
+			pid := readLockHolder(fa.LockPath())
+			file.Close()
+			return nil, pkgerrors.ErrLockHeld(fa.Path, pid, pkgerrors.ErrLockTimeout)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+
+	// Record our PID so that contenders can report who holds the lock.
+	if err := file.Truncate(0); err == nil {
+		_, _ = file.WriteAt([]byte(strconv.Itoa(os.Getpid())), 0)
+	}
+	return &FileLock{file: file}, nil
+}
+
+// Unlock releases the lock. It is safe to call more than once.
+func (l *FileLock) Unlock() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+	err := unlock(l.file)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	l.file = nil
+	return err
+}
+
+// readLockHolder returns the PID recorded in a lock file, or zero if unknown.
+func readLockHolder(path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
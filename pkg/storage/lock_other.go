@@ -0,0 +1,15 @@
+//go:build !unix && !windows
+
+package storage
+
+import "os"
+
+// tryLock is a no-op on platforms without file locking support.
+func tryLock(f *os.File) error {
+	return nil
+}
+
+// unlock is a no-op on platforms without file locking support.
+func unlock(f *os.File) error {
+	return nil
+}
@@ -0,0 +1,131 @@
+package storage
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lockHelperEnv makes the test binary act as a competing process holding a lock.
+const lockHelperEnv = "AZTX_TEST_LOCK_HELPER"
+
+func TestMain(m *testing.M) {
+	if path := os.Getenv(lockHelperEnv); path != "" {
+		fa := &FileAdapter{Path: path}
+		unlock, err := fa.Lock()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Println("locked")
+		// Hold the lock until the parent closes stdin.
+		_, _ = bufio.NewReader(os.Stdin).ReadString('\n')
+		_ = unlock()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+func TestFileAdapter_Lock_SerializesGoroutines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counter")
+	require.NoError(t, os.WriteFile(path, []byte("0"), 0644))
+
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fa := &FileAdapter{Path: path, LockTimeout: 10 * time.Second}
+			unlock, err := fa.Lock()
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer unlock()
+
+			data, err := fa.Read()
+			if err != nil {
+				errs <- err
+				return
+			}
+			n, _ := strconv.Atoi(string(data))
+			time.Sleep(5 * time.Millisecond)
+			errs <- fa.Write([]byte(strconv.Itoa(n + 1)))
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		require.NoError(t, err)
+	}
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, strconv.Itoa(workers), string(data), "no increments should be lost")
+}
+
+func TestFileAdapter_Lock_TimesOut(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "azureProfile.json")
+
+	holder := &FileAdapter{Path: path}
+	unlock, err := holder.Lock()
+	require.NoError(t, err)
+	defer unlock()
+
+	contender := &FileAdapter{Path: path, LockTimeout: 100 * time.Millisecond}
+	_, err = contender.Lock()
+	require.Error(t, err)
+	assert.ErrorIs(t, err, pkgerrors.ErrLockTimeout)
+	assert.Contains(t, err.Error(), fmt.Sprintf("locked by process %d", os.Getpid()))
+
+	require.NoError(t, unlock())
+	unlockAgain, err := contender.Lock()
+	require.NoError(t, err, "lock should be available once released")
+	require.NoError(t, unlockAgain())
+}
+
+func TestFileAdapter_Lock_CompetingProcess(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "azureProfile.json")
+
+	cmd := exec.Command(os.Args[0], "-test.run=^$")
+	cmd.Env = append(os.Environ(), lockHelperEnv+"="+path)
+	stdin, err := cmd.StdinPipe()
+	require.NoError(t, err)
+	stdout, err := cmd.StdoutPipe()
+	require.NoError(t, err)
+	require.NoError(t, cmd.Start())
+	defer func() {
+		_ = stdin.Close()
+		_ = cmd.Wait()
+	}()
+
+	line, err := bufio.NewReader(stdout).ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "locked\n", line)
+
+	fa := &FileAdapter{Path: path, LockTimeout: 100 * time.Millisecond}
+	_, err = fa.Lock()
+	require.Error(t, err)
+	assert.ErrorIs(t, err, pkgerrors.ErrLockTimeout)
+	assert.Contains(t, err.Error(), fmt.Sprintf("locked by process %d", cmd.Process.Pid))
+
+	require.NoError(t, stdin.Close())
+	require.NoError(t, cmd.Wait())
+
+	fa.LockTimeout = time.Second
+	unlock, err := fa.Lock()
+	require.NoError(t, err, "lock should be available once the other process exits")
+	require.NoError(t, unlock())
+}
@@ -0,0 +1,194 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/types"
+)
+
+// SessionMarker is the file that marks a config directory as a session overlay.
+const SessionMarker = "aztx-session.json"
+
+// SessionEnv is the environment variable holding the overlay of the current session.
+const SessionEnv = "AZTX_SESSION_DIR"
+
+// Session is a private Azure CLI config directory that overlays another one for the
+// lifetime of a shell. It holds its own copy of the profile, so that switching the
+// default subscription in it leaves the base directory alone, and links to every other
+// file of the base directory, so that tokens and settings stay shared.
+type Session struct {
+	Dir               string `json:"-"`                           // Overlay directory
+	Base              string `json:"base"`                        // Config directory the overlay is based on
+	PreviousConfigDir string `json:"previousConfigDir,omitempty"` // AZURE_CONFIG_DIR before the session, restored when it ends
+}
+
+// DefaultSessionRoot returns the directory session overlays are created in: under
+// $XDG_RUNTIME_DIR when it is set, so that sessions end with the login, and in a
+// per-user directory of the system temporary directory otherwise.
+func DefaultSessionRoot() string {
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "aztx", "sessions")
+	}
+	name := "aztx-sessions"
+	if uid := os.Getuid(); uid >= 0 {
+		name = fmt.Sprintf("aztx-sessions-%d", uid)
+	}
+	return filepath.Join(os.TempDir(), name)
+}
+
+// NewSession creates an overlay of the config directory base in root and fills it.
+// previous is the value AZURE_CONFIG_DIR had before the session, if any.
+func NewSession(root, base, previous string) (*Session, error) {
+	if err := os.MkdirAll(root, 0700); err != nil {
+		return nil, pkgerrors.ErrFileOperation("creating session directory", err)
+	}
+	dir, err := os.MkdirTemp(root, "session-")
+	if err != nil {
+		return nil, pkgerrors.ErrFileOperation("creating session directory", err)
+	}
+
+	s := &Session{Dir: dir, Base: base, PreviousConfigDir: previous}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, pkgerrors.ErrFileOperation("writing session marker", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, SessionMarker), data); err != nil {
+		return nil, pkgerrors.ErrFileOperation("writing session marker", err)
+	}
+	if err := s.Sync(); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+	return s, nil
+}
+
+// OpenSession returns the session whose overlay is dir. It returns ErrNotASession
+// when dir was not created by NewSession.
+func OpenSession(dir string) (*Session, error) {
+	data, err := os.ReadFile(filepath.Join(dir, SessionMarker))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, pkgerrors.ErrNotASession
+		}
+		return nil, pkgerrors.ErrFileOperation("reading session marker", err)
+	}
+	s := &Session{Dir: dir}
+	if err := json.Unmarshal(data, s); err != nil || s.Base == "" {
+		return nil, pkgerrors.ErrNotASession
+	}
+	return s, nil
+}
+
+// Sync brings the overlay up to date with its base directory. Files added to the base
+// since the last sync are linked, and the profile is copied again when the base
+// profile changed after the overlay's copy was taken, e.g. by "az login". The default
+// subscriptions of the overlay are kept, so that switching in another shell never
+// changes the session.
+func (s *Session) Sync() error {
+	entries, err := os.ReadDir(s.Base)
+	if err != nil {
+		return pkgerrors.ErrFileOperation("reading session base", err)
+	}
+	for _, entry := range entries {
+		if !sharedWithSession(entry.Name()) {
+			continue
+		}
+		link := filepath.Join(s.Dir, entry.Name())
+		if _, err := os.Lstat(link); err == nil {
+			continue
+		}
+		if err := os.Symlink(filepath.Join(s.Base, entry.Name()), link); err != nil {
+			return pkgerrors.ErrFileOperation("linking session file", err)
+		}
+	}
+
+	base := filepath.Join(s.Base, ProfileFileName)
+	info, err := os.Stat(base)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return pkgerrors.ErrFileDoesNotExist
+		}
+		return pkgerrors.ErrFileOperation("reading session base", err)
+	}
+	profile := filepath.Join(s.Dir, ProfileFileName)
+	if copied, err := os.Stat(profile); err == nil && !info.ModTime().After(copied.ModTime()) {
+		return nil
+	}
+	data, err := os.ReadFile(base)
+	if err != nil {
+		return pkgerrors.ErrFileOperation("reading session base", err)
+	}
+	if copied, err := os.ReadFile(profile); err == nil {
+		data = keepDefaults(data, copied)
+	}
+	if err := writeFileAtomic(profile, data); err != nil {
+		return pkgerrors.ErrFileOperation("copying profile into session", err)
+	}
+	return nil
+}
+
+// Remove deletes the overlay. Files of the base directory are left alone, as the
+// overlay only links to them.
+func (s *Session) Remove() error {
+	if err := os.RemoveAll(s.Dir); err != nil {
+		return pkgerrors.ErrFileOperation("removing session", err)
+	}
+	return nil
+}
+
+// keepDefaults returns the base profile with the default subscriptions of the overlay's
+// profile. Defaults are kept per cloud, and only for entries the base still lists.
+// The base is returned as is when either profile cannot be parsed.
+func keepDefaults(base, overlay []byte) []byte {
+	var baseConfig, overlayConfig types.Configuration
+	if json.Unmarshal(trimBOM(base), &baseConfig) != nil || json.Unmarshal(trimBOM(overlay), &overlayConfig) != nil {
+		return base
+	}
+
+	changed := false
+	for _, kept := range overlayConfig.Subscriptions {
+		if !kept.IsDefault {
+			continue
+		}
+		target := -1
+		for i, sub := range baseConfig.Subscriptions {
+			if kept.Identity().Matches(sub) {
+				target = i
+				break
+			}
+		}
+		if target == -1 {
+			continue
+		}
+		for i, sub := range baseConfig.Subscriptions {
+			if strings.EqualFold(sub.EnvironmentName, kept.EnvironmentName) && sub.IsDefault != (i == target) {
+				baseConfig.Subscriptions[i].IsDefault = i == target
+				changed = true
+			}
+		}
+	}
+	if !changed {
+		return base
+	}
+	merged, err := mergeDocument(base, &baseConfig)
+	if err != nil {
+		return base
+	}
+	return merged
+}
+
+// sharedWithSession reports whether a file of the base directory is linked into
+// session overlays. The profile, with its lock and backups, is private to each
+// overlay, and temporary copies of files being replaced are never linked.
+func sharedWithSession(name string) bool {
+	switch name {
+	case ProfileFileName, ProfileFileName + ".lock", backupDirName, SessionMarker:
+		return false
+	}
+	return !strings.HasPrefix(name, ".") || !strings.Contains(name, ".tmp-")
+}
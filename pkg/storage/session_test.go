@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newSessionBase(t *testing.T) string {
+	base := t.TempDir()
+	profile, err := os.ReadFile(filepath.Join("testdata", ProfileFileName))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(base, ProfileFileName), profile, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(base, "msal_token_cache.json"), []byte("{}"), 0600))
+	require.NoError(t, os.Mkdir(filepath.Join(base, backupDirName), 0755))
+	return base
+}
+
+func TestNewSession(t *testing.T) {
+	base := newSessionBase(t)
+	s, err := NewSession(filepath.Join(t.TempDir(), "sessions"), base, "~/.azure")
+	require.NoError(t, err)
+
+	// The profile is a private copy and other files are shared.
+	info, err := os.Lstat(filepath.Join(s.Dir, ProfileFileName))
+	require.NoError(t, err)
+	assert.True(t, info.Mode().IsRegular())
+	target, err := os.Readlink(filepath.Join(s.Dir, "msal_token_cache.json"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(base, "msal_token_cache.json"), target)
+	_, err = os.Lstat(filepath.Join(s.Dir, backupDirName))
+	assert.True(t, os.IsNotExist(err))
+
+	opened, err := OpenSession(s.Dir)
+	require.NoError(t, err)
+	assert.Equal(t, s, opened)
+}
+
+func TestNewSession_MissingProfile(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "sessions")
+	_, err := NewSession(root, t.TempDir(), "")
+	assert.ErrorIs(t, err, pkgerrors.ErrFileDoesNotExist)
+
+	entries, err := os.ReadDir(root)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "a failed session is removed")
+}
+
+func TestOpenSession_NotASession(t *testing.T) {
+	_, err := OpenSession(t.TempDir())
+	assert.ErrorIs(t, err, pkgerrors.ErrNotASession)
+}
+
+func TestSession_Sync(t *testing.T) {
+	base := newSessionBase(t)
+	s, err := NewSession(t.TempDir(), base, "")
+	require.NoError(t, err)
+
+	// Changes made in the overlay survive a sync while the base is unchanged.
+	profile := filepath.Join(s.Dir, ProfileFileName)
+	require.NoError(t, os.WriteFile(profile, []byte(`{"session": true}`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(base, "clouds.config"), nil, 0644))
+	require.NoError(t, s.Sync())
+	data, err := os.ReadFile(profile)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"session": true}`, string(data))
+	_, err = os.Readlink(filepath.Join(s.Dir, "clouds.config"))
+	assert.NoError(t, err)
+
+	// A newer base profile is copied again.
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.WriteFile(filepath.Join(base, ProfileFileName), []byte(`{"base": true}`), 0644))
+	require.NoError(t, os.Chtimes(filepath.Join(base, ProfileFileName), later, later))
+	require.NoError(t, s.Sync())
+	data, err = os.ReadFile(profile)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"base": true}`, string(data))
+}
+
+func TestSession_Sync_KeepsSessionDefault(t *testing.T) {
+	base := newSessionBase(t)
+	s, err := NewSession(t.TempDir(), base, "")
+	require.NoError(t, err)
+	development := uuid.MustParse("8aa89ebb-5735-4d1b-9c5c-a8f32a858e99")
+
+	setDefault := func(fa *FileAdapter, id uuid.UUID) {
+		cfg, err := fa.ReadConfig()
+		require.NoError(t, err)
+		for i, sub := range cfg.Subscriptions {
+			cfg.Subscriptions[i].IsDefault = sub.ID == id
+		}
+		require.NoError(t, fa.WriteConfig(cfg))
+	}
+	defaultOf := func(fa *FileAdapter) string {
+		cfg, err := fa.ReadConfig()
+		require.NoError(t, err)
+		for _, sub := range cfg.Subscriptions {
+			if sub.IsDefault {
+				return sub.Name
+			}
+		}
+		return ""
+	}
+
+	// The session switches to Development, then another shell switches the base
+	// away from Production and back, e.g. with "az account set".
+	overlay := &FileAdapter{Path: filepath.Join(s.Dir, ProfileFileName)}
+	setDefault(overlay, development)
+	shared := &FileAdapter{Path: filepath.Join(base, ProfileFileName)}
+	setDefault(shared, development)
+	setDefault(shared, uuid.MustParse("9e7969ef-4cb8-4a2d-959f-bfdaae452a3d"))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(shared.Path, later, later))
+
+	require.NoError(t, s.Sync())
+	assert.Equal(t, "Development Environment", defaultOf(overlay), "the session keeps its default")
+	assert.Equal(t, "Production Workloads", defaultOf(shared))
+	data, err := os.ReadFile(overlay.Path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"assertion": "preserved"`, "the rest of the base profile is taken as is")
+}
+
+func TestSession_Remove(t *testing.T) {
+	base := newSessionBase(t)
+	s, err := NewSession(t.TempDir(), base, "")
+	require.NoError(t, err)
+
+	require.NoError(t, s.Remove())
+	_, err = os.Stat(s.Dir)
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(base, "msal_token_cache.json"))
+	assert.NoError(t, err, "shared files are left in the base directory")
+}
@@ -0,0 +1,45 @@
+package subscription
+
+import (
+	"github.com/google/uuid"
+	"github.com/riweston/aztx/pkg/types"
+)
+
+// Ways a subscription can be reached from another tenant, as reported by Access.
+const (
+	AccessLighthouse = "lighthouse"
+	AccessGuest      = "guest"
+)
+
+// Delegated reports whether other tenants manage sub through Azure Lighthouse.
+func Delegated(sub types.Subscription) bool {
+	return len(sub.ManagedByTenants) > 0
+}
+
+// Guest reports whether sub is reached as a B2B guest: it lives in another tenant than
+// the one signed in to, which does not manage it through Lighthouse.
+func Guest(sub types.Subscription) bool {
+	if sub.HomeTenantID == uuid.Nil || sub.HomeTenantID == sub.TenantID {
+		return false
+	}
+	for _, m := range sub.ManagedByTenants {
+		if m.TenantID == sub.TenantID {
+			return false
+		}
+	}
+	return true
+}
+
+// Access returns how sub is reached from other tenants: AccessLighthouse when it is
+// delegated and AccessGuest when it is reached as a guest. It is empty for
+// subscriptions used from their own tenant.
+func Access(sub types.Subscription) []string {
+	var access []string
+	if Delegated(sub) {
+		access = append(access, AccessLighthouse)
+	}
+	if Guest(sub) {
+		access = append(access, AccessGuest)
+	}
+	return access
+}
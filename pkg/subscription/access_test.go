@@ -0,0 +1,43 @@
+package subscription
+
+import (
+	"testing"
+
+	"github.com/riweston/aztx/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccess(t *testing.T) {
+	subs := newListManager().Configuration.Subscriptions
+	lighthouse := subs[2]
+	guest := subs[1]
+	guest.HomeTenantID = fabrikamTenant
+	// Reached from the managing tenant: delegated, not a guest.
+	managed := lighthouse
+	managed.TenantID, managed.HomeTenantID = contosoTenant, fabrikamTenant
+	home := subs[0]
+	home.HomeTenantID = contosoTenant
+
+	tests := []struct {
+		name string
+		sub  types.Subscription
+		want []string
+	}{
+		{name: "own tenant", sub: home},
+		{name: "no home tenant recorded", sub: subs[0]},
+		{name: "delegated", sub: lighthouse, want: []string{AccessLighthouse}},
+		{name: "guest", sub: guest, want: []string{AccessGuest}},
+		{name: "managing tenant is no guest", sub: managed, want: []string{AccessLighthouse}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Access(tt.sub))
+		})
+	}
+}
+
+func TestLabel_Access(t *testing.T) {
+	sub := newListManager().Configuration.Subscriptions[2]
+	sub.HomeTenantID = contosoTenant
+	assert.Equal(t, "Fabrikam Production (9bb28eee-ebaa-442a-83ba-5511810fb151) · lighthouse · guest", Label(sub))
+}
@@ -0,0 +1,40 @@
+package subscription
+
+import (
+	"fmt"
+
+	"github.com/riweston/aztx/pkg/types"
+)
+
+// Completions returns the values a subscription query can be completed with: the
+// aliases, then the names and finally the IDs of the subscriptions, described by
+// the subscription they stand for or by its tenant and state.
+func (sm *Manager) Completions() []types.Completion {
+	subs := sm.Configuration.Subscriptions
+	var aliases, names, ids []types.Completion
+	// A subscription is listed once per signed-in account, but completed only once.
+	seenNames := make(map[string]bool)
+	seenIDs := make(map[string]bool)
+	for _, sub := range subs {
+		if seenIDs[sub.ID.String()] {
+			continue
+		}
+		seenIDs[sub.ID.String()] = true
+		if sub.Alias != "" {
+			aliases = append(aliases, types.Completion{Value: sub.Alias, Description: sub.Name})
+		}
+		if !seenNames[sub.Name] {
+			seenNames[sub.Name] = true
+			names = append(names, types.Completion{
+				Value:       sub.Name,
+				Description: fmt.Sprintf("%s, %s", sm.TenantName(sub), sub.State),
+			})
+		}
+		ids = append(ids, types.Completion{Value: sub.ID.String(), Description: sub.Name})
+	}
+
+	completions := make([]types.Completion, 0, len(aliases)+len(names)+len(ids))
+	completions = append(completions, aliases...)
+	completions = append(completions, names...)
+	return append(completions, ids...)
+}
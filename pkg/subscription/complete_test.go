@@ -0,0 +1,42 @@
+package subscription
+
+import (
+	"testing"
+
+	"github.com/riweston/aztx/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_Completions(t *testing.T) {
+	sm := newListManager()
+	sm.Configuration.Subscriptions[0].Alias = "prod"
+	duplicate := sm.Configuration.Subscriptions[1]
+	duplicate.ID = contosoTenant
+	sm.Configuration.Subscriptions = append(sm.Configuration.Subscriptions, duplicate)
+
+	assert.Equal(t, []types.Completion{
+		{Value: "prod", Description: "Production Workloads"},
+		{Value: "Production Workloads", Description: "Contoso, Enabled"},
+		{Value: "Development Environment", Description: "Contoso, Disabled"},
+		{Value: "Fabrikam Production", Description: "bob@fabrikam.com, Enabled"},
+		{Value: "9e7969ef-4cb8-4a2d-959f-bfdaae452a3d", Description: "Production Workloads"},
+		{Value: "8aa89ebb-5735-4d1b-9c5c-a8f32a858e99", Description: "Development Environment"},
+		{Value: "9bb28eee-ebaa-442a-83ba-5511810fb151", Description: "Fabrikam Production"},
+		{Value: "11111111-1111-1111-1111-111111111111", Description: "Development Environment"},
+	}, sm.Completions())
+}
+
+func TestManager_Completions_SeveralAccounts(t *testing.T) {
+	sm := newListManager()
+	sm.Configuration.Subscriptions = sm.Configuration.Subscriptions[:1]
+	sm.Configuration.Subscriptions[0].Alias = "prod"
+	sp := sm.Configuration.Subscriptions[0]
+	sp.User.Name, sp.User.Type = "deploy-sp", "servicePrincipal"
+	sm.Configuration.Subscriptions = append(sm.Configuration.Subscriptions, sp)
+
+	assert.Equal(t, []types.Completion{
+		{Value: "prod", Description: "Production Workloads"},
+		{Value: "Production Workloads", Description: "Contoso, Enabled"},
+		{Value: "9e7969ef-4cb8-4a2d-959f-bfdaae452a3d", Description: "Production Workloads"},
+	}, sm.Completions())
+}
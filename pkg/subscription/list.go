@@ -0,0 +1,186 @@
+package subscription
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/types"
+)
+
+// Filter narrows the subscriptions returned by List. Empty fields match everything.
+type Filter struct {
+	Tenant string // Tenant ID, or part of the tenant's custom or account name
+	State  string // Subscription state, e.g. Enabled or Disabled
+	User   string // Part of the signed-in user name
+	Cloud  string // Azure environment name, e.g. AzureCloud
+
+	Delegated bool // Only subscriptions managed by other tenants through Lighthouse
+	Guest     bool // Only subscriptions reached as a B2B guest
+}
+
+// SortKeys lists the fields List can order subscriptions by.
+var SortKeys = []string{"name", "id", "state", "tenant", "user", "cloud", "default", "managedby"}
+
+// List returns the subscriptions matching f, ordered by the given sort keys. Later
+// keys break ties left by earlier ones, and subscriptions that still compare equal
+// are ordered by name and then ID.
+func (sm *Manager) List(f Filter, keys ...string) ([]types.Subscription, error) {
+	for _, key := range keys {
+		if !validSortKey(key) {
+			return nil, pkgerrors.ErrInvalidSortKey(key, SortKeys)
+		}
+	}
+
+	subs := make([]types.Subscription, 0, len(sm.Configuration.Subscriptions))
+	for _, sub := range sm.Configuration.Subscriptions {
+		if sm.Matches(sub, f) {
+			subs = append(subs, sub)
+		}
+	}
+
+	keys = append(keys, "name", "id")
+	sort.SliceStable(subs, func(i, j int) bool {
+		for _, key := range keys {
+			if c := sm.compare(subs[i], subs[j], key); c != 0 {
+				return c < 0
+			}
+		}
+		return false
+	})
+	return subs, nil
+}
+
+// Narrow returns a manager over the subscriptions matching f, in their original order
+// and with the tenants of sm. The configuration of the result is only meant for
+// choosing a subscription and must not be written back. An empty filter returns sm.
+func (sm *Manager) Narrow(f Filter) (*Manager, error) {
+	if f == (Filter{}) {
+		return sm, nil
+	}
+	narrowed := *sm.Configuration
+	narrowed.Subscriptions = nil
+	for _, sub := range sm.Configuration.Subscriptions {
+		if sm.Matches(sub, f) {
+			narrowed.Subscriptions = append(narrowed.Subscriptions, sub)
+		}
+	}
+	if len(narrowed.Subscriptions) == 0 {
+		return nil, pkgerrors.ErrNoneMatchFilter
+	}
+	return &Manager{BaseManager: types.BaseManager{Configuration: &narrowed}}, nil
+}
+
+// TenantName returns the name shown for the tenant of a subscription: the tenant's
+// custom name when it has one, the signed-in account otherwise.
+func (sm *Manager) TenantName(sub types.Subscription) string {
+	if name := sm.customTenantName(sub.TenantID); name != "" {
+		return name
+	}
+	return sub.User.Name
+}
+
+// ManagedBy returns the tenants managing a subscription, by custom name when the
+// tenant has one and by ID otherwise.
+func (sm *Manager) ManagedBy(sub types.Subscription) []string {
+	managers := make([]string, 0, len(sub.ManagedByTenants))
+	for _, m := range sub.ManagedByTenants {
+		if name := sm.customTenantName(m.TenantID); name != "" {
+			managers = append(managers, name)
+		} else {
+			managers = append(managers, m.TenantID.String())
+		}
+	}
+	return managers
+}
+
+func (sm *Manager) customTenantName(id uuid.UUID) string {
+	for _, t := range sm.Configuration.Tenants {
+		if t.ID == id {
+			return t.CustomName
+		}
+	}
+	return ""
+}
+
+// Matches reports whether sub passes f.
+func (sm *Manager) Matches(sub types.Subscription, f Filter) bool {
+	if f.Tenant != "" && !strings.EqualFold(sub.TenantID.String(), f.Tenant) &&
+		!containsFold(sm.TenantName(sub), f.Tenant) && !containsFold(sub.User.Name, f.Tenant) {
+		return false
+	}
+	if f.State != "" && !strings.EqualFold(sub.State, f.State) {
+		return false
+	}
+	if f.User != "" && !containsFold(sub.User.Name, f.User) {
+		return false
+	}
+	if f.Cloud != "" && !strings.EqualFold(sub.EnvironmentName, f.Cloud) {
+		return false
+	}
+	if f.Delegated && !Delegated(sub) {
+		return false
+	}
+	if f.Guest && !Guest(sub) {
+		return false
+	}
+	return true
+}
+
+// compare orders two subscriptions by key, returning a negative number when a comes
+// first. The default subscription comes first when ordering by "default".
+func (sm *Manager) compare(a, b types.Subscription, key string) int {
+	switch key {
+	case "id":
+		return strings.Compare(a.ID.String(), b.ID.String())
+	case "state":
+		return compareFold(a.State, b.State)
+	case "tenant":
+		return compareFold(sm.TenantName(a), sm.TenantName(b))
+	case "user":
+		return compareFold(a.User.Name, b.User.Name)
+	case "cloud":
+		return compareFold(a.EnvironmentName, b.EnvironmentName)
+	case "managedby":
+		// Subscriptions managed by no other tenant come last.
+		ma, mb := strings.Join(sm.ManagedBy(a), ","), strings.Join(sm.ManagedBy(b), ",")
+		switch {
+		case ma == mb:
+			return 0
+		case ma == "":
+			return 1
+		case mb == "":
+			return -1
+		}
+		return compareFold(ma, mb)
+	case "default":
+		switch {
+		case a.IsDefault == b.IsDefault:
+			return 0
+		case a.IsDefault:
+			return -1
+		default:
+			return 1
+		}
+	default:
+		return compareFold(a.Name, b.Name)
+	}
+}
+
+func validSortKey(key string) bool {
+	for _, k := range SortKeys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(s, substr string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+}
+
+func compareFold(a, b string) int {
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
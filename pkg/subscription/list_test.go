@@ -0,0 +1,165 @@
+package subscription
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	contosoTenant  = uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	fabrikamTenant = uuid.MustParse("22222222-2222-2222-2222-222222222222")
+)
+
+func newListSubscription(id, name, state, user, cloud string, tenantID uuid.UUID) types.Subscription {
+	sub := types.Subscription{
+		ID:              uuid.MustParse(id),
+		Name:            name,
+		State:           state,
+		TenantID:        tenantID,
+		EnvironmentName: cloud,
+	}
+	sub.User.Name = user
+	return sub
+}
+
+func newListManager() *Manager {
+	prod := newListSubscription("9e7969ef-4cb8-4a2d-959f-bfdaae452a3d", "Production Workloads", "Enabled", "alice@contoso.com", "AzureCloud", contosoTenant)
+	prod.IsDefault = true
+	dev := newListSubscription("8aa89ebb-5735-4d1b-9c5c-a8f32a858e99", "Development Environment", "Disabled", "alice@contoso.com", "AzureCloud", contosoTenant)
+	fab := newListSubscription("9bb28eee-ebaa-442a-83ba-5511810fb151", "Fabrikam Production", "Enabled", "bob@fabrikam.com", "AzureUSGovernment", fabrikamTenant)
+	fab.ManagedByTenants = append(fab.ManagedByTenants, struct {
+		TenantID uuid.UUID `json:"tenantId"`
+	}{TenantID: contosoTenant}, struct {
+		TenantID uuid.UUID `json:"tenantId"`
+	}{TenantID: uuid.MustParse("33333333-3333-3333-3333-333333333333")})
+
+	return &Manager{BaseManager: types.BaseManager{Configuration: &types.Configuration{
+		Tenants:       []types.Tenant{{ID: contosoTenant, CustomName: "Contoso"}},
+		Subscriptions: []types.Subscription{prod, dev, fab},
+	}}}
+}
+
+func names(subs []types.Subscription) []string {
+	result := make([]string, 0, len(subs))
+	for _, sub := range subs {
+		result = append(result, sub.Name)
+	}
+	return result
+}
+
+func TestManager_List(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  Filter
+		keys    []string
+		want    []string
+		wantErr error
+	}{
+		{
+			name: "everything sorted by name",
+			want: []string{"Development Environment", "Fabrikam Production", "Production Workloads"},
+		},
+		{
+			name:   "tenant by custom name",
+			filter: Filter{Tenant: "contoso"},
+			want:   []string{"Development Environment", "Production Workloads"},
+		},
+		{
+			name:   "tenant by ID",
+			filter: Filter{Tenant: fabrikamTenant.String()},
+			want:   []string{"Fabrikam Production"},
+		},
+		{
+			name:   "tenant by account",
+			filter: Filter{Tenant: "bob@"},
+			want:   []string{"Fabrikam Production"},
+		},
+		{
+			name:   "state ignores case",
+			filter: Filter{State: "disabled"},
+			want:   []string{"Development Environment"},
+		},
+		{
+			name:   "user and cloud",
+			filter: Filter{User: "contoso", Cloud: "azurecloud", State: "Enabled"},
+			want:   []string{"Production Workloads"},
+		},
+		{
+			name:   "no match",
+			filter: Filter{Cloud: "AzureChinaCloud"},
+			want:   []string{},
+		},
+		{
+			name:   "delegated",
+			filter: Filter{Delegated: true},
+			want:   []string{"Fabrikam Production"},
+		},
+		{
+			name:   "guest",
+			filter: Filter{Guest: true},
+			want:   []string{},
+		},
+		{
+			name: "managing tenant first",
+			keys: []string{"managedby", "name"},
+			want: []string{"Fabrikam Production", "Development Environment", "Production Workloads"},
+		},
+		{
+			name: "default first",
+			keys: []string{"default"},
+			want: []string{"Production Workloads", "Development Environment", "Fabrikam Production"},
+		},
+		{
+			name: "several keys",
+			keys: []string{"state", "tenant"},
+			want: []string{"Development Environment", "Fabrikam Production", "Production Workloads"},
+		},
+		{
+			name:    "unknown key",
+			keys:    []string{"size"},
+			wantErr: pkgerrors.ErrUnknownSortKey,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			subs, err := newListManager().List(tt.filter, tt.keys...)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, names(subs))
+		})
+	}
+}
+
+func TestManager_TenantName(t *testing.T) {
+	sm := newListManager()
+	subs := sm.Configuration.Subscriptions
+	assert.Equal(t, "Contoso", sm.TenantName(subs[0]))
+	assert.Equal(t, "bob@fabrikam.com", sm.TenantName(subs[2]))
+}
+
+func TestManager_ManagedBy(t *testing.T) {
+	sm := newListManager()
+	subs := sm.Configuration.Subscriptions
+	assert.Empty(t, sm.ManagedBy(subs[0]))
+	assert.Equal(t, []string{"Contoso", "33333333-3333-3333-3333-333333333333"}, sm.ManagedBy(subs[2]))
+}
+
+func TestManager_DefaultSubscription(t *testing.T) {
+	sm := newListManager()
+	sub, err := sm.DefaultSubscription()
+	require.NoError(t, err)
+	assert.Equal(t, "Production Workloads", sub.Name)
+
+	sm.Configuration.Subscriptions[0].IsDefault = false
+	_, err = sm.DefaultSubscription()
+	assert.ErrorIs(t, err, pkgerrors.ErrNoDefaultSubscription)
+}
@@ -0,0 +1,60 @@
+package subscription
+
+import (
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/riweston/aztx/pkg/finder"
+	"github.com/riweston/aztx/pkg/types"
+)
+
+// previewTime is the layout of times shown in preview panes.
+const previewTime = "2006-01-02 15:04"
+
+// Preview renders the details of sub shown next to the finder: every field of the
+// profile entry, the tenant's custom name and what aztx keeps about the subscription.
+func (sm *Manager) Preview(sub types.Subscription) string {
+	lastUsed := ""
+	switch {
+	case sm.IsCurrent(sub):
+		lastUsed = "in use"
+	case !sub.LastUsed.IsZero():
+		lastUsed = sub.LastUsed.Local().Format(previewTime)
+	}
+
+	return finder.Details(sub.Name,
+		finder.Field{Name: "ID", Value: sub.ID.String()},
+		finder.Field{Name: "Alias", Value: sub.Alias},
+		finder.Field{Name: "State", Value: sub.State},
+		finder.Field{Name: "Tenant", Value: sm.tenantLabel(sub.TenantID)},
+		finder.Field{Name: "Home tenant", Value: sm.tenantLabel(sub.HomeTenantID)},
+		finder.Field{Name: "User", Value: UserLabel(sub)},
+		finder.Field{Name: "Cloud", Value: sub.EnvironmentName},
+		finder.Field{Name: "Managed by", Value: strings.Join(sm.ManagedBy(sub), ", ")},
+		finder.Field{Name: "Access", Value: strings.Join(Access(sub), ", ")},
+		finder.Field{Name: "Default", Value: yesNo(sub.IsDefault)},
+		finder.Field{Name: "Favorite", Value: yesNo(sub.Favorite)},
+		finder.Field{Name: "Protected", Value: yesNo(sub.Protected)},
+		finder.Field{Name: "Tags", Value: strings.Join(sub.Tags, ", ")},
+		finder.Field{Name: "Last used", Value: lastUsed},
+	)
+}
+
+// tenantLabel returns the ID of a tenant, preceded by its custom name when it has one.
+// The nil ID of a tenant missing from the profile renders empty.
+func (sm *Manager) tenantLabel(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return ""
+	}
+	if name := sm.customTenantName(id); name != "" {
+		return name + " (" + id.String() + ")"
+	}
+	return id.String()
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
@@ -0,0 +1,42 @@
+package subscription
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManager_Preview(t *testing.T) {
+	sm := newListManager()
+	subs := sm.Configuration.Subscriptions
+
+	fab := subs[2]
+	fab.User.Type = "servicePrincipal"
+	fab.HomeTenantID = contosoTenant
+	fab.Tags = []string{"critical", "prod"}
+	fab.Protected = true
+	fab.LastUsed = time.Date(2024, 10, 18, 9, 30, 0, 0, time.UTC)
+	want := "Fabrikam Production\n\n" +
+		"ID           9bb28eee-ebaa-442a-83ba-5511810fb151\n" +
+		"Alias        -\n" +
+		"State        Enabled\n" +
+		"Tenant       22222222-2222-2222-2222-222222222222\n" +
+		"Home tenant  Contoso (11111111-1111-1111-1111-111111111111)\n" +
+		"User         bob@fabrikam.com (servicePrincipal)\n" +
+		"Cloud        AzureUSGovernment\n" +
+		"Managed by   Contoso, 33333333-3333-3333-3333-333333333333\n" +
+		"Access       lighthouse, guest\n" +
+		"Default      no\n" +
+		"Favorite     no\n" +
+		"Protected    yes\n" +
+		"Tags         critical, prod\n" +
+		"Last used    " + fab.LastUsed.Local().Format("2006-01-02 15:04") + "\n"
+	assert.Equal(t, want, sm.Preview(fab))
+
+	prod := sm.Preview(subs[0])
+	assert.Contains(t, prod, "Tenant       Contoso (11111111-1111-1111-1111-111111111111)\n")
+	assert.Contains(t, prod, "Home tenant  -\n")
+	assert.Contains(t, prod, "Access       -\n")
+	assert.Contains(t, prod, "Last used    in use\n")
+}
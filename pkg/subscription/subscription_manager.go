@@ -2,10 +2,14 @@ package subscription
 
 import (
 	"fmt"
+	"slices"
+	"sort"
+	"strings"
 
 	"github.com/google/uuid"
 	pkgerrors "github.com/riweston/aztx/pkg/errors"
 	"github.com/riweston/aztx/pkg/finder"
+	"github.com/riweston/aztx/pkg/metadata"
 	"github.com/riweston/aztx/pkg/types"
 )
 
@@ -13,13 +17,25 @@ type Manager struct {
 	types.BaseManager
 }
 
-// SetDefaultSubscription marks a subscription as default by its UUID.
-func (sm *Manager) SetDefaultSubscription(subscriptionID uuid.UUID) error {
+// SetDefaultSubscription marks the profile entry id refers to as default, the first
+// one when id leaves the account open. The Azure CLI keeps a default per cloud, so
+// only the default of the subscription's cloud is cleared, and other accounts' entries
+// of the same subscription are not marked.
+func (sm *Manager) SetDefaultSubscription(id types.Identity) error {
+	target := -1
 	for i, sub := range sm.Configuration.Subscriptions {
-		if sub.ID == subscriptionID {
-			sm.Configuration.Subscriptions[i].IsDefault = true
-		} else {
-			sm.Configuration.Subscriptions[i].IsDefault = false
+		if id.Matches(sub) {
+			target = i
+			break
+		}
+	}
+	if target == -1 {
+		return pkgerrors.ErrSubscriptionNotFound
+	}
+	cloud := sm.Configuration.Subscriptions[target].EnvironmentName
+	for i, sub := range sm.Configuration.Subscriptions {
+		if SameCloud(sub.EnvironmentName, cloud) {
+			sm.Configuration.Subscriptions[i].IsDefault = i == target
 		}
 	}
 	return nil
@@ -41,16 +57,14 @@ func (sm *Manager) FindSubscriptionIndex() (int, error) {
 		return -1, pkgerrors.ErrSubscriptionNotFound
 	}
 
-	sub, err := finder.Fuzzy(sm.Configuration.Subscriptions, func(s types.Subscription) string {
-		return fmt.Sprintf("%s (%s)", s.Name, s.ID)
-	})
+	sub, err := sm.SelectSubscription(sm.Configuration.Subscriptions)
 	if err != nil {
 		return -1, err
 	}
 
 	// Find the index of the selected subscription
 	for i, s := range sm.Configuration.Subscriptions {
-		if s.ID == sub.ID {
+		if s.Identity() == sub.Identity() {
 			return i, nil
 		}
 	}
@@ -63,6 +77,40 @@ func (sm *Manager) FindSubscriptionByID(id uuid.UUID) (*types.Subscription, erro
 	return finder.ByID(sm.Configuration.Subscriptions, id)
 }
 
+// DefaultSubscription returns the subscription marked as default in the cloud the
+// Azure CLI targets. When that cloud has no default, or the active cloud is unknown,
+// the first default of any cloud is returned.
+func (sm *Manager) DefaultSubscription() (*types.Subscription, error) {
+	var fallback *types.Subscription
+	for i, sub := range sm.Configuration.Subscriptions {
+		if !sub.IsDefault {
+			continue
+		}
+		if SameCloud(sub.EnvironmentName, sm.Configuration.ActiveCloud) {
+			return &sm.Configuration.Subscriptions[i], nil
+		}
+		if fallback == nil {
+			fallback = &sm.Configuration.Subscriptions[i]
+		}
+	}
+	if fallback == nil {
+		return nil, pkgerrors.ErrNoDefaultSubscription
+	}
+	return fallback, nil
+}
+
+// IsCurrent reports whether sub is the subscription the Azure CLI uses, that is the
+// default of the active cloud.
+func (sm *Manager) IsCurrent(sub types.Subscription) bool {
+	current, err := sm.DefaultSubscription()
+	return err == nil && current.Identity() == sub.Identity()
+}
+
+// SameCloud reports whether two environment names refer to the same cloud.
+func SameCloud(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
+
 // FindSubscriptionsByTenant returns subscriptions filtered by tenant ID
 func (sm *Manager) FindSubscriptionsByTenant(tenantID uuid.UUID) ([]types.Subscription, error) {
 	var tenantSubs []types.Subscription
@@ -84,7 +132,136 @@ func (sm *Manager) FindSubscriptionIndexByTenant(tenantID uuid.UUID) (*types.Sub
 		return nil, err
 	}
 
-	return finder.Fuzzy(subs, func(s types.Subscription) string {
-		return fmt.Sprintf("%s (%s)", s.Name, s.ID)
+	return sm.SelectSubscription(subs)
+}
+
+// SelectSubscription uses fuzzy finding to select one of the given subscriptions.
+// Favorites are listed first, and the details of the subscription under the cursor
+// are shown in a preview pane.
+func (sm *Manager) SelectSubscription(subs []types.Subscription) (*types.Subscription, error) {
+	return finder.FuzzyPreview(SortFavoritesFirst(subs), Labeler(subs), sm.Preview)
+}
+
+// ApplyMetadata annotates the subscriptions with the aliases, favorites and tags
+// kept by aztx.
+func (sm *Manager) ApplyMetadata(m *metadata.Metadata) {
+	for i, sub := range sm.Configuration.Subscriptions {
+		kept := m.Subscriptions[sub.ID.String()]
+		sm.Configuration.Subscriptions[i].Alias = kept.Alias
+		sm.Configuration.Subscriptions[i].Favorite = kept.Favorite
+		sm.Configuration.Subscriptions[i].Tags = kept.Tags
+	}
+}
+
+// SortFavoritesFirst returns a copy of subs with favorites moved to the front,
+// keeping the original order otherwise.
+func SortFavoritesFirst(subs []types.Subscription) []types.Subscription {
+	sorted := make([]types.Subscription, len(subs))
+	copy(sorted, subs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Favorite && !sorted[j].Favorite
 	})
+	return sorted
+}
+
+// MatchSubscriptions resolves a query against the subscription IDs, names and aliases.
+// A single case-insensitive exact match on ID, name or alias is returned as exact.
+// Several exact matches, or failing that every subscription whose ID, name or alias
+// contains the query, are returned as candidates.
+func (sm *Manager) MatchSubscriptions(query string) (exact *types.Subscription, candidates []types.Subscription) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, nil
+	}
+
+	var exacts, partials []types.Subscription
+	lower := strings.ToLower(query)
+	for _, sub := range sm.Configuration.Subscriptions {
+		id := sub.ID.String()
+		name := strings.ToLower(sub.Name)
+		alias := strings.ToLower(sub.Alias)
+		switch {
+		case strings.EqualFold(id, query) || name == lower || (alias != "" && alias == lower):
+			exacts = append(exacts, sub)
+		case strings.Contains(strings.ToLower(id), lower) || strings.Contains(name, lower) ||
+			(alias != "" && strings.Contains(alias, lower)):
+			partials = append(partials, sub)
+		}
+	}
+
+	if len(exacts) == 1 {
+		return &exacts[0], nil
+	}
+	if len(exacts) > 1 {
+		return nil, exacts
+	}
+	return nil, partials
+}
+
+// Label returns the text used to show a subscription in the finder and messages.
+// Aliases are shown in brackets, favorites are marked with a star and protected
+// subscriptions with a warning sign. Subscriptions reached through Lighthouse or as a
+// guest end with how they are reached.
+func Label(s types.Subscription) string {
+	label := fmt.Sprintf("%s (%s)", s.Name, s.ID)
+	if s.Alias != "" {
+		label = fmt.Sprintf("%s [%s] (%s)", s.Name, s.Alias, s.ID)
+	}
+	for _, access := range Access(s) {
+		label += " · " + access
+	}
+	if s.Protected {
+		label = "⚠ " + label
+	}
+	if s.Favorite {
+		label = "★ " + label
+	}
+	return label
+}
+
+// Labeler returns the function labelling subs in the finder and in messages listing
+// them. It is Label, with the account and its type appended when a subscription of
+// subs is listed for several accounts, and the cloud when subs span several clouds.
+func Labeler(subs []types.Subscription) func(types.Subscription) string {
+	seen := make(map[uuid.UUID]bool, len(subs))
+	showUser := false
+	for _, sub := range subs {
+		showUser = showUser || seen[sub.ID]
+		seen[sub.ID] = true
+	}
+	showCloud := len(Clouds(subs)) > 1
+	if !showUser && !showCloud {
+		return Label
+	}
+	return func(s types.Subscription) string {
+		label := Label(s)
+		if showUser {
+			label += " · " + UserLabel(s)
+		}
+		if showCloud {
+			label += " · " + s.EnvironmentName
+		}
+		return label
+	}
+}
+
+// UserLabel returns the account a subscription is reached with, followed by its type.
+func UserLabel(s types.Subscription) string {
+	if s.User.Type == "" {
+		return s.User.Name
+	}
+	return fmt.Sprintf("%s (%s)", s.User.Name, s.User.Type)
+}
+
+// Clouds returns the distinct clouds of subs in the order they first appear.
+func Clouds(subs []types.Subscription) []string {
+	var clouds []string
+	for _, sub := range subs {
+		if sub.EnvironmentName != "" && !slices.ContainsFunc(clouds, func(c string) bool {
+			return SameCloud(c, sub.EnvironmentName)
+		}) {
+			clouds = append(clouds, sub.EnvironmentName)
+		}
+	}
+	return clouds
 }
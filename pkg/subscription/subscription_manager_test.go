@@ -0,0 +1,294 @@
+package subscription
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/metadata"
+	"github.com/riweston/aztx/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestManager() *Manager {
+	return &Manager{BaseManager: types.BaseManager{Configuration: &types.Configuration{
+		Subscriptions: []types.Subscription{
+			{ID: uuid.MustParse("9e7969ef-4cb8-4a2d-959f-bfdaae452a3d"), Name: "Production Workloads"},
+			{ID: uuid.MustParse("8aa89ebb-5735-4d1b-9c5c-a8f32a858e99"), Name: "Development Environment"},
+			{ID: uuid.MustParse("9bb28eee-ebaa-442a-83ba-5511810fb151"), Name: "Fabrikam Production"},
+			{ID: uuid.MustParse("7cc65eaa-f64e-442a-8b8a-3211810ac151"), Name: "Shared"},
+			{ID: uuid.MustParse("8fff24dd-2842-4dbb-8a66-1410c7bc231f"), Name: "Shared"},
+		},
+	}}}
+}
+
+func TestManager_MatchSubscriptions(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		wantExact      string
+		wantCandidates []string
+	}{
+		{
+			name:      "exact ID match",
+			query:     "8aa89ebb-5735-4d1b-9c5c-a8f32a858e99",
+			wantExact: "Development Environment",
+		},
+		{
+			name:      "exact ID match ignores case",
+			query:     "8AA89EBB-5735-4D1B-9C5C-A8F32A858E99",
+			wantExact: "Development Environment",
+		},
+		{
+			name:      "exact name match ignores case",
+			query:     "production workloads",
+			wantExact: "Production Workloads",
+		},
+		{
+			name:           "partial match returns candidates",
+			query:          "production",
+			wantCandidates: []string{"Production Workloads", "Fabrikam Production"},
+		},
+		{
+			name:           "partial match on a single subscription",
+			query:          "develop",
+			wantCandidates: []string{"Development Environment"},
+		},
+		{
+			name:           "duplicate exact names return candidates",
+			query:          "Shared",
+			wantCandidates: []string{"Shared", "Shared"},
+		},
+		{
+			name:  "no match",
+			query: "missing",
+		},
+		{
+			name:  "empty query",
+			query: "  ",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exact, candidates := newTestManager().MatchSubscriptions(tt.query)
+			if tt.wantExact != "" {
+				if assert.NotNil(t, exact) {
+					assert.Equal(t, tt.wantExact, exact.Name)
+				}
+			} else {
+				assert.Nil(t, exact)
+			}
+
+			var names []string
+			for _, c := range candidates {
+				names = append(names, c.Name)
+			}
+			assert.Equal(t, tt.wantCandidates, names)
+		})
+	}
+}
+
+func TestLabel(t *testing.T) {
+	sub := types.Subscription{ID: uuid.MustParse("8aa89ebb-5735-4d1b-9c5c-a8f32a858e99"), Name: "Development"}
+	assert.Equal(t, "Development (8aa89ebb-5735-4d1b-9c5c-a8f32a858e99)", Label(sub))
+}
+
+func TestManager_ApplyMetadata(t *testing.T) {
+	m := newTestManager()
+	kept := &metadata.Metadata{}
+	kept.SetAlias("prod", "9E7969EF-4CB8-4A2D-959F-BFDAAE452A3D")
+	kept.SetFavorite("7cc65eaa-f64e-442a-8b8a-3211810ac151", true)
+	kept.AddTags("7cc65eaa-f64e-442a-8b8a-3211810ac151", "Shared", "dev")
+	m.ApplyMetadata(kept)
+
+	subs := m.Configuration.Subscriptions
+	assert.Equal(t, "prod", subs[0].Alias)
+	assert.False(t, subs[0].Favorite)
+	assert.True(t, subs[3].Favorite)
+	assert.Equal(t, []string{"dev", "shared"}, subs[3].Tags)
+	assert.Empty(t, subs[3].Alias)
+
+	exact, candidates := m.MatchSubscriptions("PROD")
+	if assert.NotNil(t, exact) {
+		assert.Equal(t, "Production Workloads", exact.Name)
+	}
+	assert.Empty(t, candidates)
+
+	sorted := SortFavoritesFirst(subs)
+	assert.Equal(t, subs[3].ID, sorted[0].ID)
+	assert.Equal(t, subs[0].ID, sorted[1].ID)
+	assert.Equal(t, "Production Workloads", subs[0].Name, "input must not be reordered")
+}
+
+func TestLabel_Annotated(t *testing.T) {
+	sub := types.Subscription{
+		ID:       uuid.MustParse("8aa89ebb-5735-4d1b-9c5c-a8f32a858e99"),
+		Name:     "Development",
+		Alias:    "dev",
+		Favorite: true,
+	}
+	assert.Equal(t, "★ Development [dev] (8aa89ebb-5735-4d1b-9c5c-a8f32a858e99)", Label(sub))
+}
+
+func TestLabel_Protected(t *testing.T) {
+	sub := types.Subscription{
+		ID:        uuid.MustParse("9e7969ef-4cb8-4a2d-959f-bfdaae452a3d"),
+		Name:      "Production",
+		Favorite:  true,
+		Protected: true,
+	}
+	assert.Equal(t, "★ ⚠ Production (9e7969ef-4cb8-4a2d-959f-bfdaae452a3d)", Label(sub))
+}
+
+func TestManager_Narrow(t *testing.T) {
+	m := newTestManager()
+	m.Configuration.Subscriptions[1].EnvironmentName = "AzureUSGovernment"
+	m.Configuration.Subscriptions[3].EnvironmentName = "AzureUSGovernment"
+
+	narrowed, err := m.Narrow(Filter{Cloud: "azureusgovernment"})
+	require.NoError(t, err)
+	var names []string
+	for _, sub := range narrowed.Configuration.Subscriptions {
+		names = append(names, sub.Name)
+	}
+	assert.Equal(t, []string{"Development Environment", "Shared"}, names)
+	assert.Len(t, m.Configuration.Subscriptions, 5, "the original configuration is left alone")
+
+	same, err := m.Narrow(Filter{})
+	require.NoError(t, err)
+	assert.Same(t, m, same)
+
+	_, err = m.Narrow(Filter{Cloud: "AzureChinaCloud"})
+	assert.ErrorIs(t, err, pkgerrors.ErrSubscriptionNotFound)
+}
+
+func TestLabeler(t *testing.T) {
+	public := types.Subscription{ID: uuid.MustParse("8aa89ebb-5735-4d1b-9c5c-a8f32a858e99"), Name: "Development", EnvironmentName: "AzureCloud"}
+	gov := types.Subscription{ID: uuid.MustParse("9e7969ef-4cb8-4a2d-959f-bfdaae452a3d"), Name: "Production", EnvironmentName: "AzureUSGovernment"}
+
+	assert.Equal(t, []string{"AzureCloud", "AzureUSGovernment"}, Clouds([]types.Subscription{public, gov, public}))
+	assert.Equal(t, "Development (8aa89ebb-5735-4d1b-9c5c-a8f32a858e99)",
+		Labeler([]types.Subscription{public})(public), "a single cloud is not shown")
+	assert.Equal(t, "Production (9e7969ef-4cb8-4a2d-959f-bfdaae452a3d) · AzureUSGovernment",
+		Labeler([]types.Subscription{public, gov})(gov))
+}
+
+// newMixedCloudManager returns a manager whose profile has a default subscription in
+// both the public and the US Government cloud.
+func newMixedCloudManager(activeCloud string) *Manager {
+	m := newTestManager()
+	m.Configuration.ActiveCloud = activeCloud
+	for i := range m.Configuration.Subscriptions {
+		m.Configuration.Subscriptions[i].EnvironmentName = "AzureCloud"
+	}
+	m.Configuration.Subscriptions[0].IsDefault = true
+	m.Configuration.Subscriptions[2].EnvironmentName = "AzureUSGovernment"
+	m.Configuration.Subscriptions[3].EnvironmentName = "AzureUSGovernment"
+	m.Configuration.Subscriptions[3].IsDefault = true
+	return m
+}
+
+func TestManager_DefaultSubscription_PerCloud(t *testing.T) {
+	tests := []struct {
+		name        string
+		activeCloud string
+		want        string
+	}{
+		{name: "public cloud", activeCloud: "AzureCloud", want: "Production Workloads"},
+		{name: "government cloud", activeCloud: "azureusgovernment", want: "Shared"},
+		{name: "cloud without default", activeCloud: "AzureChinaCloud", want: "Production Workloads"},
+		{name: "unknown cloud", want: "Production Workloads"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMixedCloudManager(tt.activeCloud)
+			sub, err := m.DefaultSubscription()
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, sub.Name)
+			assert.True(t, m.IsCurrent(*sub))
+		})
+	}
+}
+
+func TestManager_SetDefaultSubscription(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		want   []bool
+	}{
+		{
+			name:   "public default leaves the government default",
+			target: "8aa89ebb-5735-4d1b-9c5c-a8f32a858e99",
+			want:   []bool{false, true, false, true, false},
+		},
+		{
+			name:   "government default leaves the public default",
+			target: "9bb28eee-ebaa-442a-83ba-5511810fb151",
+			want:   []bool{true, false, true, false, false},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMixedCloudManager("AzureCloud")
+			require.NoError(t, m.SetDefaultSubscription(types.Identity{SubscriptionID: uuid.MustParse(tt.target)}))
+			var got []bool
+			for _, sub := range m.Configuration.Subscriptions {
+				got = append(got, sub.IsDefault)
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	err := newMixedCloudManager("AzureCloud").SetDefaultSubscription(types.Identity{SubscriptionID: uuid.New()})
+	assert.ErrorIs(t, err, pkgerrors.ErrSubscriptionNotFound)
+}
+
+func TestManager_SetDefaultSubscription_SeveralAccounts(t *testing.T) {
+	id := uuid.MustParse("9e7969ef-4cb8-4a2d-959f-bfdaae452a3d")
+	user := types.Subscription{ID: id, Name: "Production", EnvironmentName: "AzureCloud", IsDefault: true}
+	user.User.Name, user.User.Type = "alice@contoso.com", "user"
+	sp := user
+	sp.IsDefault = false
+	sp.User.Name, sp.User.Type = "deploy-sp", "servicePrincipal"
+	m := &Manager{BaseManager: types.BaseManager{Configuration: &types.Configuration{
+		Subscriptions: []types.Subscription{user, sp},
+	}}}
+
+	require.NoError(t, m.SetDefaultSubscription(sp.Identity()))
+	assert.False(t, m.Configuration.Subscriptions[0].IsDefault)
+	assert.True(t, m.Configuration.Subscriptions[1].IsDefault, "only the entry of the account is marked")
+
+	require.NoError(t, m.SetDefaultSubscription(types.Identity{SubscriptionID: id}))
+	assert.True(t, m.Configuration.Subscriptions[0].IsDefault)
+	assert.False(t, m.Configuration.Subscriptions[1].IsDefault, "an identity without account marks the first entry")
+
+	err := m.SetDefaultSubscription(types.Identity{SubscriptionID: id, User: "bob@contoso.com"})
+	assert.ErrorIs(t, err, pkgerrors.ErrSubscriptionNotFound)
+}
+
+func TestLabeler_SeveralAccounts(t *testing.T) {
+	user := types.Subscription{ID: uuid.MustParse("9e7969ef-4cb8-4a2d-959f-bfdaae452a3d"), Name: "Production"}
+	user.User.Name, user.User.Type = "alice@contoso.com", "user"
+	sp := user
+	sp.User.Name, sp.User.Type = "deploy-sp", "servicePrincipal"
+
+	label := Labeler([]types.Subscription{user, sp})
+	assert.Equal(t, "Production (9e7969ef-4cb8-4a2d-959f-bfdaae452a3d) · alice@contoso.com (user)", label(user))
+	assert.Equal(t, "Production (9e7969ef-4cb8-4a2d-959f-bfdaae452a3d) · deploy-sp (servicePrincipal)", label(sp))
+
+	_, candidates := (&Manager{BaseManager: types.BaseManager{Configuration: &types.Configuration{
+		Subscriptions: []types.Subscription{user, sp},
+	}}}).MatchSubscriptions("9e7969ef-4cb8-4a2d-959f-bfdaae452a3d")
+	assert.Equal(t, []types.Subscription{user, sp}, candidates, "an ID listed for several accounts is ambiguous")
+
+	narrowed, err := (&Manager{BaseManager: types.BaseManager{Configuration: &types.Configuration{
+		Subscriptions: []types.Subscription{user, sp},
+	}}}).Narrow(Filter{User: "deploy"})
+	require.NoError(t, err)
+	exact, _ := narrowed.MatchSubscriptions("Production")
+	if assert.NotNil(t, exact) {
+		assert.Equal(t, sp.Identity(), exact.Identity())
+	}
+}
@@ -0,0 +1,91 @@
+package tenant
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/riweston/aztx/pkg/types"
+)
+
+// Delegation is a customer tenant whose subscriptions a managing tenant reaches
+// through Azure Lighthouse.
+type Delegation struct {
+	Manager       types.Tenant
+	Customer      types.Tenant
+	Subscriptions []types.Subscription
+}
+
+// Delegations returns the Lighthouse delegations of the profile grouped by managing
+// tenant, then by customer tenant, both ordered by name. The customer of a delegated
+// subscription is its home tenant, or the tenant it is listed under when the profile
+// does not record one.
+func (tm *Manager) Delegations() []Delegation {
+	var delegations []Delegation
+	for _, sub := range tm.Configuration.Subscriptions {
+		customer := sub.HomeTenantID
+		if customer == uuid.Nil {
+			customer = sub.TenantID
+		}
+		for _, m := range sub.ManagedByTenants {
+			i := 0
+			for ; i < len(delegations); i++ {
+				if delegations[i].Manager.ID == m.TenantID && delegations[i].Customer.ID == customer {
+					break
+				}
+			}
+			if i == len(delegations) {
+				delegations = append(delegations, Delegation{
+					Manager:  tm.tenant(m.TenantID),
+					Customer: tm.tenant(customer),
+				})
+			}
+			delegations[i].Subscriptions = append(delegations[i].Subscriptions, sub)
+		}
+	}
+
+	sort.SliceStable(delegations, func(i, j int) bool {
+		a, b := delegations[i], delegations[j]
+		if a.Manager.ID != b.Manager.ID {
+			return lessTenant(a.Manager, b.Manager)
+		}
+		return lessTenant(a.Customer, b.Customer)
+	})
+	return delegations
+}
+
+// TenantName returns the display name of t, or its ID when the profile knows no name
+// for it, as for managing tenants the user is not signed in to.
+func TenantName(t types.Tenant) string {
+	if name := DisplayName(t); name != "" {
+		return name
+	}
+	return t.ID.String()
+}
+
+// tenant returns what the profile knows of a tenant: its custom name and the account
+// signed in to it.
+func (tm *Manager) tenant(id uuid.UUID) types.Tenant {
+	t := types.Tenant{ID: id}
+	for _, sub := range tm.Configuration.Subscriptions {
+		if sub.TenantID == id {
+			t.Name = sub.User.Name
+			break
+		}
+	}
+	for _, known := range tm.Configuration.Tenants {
+		if known.ID == id && known.CustomName != "" {
+			t.CustomName = known.CustomName
+			break
+		}
+	}
+	return t
+}
+
+func lessTenant(a, b types.Tenant) bool {
+	la, lb := strings.ToLower(TenantName(a)), strings.ToLower(TenantName(b))
+	if la != lb {
+		return la < lb
+	}
+	return a.ID.String() < b.ID.String()
+}
@@ -0,0 +1,50 @@
+package tenant
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/riweston/aztx/pkg/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func delegate(sub types.Subscription, managers ...uuid.UUID) types.Subscription {
+	for _, id := range managers {
+		sub.ManagedByTenants = append(sub.ManagedByTenants, struct {
+			TenantID uuid.UUID `json:"tenantId"`
+		}{TenantID: id})
+	}
+	return sub
+}
+
+func TestManager_Delegations(t *testing.T) {
+	partnerID := uuid.MustParse("44444444-4444-4444-4444-444444444444")
+	tm := newTestManager()
+	subs := tm.Configuration.Subscriptions
+	subs[0] = delegate(subs[0], fabrikamID)
+	subs[1] = delegate(subs[1], fabrikamID, partnerID)
+	// Reached from the managing tenant: the customer is the home tenant.
+	acme := delegate(newSubscription("Acme Shared", contosoID, "admin@contoso.com"), contosoID)
+	acme.HomeTenantID = acmeID
+	tm.Configuration.Subscriptions = append(subs, acme)
+
+	type summary struct {
+		Manager, Customer string
+		Subscriptions     []string
+	}
+	var got []summary
+	for _, d := range tm.Delegations() {
+		var names []string
+		for _, sub := range d.Subscriptions {
+			names = append(names, sub.Name)
+		}
+		got = append(got, summary{TenantName(d.Manager), TenantName(d.Customer), names})
+	}
+	assert.Equal(t, []summary{
+		{"44444444-4444-4444-4444-444444444444", "admin@contoso.com", []string{"Development"}},
+		{"admin@contoso.com", "ops@acme.com", []string{"Acme Shared"}},
+		{"Fabrikam Customer", "admin@contoso.com", []string{"Production", "Development"}},
+	}, got)
+
+	assert.Empty(t, newTestManager().Delegations())
+}
@@ -0,0 +1,40 @@
+package tenant
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/riweston/aztx/pkg/finder"
+	"github.com/riweston/aztx/pkg/subscription"
+	"github.com/riweston/aztx/pkg/types"
+)
+
+// Preview renders the details of t shown next to the finder: its names and the
+// subscriptions it holds, with the default one marked with an asterisk.
+func (tm *Manager) Preview(t types.Tenant) string {
+	var subs []types.Subscription
+	for _, sub := range tm.Configuration.Subscriptions {
+		if sub.TenantID == t.ID {
+			subs = append(subs, sub)
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(finder.Details(DisplayName(t),
+		finder.Field{Name: "ID", Value: t.ID.String()},
+		finder.Field{Name: "Custom name", Value: t.CustomName},
+		finder.Field{Name: "Account", Value: t.Name},
+		finder.Field{Name: "Subscriptions", Value: fmt.Sprint(len(subs))},
+	))
+	if len(subs) > 0 {
+		b.WriteString("\n")
+	}
+	for _, sub := range subscription.SortFavoritesFirst(subs) {
+		mark := " "
+		if sub.IsDefault {
+			mark = "*"
+		}
+		fmt.Fprintf(&b, "%s %s\n", mark, subscription.Label(sub))
+	}
+	return b.String()
+}
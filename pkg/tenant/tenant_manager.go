@@ -2,6 +2,8 @@ package tenant
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	"github.com/google/uuid"
 	pkgerrors "github.com/riweston/aztx/pkg/errors"
@@ -42,9 +44,115 @@ func (tm *Manager) GetTenants() ([]types.Tenant, error) {
 	for _, tenant := range uniqueTenants {
 		tenants = append(tenants, tenant)
 	}
+	sort.Slice(tenants, func(i, j int) bool {
+		li, lj := strings.ToLower(DisplayName(tenants[i])), strings.ToLower(DisplayName(tenants[j]))
+		if li != lj {
+			return li < lj
+		}
+		return tenants[i].ID.String() < tenants[j].ID.String()
+	})
 	return tenants, nil
 }
 
+// DisplayName returns the custom name of a tenant, or its system-assigned name when
+// it has none.
+func DisplayName(t types.Tenant) string {
+	if t.CustomName != "" {
+		return t.CustomName
+	}
+	return t.Name
+}
+
+// SortKeys lists the fields Sort can order tenants by.
+var SortKeys = []string{"name", "id", "account"}
+
+// Sort orders tenants by display name, ID or account name in place, keeping the
+// order of tenants that compare equal.
+func Sort(tenants []types.Tenant, key string) error {
+	var field func(types.Tenant) string
+	switch key {
+	case "name":
+		field = func(t types.Tenant) string { return strings.ToLower(DisplayName(t)) }
+	case "id":
+		field = func(t types.Tenant) string { return t.ID.String() }
+	case "account":
+		field = func(t types.Tenant) string { return strings.ToLower(t.Name) }
+	default:
+		return pkgerrors.ErrInvalidSortKey(key, SortKeys)
+	}
+	sort.SliceStable(tenants, func(i, j int) bool {
+		return field(tenants[i]) < field(tenants[j])
+	})
+	return nil
+}
+
+// Label renders a tenant the way the finder lists it.
+func Label(t types.Tenant) string {
+	return fmt.Sprintf("%s (%s)", DisplayName(t), t.ID)
+}
+
+// MatchTenants resolves a query against the tenant IDs, custom names and names.
+// A single case-insensitive exact match is returned as exact. Several exact matches,
+// or failing that every tenant whose ID or names contain the query, are returned as
+// candidates.
+func (tm *Manager) MatchTenants(query string) (exact *types.Tenant, candidates []types.Tenant) {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil, nil
+	}
+	tenants, err := tm.GetTenants()
+	if err != nil {
+		return nil, nil
+	}
+
+	var exacts, partials []types.Tenant
+	for _, t := range tenants {
+		fields := []string{t.ID.String(), strings.ToLower(t.CustomName), strings.ToLower(t.Name)}
+		matched := false
+		for _, field := range fields {
+			if field != "" && field == query {
+				exacts = append(exacts, t)
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+		for _, field := range fields {
+			if field != "" && strings.Contains(field, query) {
+				partials = append(partials, t)
+				break
+			}
+		}
+	}
+
+	if len(exacts) == 1 {
+		return &exacts[0], nil
+	}
+	if len(exacts) > 1 {
+		return nil, exacts
+	}
+	return nil, partials
+}
+
+// Completions returns the values a tenant query can be completed with: the custom
+// names of the tenants, described by their ID, and their IDs, described by their name.
+func (tm *Manager) Completions() []types.Completion {
+	tenants, err := tm.GetTenants()
+	if err != nil {
+		return nil
+	}
+	var names, ids []types.Completion
+	for _, t := range tenants {
+		if t.CustomName != "" {
+			names = append(names, types.Completion{Value: t.CustomName, Description: t.ID.String()})
+		}
+		ids = append(ids, types.Completion{Value: t.ID.String(), Description: DisplayName(t)})
+	}
+	return append(names, ids...)
+}
+
 // FindTenantIndex uses fuzzy finding to let user select a tenant
 func (tm *Manager) FindTenantIndex() (*types.Tenant, error) {
 	tenants, err := tm.GetTenants()
@@ -52,12 +160,13 @@ func (tm *Manager) FindTenantIndex() (*types.Tenant, error) {
 		return nil, fmt.Errorf("failed to get tenants: %w", err)
 	}
 
-	return finder.Fuzzy(tenants, func(t types.Tenant) string {
-		if t.CustomName != "" {
-			return fmt.Sprintf("%s (%s)", t.CustomName, t.ID)
-		}
-		return fmt.Sprintf("%s (%s)", t.Name, t.ID)
-	})
+	return tm.SelectTenant(tenants)
+}
+
+// SelectTenant uses fuzzy finding to select one of the given tenants, showing the
+// details of the tenant under the cursor in a preview pane.
+func (tm *Manager) SelectTenant(tenants []types.Tenant) (*types.Tenant, error) {
+	return finder.FuzzyPreview(tenants, Label, tm.Preview)
 }
 
 // SaveTenantName saves or updates a tenant's custom name.
@@ -99,3 +208,24 @@ func (tm *Manager) SaveTenantName(id uuid.UUID, customName string) error {
 
 	return nil
 }
+
+// ClearTenantName removes a tenant's custom name. It returns false when the tenant
+// had no custom name. Entries that only existed to hold the custom name are dropped.
+func (tm *Manager) ClearTenantName(id uuid.UUID) (bool, error) {
+	if id == uuid.Nil {
+		return false, pkgerrors.ErrInvalidTenantID
+	}
+
+	for i, tenant := range tm.Configuration.Tenants {
+		if tenant.ID != id || tenant.CustomName == "" {
+			continue
+		}
+		if tenant.Name == "" {
+			tm.Configuration.Tenants = append(tm.Configuration.Tenants[:i], tm.Configuration.Tenants[i+1:]...)
+		} else {
+			tm.Configuration.Tenants[i].CustomName = ""
+		}
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,168 @@
+package tenant
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	pkgerrors "github.com/riweston/aztx/pkg/errors"
+	"github.com/riweston/aztx/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	contosoID  = uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	fabrikamID = uuid.MustParse("22222222-2222-2222-2222-222222222222")
+	acmeID     = uuid.MustParse("33333333-3333-3333-3333-333333333333")
+)
+
+func newSubscription(name string, tenantID uuid.UUID, user string) types.Subscription {
+	sub := types.Subscription{ID: uuid.New(), Name: name, TenantID: tenantID}
+	sub.User.Name = user
+	return sub
+}
+
+func newTestManager() *Manager {
+	return &Manager{BaseManager: types.BaseManager{Configuration: &types.Configuration{
+		Subscriptions: []types.Subscription{
+			newSubscription("Production", contosoID, "admin@contoso.com"),
+			newSubscription("Development", contosoID, "admin@contoso.com"),
+			newSubscription("Fabrikam", fabrikamID, "guest@contoso.com"),
+			newSubscription("Acme", acmeID, "ops@acme.com"),
+		},
+		Tenants: []types.Tenant{
+			{ID: fabrikamID, CustomName: "Fabrikam Customer"},
+		},
+	}}}
+}
+
+func TestManager_GetTenants(t *testing.T) {
+	tenants, err := newTestManager().GetTenants()
+	require.NoError(t, err)
+
+	var labels []string
+	for _, tenant := range tenants {
+		labels = append(labels, DisplayName(tenant))
+	}
+	assert.Equal(t, []string{"admin@contoso.com", "Fabrikam Customer", "ops@acme.com"}, labels)
+}
+
+func TestSort(t *testing.T) {
+	tests := []struct {
+		key     string
+		want    []uuid.UUID
+		wantErr bool
+	}{
+		{key: "name", want: []uuid.UUID{acmeID, contosoID, fabrikamID}},
+		{key: "id", want: []uuid.UUID{contosoID, fabrikamID, acmeID}},
+		{key: "account", want: []uuid.UUID{contosoID, fabrikamID, acmeID}},
+		{key: "size", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			tenants := []types.Tenant{
+				{ID: acmeID, Name: "ops@acme.com", CustomName: "Acme"},
+				{ID: fabrikamID, Name: "guest@contoso.com", CustomName: "Fabrikam Customer"},
+				{ID: contosoID, Name: "admin@contoso.com"},
+			}
+			err := Sort(tenants, tt.key)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, pkgerrors.ErrUnknownSortKey)
+				return
+			}
+			require.NoError(t, err)
+			var ids []uuid.UUID
+			for _, tenant := range tenants {
+				ids = append(ids, tenant.ID)
+			}
+			assert.Equal(t, tt.want, ids)
+		})
+	}
+}
+
+func TestManager_Completions(t *testing.T) {
+	assert.Equal(t, []types.Completion{
+		{Value: "Fabrikam Customer", Description: fabrikamID.String()},
+		{Value: contosoID.String(), Description: "admin@contoso.com"},
+		{Value: fabrikamID.String(), Description: "Fabrikam Customer"},
+		{Value: acmeID.String(), Description: "ops@acme.com"},
+	}, newTestManager().Completions())
+
+	empty := &Manager{BaseManager: types.BaseManager{Configuration: &types.Configuration{}}}
+	assert.Empty(t, empty.Completions())
+}
+
+func TestManager_MatchTenants(t *testing.T) {
+	tests := []struct {
+		name           string
+		query          string
+		wantExact      uuid.UUID
+		wantCandidates []uuid.UUID
+	}{
+		{name: "exact ID", query: "33333333-3333-3333-3333-333333333333", wantExact: acmeID},
+		{name: "exact custom name ignores case", query: "fabrikam customer", wantExact: fabrikamID},
+		{name: "exact account name", query: "admin@contoso.com", wantExact: contosoID},
+		{name: "partial match", query: "contoso", wantCandidates: []uuid.UUID{contosoID, fabrikamID}},
+		{name: "partial ID", query: "2222", wantCandidates: []uuid.UUID{fabrikamID}},
+		{name: "no match", query: "missing"},
+		{name: "empty query", query: " "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exact, candidates := newTestManager().MatchTenants(tt.query)
+			if tt.wantExact != uuid.Nil {
+				if assert.NotNil(t, exact) {
+					assert.Equal(t, tt.wantExact, exact.ID)
+				}
+			} else {
+				assert.Nil(t, exact)
+			}
+
+			var ids []uuid.UUID
+			for _, c := range candidates {
+				ids = append(ids, c.ID)
+			}
+			assert.Equal(t, tt.wantCandidates, ids)
+		})
+	}
+}
+
+func TestManager_ClearTenantName(t *testing.T) {
+	m := newTestManager()
+	m.Configuration.Tenants = append(m.Configuration.Tenants,
+		types.Tenant{ID: contosoID, Name: "Contoso", CustomName: "Contoso HQ"})
+
+	cleared, err := m.ClearTenantName(fabrikamID)
+	require.NoError(t, err)
+	assert.True(t, cleared)
+
+	cleared, err = m.ClearTenantName(contosoID)
+	require.NoError(t, err)
+	assert.True(t, cleared)
+	assert.Equal(t, []types.Tenant{{ID: contosoID, Name: "Contoso"}}, m.Configuration.Tenants,
+		"entries holding only a custom name are dropped, others keep their name")
+
+	cleared, err = m.ClearTenantName(acmeID)
+	require.NoError(t, err)
+	assert.False(t, cleared)
+
+	_, err = m.ClearTenantName(uuid.Nil)
+	assert.Error(t, err)
+}
+
+func TestManager_Preview(t *testing.T) {
+	tm := newTestManager()
+	subs := tm.Configuration.Subscriptions
+	subs[1].IsDefault = true
+	subs[1].Favorite = true
+
+	preview := tm.Preview(types.Tenant{ID: contosoID, Name: "admin@contoso.com"})
+	assert.Equal(t, "admin@contoso.com\n\n"+
+		"ID             11111111-1111-1111-1111-111111111111\n"+
+		"Custom name    -\n"+
+		"Account        admin@contoso.com\n"+
+		"Subscriptions  2\n\n"+
+		"* ★ Development ("+subs[1].ID.String()+")\n"+
+		"  Production ("+subs[0].ID.String()+")\n", preview)
+}
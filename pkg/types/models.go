@@ -3,6 +3,9 @@
 package types
 
 import (
+	"strings"
+	"time"
+
 	"github.com/google/uuid"
 	"github.com/riweston/aztx/pkg/errors"
 )
@@ -13,6 +16,7 @@ type Configuration struct {
 	InstallationID uuid.UUID      `json:"installationId"`    // Unique identifier for the installation
 	Tenants        []Tenant       `json:"tenants,omitempty"` // List of available Azure tenants
 	Subscriptions  []Subscription `json:"subscriptions"`     // List of available Azure subscriptions
+	ActiveCloud    string         `json:"-"`                 // Cloud the Azure CLI targets, empty when unknown
 }
 
 // Validate checks if the configuration has valid data.
@@ -84,6 +88,11 @@ type Subscription struct {
 	ManagedByTenants []struct {
 		TenantID uuid.UUID `json:"tenantId"` // ID of the tenant managing this subscription
 	} `json:"managedByTenants"`
+	Alias     string    `json:"-"` // User-defined short name for the subscription, kept by aztx
+	Favorite  bool      `json:"-"` // Whether the user marked the subscription as a favorite, kept by aztx
+	Tags      []string  `json:"-"` // User-defined labels for the subscription, kept by aztx
+	Protected bool      `json:"-"` // Whether a protected rule in ~/.aztx.yml matches the subscription
+	LastUsed  time.Time `json:"-"` // When the subscription was last left according to the history of aztx, zero when unknown
 }
 
 // GetID implements the IDGetter interface for Subscription
@@ -91,6 +100,11 @@ func (s Subscription) GetID() uuid.UUID {
 	return s.ID
 }
 
+// Identity returns the identity of the profile entry s.
+func (s Subscription) Identity() Identity {
+	return Identity{SubscriptionID: s.ID, User: s.User.Name, UserType: s.User.Type}
+}
+
 // Validate checks if the subscription has valid data.
 // It ensures that required fields like ID, Name, and TenantID are properly set.
 // Returns an error if any validation check fails.
@@ -116,7 +130,29 @@ func (s *Subscription) Validate() error {
 	return nil
 }
 
+// Identity identifies a context of the Azure profile. The profile lists a subscription
+// once for every account signed in to it, so the subscription ID alone can match
+// several entries. Empty user fields match any account.
+type Identity struct {
+	SubscriptionID uuid.UUID
+	User           string // Name of the account, e.g. a user principal name or an application ID
+	UserType       string // Type of the account, e.g. user or servicePrincipal
+}
+
+// Matches reports whether sub is the profile entry the identity refers to.
+func (i Identity) Matches(sub Subscription) bool {
+	return sub.ID == i.SubscriptionID &&
+		(i.User == "" || strings.EqualFold(sub.User.Name, i.User)) &&
+		(i.UserType == "" || strings.EqualFold(sub.User.Type, i.UserType))
+}
+
 // User represents an Azure user
 type User struct {
 	Name string `json:"name"`
 }
+
+// Completion is a value offered by shell completion, with a description shown next to it.
+type Completion struct {
+	Value       string
+	Description string
+}
@@ -198,3 +198,28 @@ func TestIDGetter_Implementation(t *testing.T) {
 	assert.Equal(t, validID, tenant.GetID())
 	assert.Equal(t, validID, subscription.GetID())
 }
+
+func TestIdentity_Matches(t *testing.T) {
+	id := uuid.MustParse("a1a2a3a4-b1b2-c1c2-d1d2-d3d4d5d6d7d8")
+	sub := Subscription{ID: id}
+	sub.User.Name = "alice@contoso.com"
+	sub.User.Type = "user"
+
+	tests := []struct {
+		name     string
+		identity Identity
+		want     bool
+	}{
+		{name: "own identity", identity: sub.Identity(), want: true},
+		{name: "any account", identity: Identity{SubscriptionID: id}, want: true},
+		{name: "user ignores case", identity: Identity{SubscriptionID: id, User: "Alice@Contoso.com"}, want: true},
+		{name: "other user", identity: Identity{SubscriptionID: id, User: "deploy-sp"}, want: false},
+		{name: "other user type", identity: Identity{SubscriptionID: id, User: "alice@contoso.com", UserType: "servicePrincipal"}, want: false},
+		{name: "other subscription", identity: Identity{SubscriptionID: uuid.New(), User: "alice@contoso.com"}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, tt.identity.Matches(sub))
+		})
+	}
+}